@@ -0,0 +1,126 @@
+package orderpb
+
+import (
+	"demo-service/internal/model"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// FromModel переводит заказ в protobuf-сообщение
+func FromModel(o *model.Order) *Order {
+	items := make([]*Item, 0, len(o.Items))
+	for _, it := range o.Items {
+		items = append(items, &Item{
+			ChrtId:      int64(it.ChrtID),
+			TrackNumber: it.TrackNumber,
+			Price:       int64(it.Price),
+			Rid:         it.Rid,
+			Name:        it.Name,
+			Sale:        int64(it.Sale),
+			Size:        it.Size,
+			TotalPrice:  int64(it.TotalPrice),
+			NmId:        int64(it.NmID),
+			Brand:       it.Brand,
+			Status:      int64(it.Status),
+		})
+	}
+
+	return &Order{
+		OrderUid:    o.OrderUID,
+		TrackNumber: o.TrackNumber,
+		Entry:       o.Entry,
+		Delivery: &Delivery{
+			Name:    o.Delivery.Name,
+			Phone:   o.Delivery.Phone,
+			Zip:     o.Delivery.Zip,
+			City:    o.Delivery.City,
+			Address: o.Delivery.Address,
+			Region:  o.Delivery.Region,
+			Email:   o.Delivery.Email,
+		},
+		Payment: &Payment{
+			Transaction:  o.Payment.Transaction,
+			RequestId:    o.Payment.RequestID,
+			Currency:     o.Payment.Currency,
+			Provider:     o.Payment.Provider,
+			Amount:       int64(o.Payment.Amount),
+			PaymentDt:    o.Payment.PaymentDt,
+			Bank:         o.Payment.Bank,
+			DeliveryCost: int64(o.Payment.DeliveryCost),
+			GoodsTotal:   int64(o.Payment.GoodsTotal),
+			CustomFee:    int64(o.Payment.CustomFee),
+		},
+		Items:             items,
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerId:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		Shardkey:          o.Shardkey,
+		SmId:              int64(o.SmID),
+		DateCreated:       timestamppb.New(o.DateCreated),
+		OofShard:          o.OofShard,
+		Status:            string(o.Status),
+	}
+}
+
+// ToModel переводит protobuf-сообщение в заказ
+func (x *Order) ToModel() *model.Order {
+	o := &model.Order{
+		OrderUID:          x.GetOrderUid(),
+		TrackNumber:       x.GetTrackNumber(),
+		Entry:             x.GetEntry(),
+		Locale:            x.GetLocale(),
+		InternalSignature: x.GetInternalSignature(),
+		CustomerID:        x.GetCustomerId(),
+		DeliveryService:   x.GetDeliveryService(),
+		Shardkey:          x.GetShardkey(),
+		SmID:              int(x.GetSmId()),
+		OofShard:          x.GetOofShard(),
+		Status:            model.Status(x.GetStatus()),
+	}
+	if x.GetDateCreated() != nil {
+		o.DateCreated = x.GetDateCreated().AsTime()
+	}
+
+	d := x.GetDelivery()
+	o.Delivery = model.Delivery{
+		Name:    d.GetName(),
+		Phone:   d.GetPhone(),
+		Zip:     d.GetZip(),
+		City:    d.GetCity(),
+		Address: d.GetAddress(),
+		Region:  d.GetRegion(),
+		Email:   d.GetEmail(),
+	}
+
+	p := x.GetPayment()
+	o.Payment = model.Payment{
+		Transaction:  p.GetTransaction(),
+		RequestID:    p.GetRequestId(),
+		Currency:     p.GetCurrency(),
+		Provider:     p.GetProvider(),
+		Amount:       int(p.GetAmount()),
+		PaymentDt:    p.GetPaymentDt(),
+		Bank:         p.GetBank(),
+		DeliveryCost: int(p.GetDeliveryCost()),
+		GoodsTotal:   int(p.GetGoodsTotal()),
+		CustomFee:    int(p.GetCustomFee()),
+	}
+
+	for _, it := range x.GetItems() {
+		o.Items = append(o.Items, model.Item{
+			ChrtID:      int(it.GetChrtId()),
+			TrackNumber: it.GetTrackNumber(),
+			Price:       int(it.GetPrice()),
+			Rid:         it.GetRid(),
+			Name:        it.GetName(),
+			Sale:        int(it.GetSale()),
+			Size:        it.GetSize(),
+			TotalPrice:  int(it.GetTotalPrice()),
+			NmID:        int(it.GetNmId()),
+			Brand:       it.GetBrand(),
+			Status:      int(it.GetStatus()),
+		})
+	}
+	return o
+}
@@ -0,0 +1,12 @@
+package orderpb
+
+import _ "embed"
+
+// Schema - текст order.proto для регистрации в schema registry
+//
+//go:embed order.proto
+var Schema string
+
+// OrderMessageIndex - позиция Order среди сообщений order.proto,
+// нужна для заголовка сообщений в формате Confluent
+const OrderMessageIndex = 3
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"demo-service/internal/infrastructure/postgres"
+	"demo-service/internal/model"
+)
+
+// orderStore - чтение заказов, общее для API сервиса и postgres
+type orderStore interface {
+	GetOrder(ctx context.Context, orderUID string) (*model.Order, error)
+	ListOrders(ctx context.Context, f postgres.ListFilter) ([]*model.Order, error)
+}
+
+// apiClient обращается к HTTP API сервиса
+type apiClient struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+func newAPIClient(addr, token string) *apiClient {
+	return &apiClient{base: strings.TrimRight(addr, "/"), token: token, http: &http.Client{Timeout: time.Minute}}
+}
+
+// apiError - ответ API с ошибкой в формате problem+json
+type apiError struct {
+	Status int
+	Title  string
+	Detail string
+}
+
+func (e *apiError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("%d %s", e.Status, e.Title)
+	}
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Title, e.Detail)
+}
+
+// do выполняет запрос, ответ с ошибкой возвращается как *apiError
+func (c *apiClient) do(ctx context.Context, hc *http.Client, method, path string, body any) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, r)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Client-ID", "orderctl")
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		e := &apiError{Status: resp.StatusCode, Title: http.StatusText(resp.StatusCode)}
+		var p struct {
+			Title  string `json:"title"`
+			Detail string `json:"detail"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&p) == nil && p.Title != "" {
+			e.Title, e.Detail = p.Title, p.Detail
+		}
+		return nil, e
+	}
+	return resp, nil
+}
+
+// call выполняет запрос и разбирает JSON-ответ в out, out может быть nil
+func (c *apiClient) call(ctx context.Context, method, path string, body, out any) error {
+	resp, err := c.do(ctx, c.http, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// download копирует тело ответа GET в w. Общий таймаут клиента не действует:
+// большая выгрузка может идти дольше.
+func (c *apiClient) download(ctx context.Context, path string, w io.Writer) (int64, error) {
+	hc := *c.http
+	hc.Timeout = 0
+	resp, err := c.do(ctx, &hc, http.MethodGet, path, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return io.Copy(w, resp.Body)
+}
+
+func (c *apiClient) GetOrder(ctx context.Context, orderUID string) (*model.Order, error) {
+	var o model.Order
+	err := c.call(ctx, http.MethodGet, "/order/"+url.PathEscape(orderUID), nil, &o)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+		return nil, postgres.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// ListOrders читает страницу GET /orders. Токен страницы - order_uid последнего
+// заказа в base64, поэтому курсор After переводится в page_token.
+func (c *apiClient) ListOrders(ctx context.Context, f postgres.ListFilter) ([]*model.Order, error) {
+	q := url.Values{}
+	q.Set("page_size", strconv.Itoa(f.Limit))
+	if f.After != "" {
+		q.Set("page_token", base64.RawURLEncoding.EncodeToString([]byte(f.After)))
+	}
+	if f.CustomerID != "" {
+		q.Set("customer_id", f.CustomerID)
+	}
+	if f.DeliveryService != "" {
+		q.Set("delivery_service", f.DeliveryService)
+	}
+	var page struct {
+		Orders []*model.Order `json:"orders"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/orders?"+q.Encode(), nil, &page); err != nil {
+		return nil, err
+	}
+	return page.Orders, nil
+}
@@ -0,0 +1,188 @@
+// orderctl - командная строка для эксплуатации сервиса: заказы, кеш, consumer,
+// DLQ, миграции, сверка и переобработка. Заказы и кеш читаются через HTTP API
+// сервиса, с -db заказы читаются и пишутся напрямую в postgres. Kafka и postgres
+// настраиваются теми же переменными окружения, что и сервис.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"demo-service/internal/config"
+	"demo-service/internal/infrastructure/postgres"
+)
+
+// command - подкоманда orderctl
+type command struct {
+	usage string
+	help  string
+	run   func(ctx context.Context, env *env, args []string) error
+}
+
+// commands заполняется в init: подкоманды сами обращаются к нему за справкой
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"get":       {"get <order_uid>...", "показать заказы", runGet},
+		"list":      {"list [-customer ID] [-service S] [-after UID] [-limit N] [-json]", "список заказов по order_uid", runList},
+		"search":    {"search [-customer ID] [-service S] [-status S] [-track T] [-text T] [-from DATE] [-to DATE] [-limit N] [-json]", "поиск заказов по полям", runSearch},
+		"export":    {"export [-format ndjson|csv|xlsx] [-rows order|item] [-pii masked|full] [-customer ID] [-service S] [-status S] [-from DATE] [-to DATE] [-o FILE]", "выгрузить заказы", runExport},
+		"import":    {"import [-dry-run] FILE...", "загрузить заказы из JSON или NDJSON через Kafka (с -db - сразу в postgres)", runImport},
+		"backfill":  {"backfill [-format ndjson|csv] [-batch N] [-conflict skip|overwrite|fail] [-rejects FILE] [-checkpoint FILE] FILE...", "загрузить исторические заказы из NDJSON или CSV пачками прямо в postgres", runBackfill},
+		"cache":     {"cache stats | reload | clear | evict <order_uid>...", "статистика кеша, перезагрузка из бд, очистка, вытеснение заказов", runCache},
+		"consumer":  {"consumer lag [-group G] [-json] | metrics | status | pause|resume [-topic T] | seek [-topic T] -partition P -offset N", "отставание группы, счётчики и состояние consumer, пауза и смена смещения", runConsumer},
+		"dlq":       {"dlq list|requeue [-topic T] [-group G] [-from P=OFF,...] [-limit N] [-dry-run] [-json]", "сообщения DLQ и возврат их в исходный топик", runDLQ},
+		"migrate":   {"migrate [-status]", "применить миграции схемы бд", runMigrate},
+		"reconcile": {"reconcile [-limit N] [-json]", "сверка сумм всех заказов", runReconcile},
+		"replay":    {"replay [-partitions P,...] [-from-offsets P=OFF,...] [-to-offsets P=OFF,...] [-from-time T] [-to-time T] [-resume ID] [-dry-run] [-poll D]", "переобработать сообщения топика заказов через API и дождаться завершения", runReplay},
+	}
+}
+
+// env - общие настройки подкоманд
+type env struct {
+	cfg   config.Config
+	api   *apiClient
+	useDB bool
+	store *postgres.Postgres
+}
+
+// orders возвращает источник заказов: postgres с -db, иначе API сервиса
+func (e *env) orders(ctx context.Context) (orderStore, error) {
+	if !e.useDB {
+		return e.api, nil
+	}
+	return e.postgres(ctx)
+}
+
+func (e *env) postgres(ctx context.Context) (*postgres.Postgres, error) {
+	if e.store == nil {
+		store, err := postgres.New(ctx, e.cfg.PostgresDSN)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось подключиться к базе: %w", err)
+		}
+		e.store = store
+	}
+	return e.store, nil
+}
+
+func main() {
+	flag.Usage = usage
+	addr := flag.String("addr", "http://localhost:8081", "адрес HTTP API сервиса")
+	token := flag.String("token", os.Getenv("ADMIN_TOKEN"), "токен администратора (по умолчанию $ADMIN_TOKEN)")
+	useDB := flag.Bool("db", false, "читать и писать заказы напрямую в postgres ($POSTGRES_DSN), а не через API")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "неизвестная команда %q\n\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fail("Ошибка конфигурации: %v", err)
+	}
+	e := &env{cfg: cfg, api: newAPIClient(*addr, *token), useDB: *useDB}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	err = cmd.run(ctx, e, flag.Args()[1:])
+	if e.store != nil {
+		e.store.Close()
+	}
+	stop()
+	if err != nil {
+		fail("%s: %v", flag.Arg(0), err)
+	}
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "Использование: orderctl [-addr URL] [-token T] [-db] <команда> [флаги]")
+	fmt.Fprintln(out, "\nКоманды:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %s\n      %s\n", commands[name].usage, commands[name].help)
+	}
+	fmt.Fprintln(out, "\nОбщие флаги:")
+	flag.PrintDefaults()
+}
+
+// newFlags создаёт набор флагов подкоманды, ошибка разбора завершает программу
+func newFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Использование: orderctl %s\n", commands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseOffsets разбирает смещения по разделам: "0=100,1=250"
+func parseOffsets(s string) (map[int]int64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	out := make(map[int]int64)
+	for _, part := range strings.Split(s, ",") {
+		p, off, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("ожидалось раздел=смещение, получено %q", part)
+		}
+		pn, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, err
+		}
+		on, err := strconv.ParseInt(off, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		out[pn] = on
+	}
+	return out, nil
+}
+
+// parseInts разбирает список чисел через запятую: "0,2"
+func parseInts(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var out []int
+	for _, part := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// parseTime разбирает время RFC 3339, пустая строка - нулевое время
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func fail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
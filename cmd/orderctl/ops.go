@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"demo-service/db"
+	"demo-service/internal/infrastructure/cache"
+	"demo-service/internal/infrastructure/kafka"
+	"demo-service/internal/reconcile"
+)
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// subcommand отделяет действие подкоманды от её флагов
+func subcommand(name string, args []string) (string, []string, error) {
+	if len(args) == 0 {
+		newFlags(name).Usage()
+		return "", nil, errors.New("не указано действие")
+	}
+	return args[0], args[1:], nil
+}
+
+func runCache(ctx context.Context, e *env, args []string) error {
+	action, args, err := subcommand("cache", args)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Updated int `json:"updated"`
+		Removed int `json:"removed"`
+	}
+	switch action {
+	case "stats":
+		var stats cache.Stats
+		if err := e.api.call(ctx, http.MethodGet, "/admin/cache", nil, &stats); err != nil {
+			return err
+		}
+		return printJSON(stats)
+	case "reload":
+		if err := e.api.call(ctx, http.MethodPost, "/admin/cache/reload", nil, &result); err != nil {
+			return err
+		}
+		fmt.Printf("Кеш перезагружен: загружено %d, убрано %d\n", result.Updated, result.Removed)
+	case "clear":
+		if err := e.api.call(ctx, http.MethodDelete, "/admin/cache", nil, &result); err != nil {
+			return err
+		}
+		fmt.Printf("Кеш очищен: убрано %d\n", result.Removed)
+	case "evict":
+		if len(args) == 0 {
+			return errors.New("не указан order_uid")
+		}
+		for _, uid := range args {
+			err := e.api.call(ctx, http.MethodDelete, "/admin/cache/"+url.PathEscape(uid), nil, nil)
+			var apiErr *apiError
+			if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+				fmt.Printf("Заказа %s нет в кеше\n", uid)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Заказ %s вытеснен из кеша\n", uid)
+		}
+	default:
+		return fmt.Errorf("неизвестное действие %q, ожидалось stats, reload, clear или evict", action)
+	}
+	return nil
+}
+
+func runConsumer(ctx context.Context, e *env, args []string) error {
+	action, args, err := subcommand("consumer", args)
+	if err != nil {
+		return err
+	}
+	switch action {
+	case "metrics":
+		var metrics any
+		if err := e.api.call(ctx, http.MethodGet, "/admin/consumer/metrics", nil, &metrics); err != nil {
+			return err
+		}
+		return printJSON(metrics)
+	case "status":
+		var status kafka.ConsumerStatus
+		if err := e.api.call(ctx, http.MethodGet, "/admin/consumer", nil, &status); err != nil {
+			return err
+		}
+		return printJSON(status)
+	case "pause", "resume":
+		fs := newFlags("consumer")
+		topic := fs.String("topic", "", "топик, по умолчанию все")
+		fs.Parse(args)
+		path := "/admin/consumer/" + action
+		if *topic != "" {
+			path += "?topic=" + url.QueryEscape(*topic)
+		}
+		if err := e.api.call(ctx, http.MethodPost, path, nil, nil); err != nil {
+			return err
+		}
+		if action == "pause" {
+			fmt.Println("Чтение приостановлено")
+		} else {
+			fmt.Println("Чтение возобновлено")
+		}
+		return nil
+	case "seek":
+		return runSeek(ctx, e, args)
+	case "lag":
+	default:
+		return fmt.Errorf("неизвестное действие %q, ожидалось lag, metrics, status, pause, resume или seek", action)
+	}
+
+	fs := newFlags("consumer")
+	group := fs.String("group", e.cfg.Kafka.GroupID, "группа consumer")
+	asJSON := fs.Bool("json", false, "напечатать в JSON")
+	fs.Parse(args)
+
+	insp, err := kafka.NewInspector(e.cfg.Kafka)
+	if err != nil {
+		return err
+	}
+	lags, err := insp.GroupLag(ctx, *group, e.cfg.Kafka.Topic, e.cfg.Kafka.StatusTopic, e.cfg.Kafka.CancelTopic)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(lags)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ТОПИК\tРАЗДЕЛ\tЗАКОММИЧЕНО\tКОНЕЦ\tОТСТАВАНИЕ")
+	var total int64
+	for _, l := range lags {
+		committed := strconv.FormatInt(l.Committed, 10)
+		if l.Committed < 0 {
+			committed = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%d\n", l.Topic, l.Partition, committed, l.End, l.Lag)
+		total += l.Lag
+	}
+	tw.Flush()
+	fmt.Printf("Группа %s, всего отставание: %d\n", *group, total)
+	return nil
+}
+
+func runSeek(ctx context.Context, e *env, args []string) error {
+	fs := newFlags("consumer")
+	topic := fs.String("topic", e.cfg.Kafka.Topic, "топик")
+	partition := fs.Int("partition", 0, "раздел")
+	offset := fs.Int64("offset", -1, "следующее смещение для чтения группой")
+	fs.Parse(args)
+	if *offset < 0 {
+		return errors.New("не указан -offset")
+	}
+	body := map[string]any{"topic": *topic, "partition": *partition, "offset": *offset}
+	if err := e.api.call(ctx, http.MethodPost, "/admin/consumer/seek", body, nil); err != nil {
+		return err
+	}
+	fmt.Printf("Топик %s, раздел %d: чтение продолжится со смещения %d\n", *topic, *partition, *offset)
+	return nil
+}
+
+func runDLQ(ctx context.Context, e *env, args []string) error {
+	action, args, err := subcommand("dlq", args)
+	if err != nil {
+		return err
+	}
+	if action != "list" && action != "requeue" {
+		return fmt.Errorf("неизвестное действие %q, ожидалось list или requeue", action)
+	}
+	fs := newFlags("dlq")
+	topic := fs.String("topic", e.cfg.Kafka.Topic+e.cfg.Kafka.DLQSuffix, "топик DLQ")
+	group := fs.String("group", e.cfg.Kafka.GroupID+".requeue", "requeue: группа, в которой хранится, докуда DLQ уже возвращена")
+	fromFlag := fs.String("from", "", "начальные смещения: раздел=смещение,..., по умолчанию с начала (requeue - со смещений группы)")
+	limit := fs.Int("limit", 20, "сколько сообщений прочитать, 0 - все")
+	dryRun := fs.Bool("dry-run", false, "requeue: только показать, что будет отправлено")
+	asJSON := fs.Bool("json", false, "list: напечатать сообщения в NDJSON")
+	fs.Parse(args)
+
+	from, err := parseOffsets(*fromFlag)
+	if err != nil {
+		return fmt.Errorf("некорректный -from: %w", err)
+	}
+	insp, err := kafka.NewInspector(e.cfg.Kafka)
+	if err != nil {
+		return err
+	}
+	if action == "requeue" && from == nil {
+		if from, err = insp.GroupOffsets(ctx, *group, *topic); err != nil {
+			return err
+		}
+	}
+	letters, err := insp.ReadDLQ(ctx, *topic, from, *limit)
+	if err != nil {
+		return err
+	}
+
+	if action == "list" || *dryRun {
+		if *asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			for _, d := range letters {
+				if err := enc.Encode(d); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		for _, d := range letters {
+			fmt.Printf("%d/%d %s ключ=%q из %s/%d/%d\n  ошибка: %s\n  данные: %.200q\n",
+				d.Partition, d.Offset, d.Time.Format(time.DateTime), d.Key,
+				d.SourceTopic, d.SourcePartition, d.SourceOffset, d.Error, d.Value)
+		}
+		fmt.Printf("Сообщений: %d\n", len(letters))
+		return nil
+	}
+
+	n, err := insp.Requeue(ctx, letters)
+	if err != nil {
+		return err
+	}
+	// при сбое до коммита последняя пачка вернётся повторно при следующем запуске
+	if err := insp.CommitDLQ(ctx, *group, *topic, letters); err != nil {
+		return fmt.Errorf("возвращено %d сообщений, но положение не сохранено: %w", n, err)
+	}
+	fmt.Printf("Возвращено в исходные топики: %d\n", n)
+	return nil
+}
+
+func runMigrate(ctx context.Context, e *env, args []string) error {
+	fs := newFlags("migrate")
+	status := fs.Bool("status", false, "только показать неприменённые миграции")
+	fs.Parse(args)
+
+	store, err := e.postgres(ctx)
+	if err != nil {
+		return err
+	}
+	if *status {
+		pending, err := store.PendingMigrations(ctx, db.Migrations)
+		if err != nil {
+			return err
+		}
+		for _, m := range pending {
+			fmt.Println("не применена:", m.Version)
+		}
+		fmt.Printf("Миграций: %d, неприменённых: %d\n", len(db.Migrations), len(pending))
+		return nil
+	}
+
+	applied, err := store.Migrate(ctx, db.Migrations)
+	for _, v := range applied {
+		fmt.Println("применена:", v)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Println("Схема актуальна")
+	}
+	return nil
+}
+
+func runReconcile(ctx context.Context, e *env, args []string) error {
+	fs := newFlags("reconcile")
+	limit := fs.Int("limit", 0, "сколько заказов с расхождениями печатать, 0 - все")
+	asJSON := fs.Bool("json", false, "напечатать отчёт в JSON")
+	fs.Parse(args)
+
+	var report reconcile.Report
+	if e.useDB {
+		store, err := e.postgres(ctx)
+		if err != nil {
+			return err
+		}
+		if report, err = reconcile.Run(ctx, store, *limit); err != nil {
+			return err
+		}
+	} else if err := e.api.call(ctx, http.MethodGet, "/reports/reconciliation?limit="+strconv.Itoa(*limit), nil, &report); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		if err := printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		for _, o := range report.Orders {
+			fmt.Println(o.OrderUID)
+			for _, issue := range o.Issues {
+				fmt.Printf("  %s: %s (ожидалось %d, получено %d)\n", issue.Rule, issue.Detail, issue.Expected, issue.Actual)
+			}
+		}
+		fmt.Printf("Проверено заказов: %d, с расхождениями: %d\n", report.Checked, report.Inconsistent)
+	}
+	if report.Inconsistent > 0 {
+		return errors.New("есть заказы с расхождениями")
+	}
+	return nil
+}
+
+func runReplay(ctx context.Context, e *env, args []string) error {
+	fs := newFlags("replay")
+	partitions := fs.String("partitions", "", "разделы через запятую, пусто - все")
+	fromOffsets := fs.String("from-offsets", "", "начальные смещения: раздел=смещение,...")
+	toOffsets := fs.String("to-offsets", "", "конечные смещения (не включительно): раздел=смещение,...")
+	fromTime := fs.String("from-time", "", "начало диапазона, RFC 3339")
+	toTime := fs.String("to-time", "", "конец диапазона, RFC 3339")
+	resume := fs.String("resume", "", "id прерванной задачи: продолжить с закоммиченных смещений её группы")
+	dryRun := fs.Bool("dry-run", false, "только разобрать сообщения, ничего не сохраняя")
+	poll := fs.Duration("poll", 2*time.Second, "интервал опроса хода выполнения")
+	fs.Parse(args)
+
+	req := kafka.ReplayRequest{Resume: *resume, DryRun: *dryRun}
+	var err error
+	if req.Partitions, err = parseInts(*partitions); err != nil {
+		return fmt.Errorf("некорректный -partitions: %w", err)
+	}
+	if req.FromOffsets, err = parseOffsets(*fromOffsets); err != nil {
+		return fmt.Errorf("некорректный -from-offsets: %w", err)
+	}
+	if req.ToOffsets, err = parseOffsets(*toOffsets); err != nil {
+		return fmt.Errorf("некорректный -to-offsets: %w", err)
+	}
+	if req.FromTime, err = parseTime(*fromTime); err != nil {
+		return fmt.Errorf("некорректный -from-time: %w", err)
+	}
+	if req.ToTime, err = parseTime(*toTime); err != nil {
+		return fmt.Errorf("некорректный -to-time: %w", err)
+	}
+
+	var progress kafka.ReplayProgress
+	if err := e.api.call(ctx, http.MethodPost, "/admin/replay", req, &progress); err != nil {
+		return fmt.Errorf("не удалось запустить переобработку: %w", err)
+	}
+	fmt.Printf("Задача %s: %d сообщений, dry_run=%v\n", progress.ID, progress.Total, progress.DryRun)
+	if progress.Group != "" {
+		fmt.Printf("Ход коммитится в группу %s\n", progress.Group)
+	}
+
+	for progress.State == kafka.ReplayRunning {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(*poll):
+		}
+		if err := e.api.call(ctx, http.MethodGet, "/admin/replay/"+url.PathEscape(progress.ID), nil, &progress); err != nil {
+			return fmt.Errorf("не удалось получить состояние: %w", err)
+		}
+		fmt.Printf("%s: обработано %d из %d, ошибок %d\n", progress.State, progress.Processed, progress.Total, progress.Failed)
+	}
+
+	for _, p := range progress.Partitions {
+		fmt.Printf("  раздел %d: [%d, %d) дочитан до %d, обработано %d, ошибок %d\n",
+			p.Partition, p.From, p.To, p.Current, p.Processed, p.Failed)
+	}
+	if progress.State != kafka.ReplayDone {
+		return fmt.Errorf("задача завершилась в состоянии %s: %s", progress.State, progress.Error)
+	}
+	return nil
+}
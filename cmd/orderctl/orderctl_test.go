@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"demo-service/internal/infrastructure/postgres"
+	"demo-service/internal/model"
+)
+
+// fakeOrdersAPI отдаёт заказы по GET /orders с тем же курсором, что и сервис
+func fakeOrdersAPI(t *testing.T, orders []*model.Order) *httptest.Server {
+	sort.Slice(orders, func(i, j int) bool { return orders[i].OrderUID < orders[j].OrderUID })
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/orders" {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"title": "Not Found", "detail": "заказ не найден"}`))
+			return
+		}
+		q := r.URL.Query()
+		size, _ := strconv.Atoi(q.Get("page_size"))
+		after, err := base64.RawURLEncoding.DecodeString(q.Get("page_token"))
+		if err != nil {
+			t.Errorf("некорректный page_token %q", q.Get("page_token"))
+		}
+		var page []*model.Order
+		for _, o := range orders {
+			if o.OrderUID > string(after) && len(page) < size {
+				page = append(page, o)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"orders": page})
+	}))
+}
+
+func TestSearch_AcrossPages(t *testing.T) {
+	var orders []*model.Order
+	for i := range scanPageSize*2 + 10 {
+		o := &model.Order{OrderUID: "uid-" + strconv.Itoa(10000+i), Status: model.StatusCreated}
+		o.DateCreated = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(i) * time.Hour)
+		if i%100 == 7 {
+			o.Status = model.StatusPaid
+			o.Delivery.Email = "Buyer@Example.com"
+		}
+		orders = append(orders, o)
+	}
+	srv := fakeOrdersAPI(t, orders)
+	defer srv.Close()
+
+	f := searchFilter{status: string(model.StatusPaid), text: "buyer@example"}
+	var found []string
+	err := eachOrder(context.Background(), newAPIClient(srv.URL, ""), postgres.ListFilter{}, func(o *model.Order) bool {
+		if f.match(o) {
+			found = append(found, o.OrderUID)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Ошибка обхода: %v", err)
+	}
+	if len(found) != 11 || found[0] != "uid-10007" || found[10] != "uid-11007" {
+		t.Errorf("найдено %v", found)
+	}
+
+	f = searchFilter{from: orders[5].DateCreated, to: orders[8].DateCreated}
+	n := 0
+	for _, o := range orders {
+		if f.match(o) {
+			n++
+		}
+	}
+	if n != 3 {
+		t.Errorf("по датам [from, to) ожидалось 3 заказа, найдено %d", n)
+	}
+}
+
+func TestAPIClient_NotFound(t *testing.T) {
+	srv := fakeOrdersAPI(t, nil)
+	defer srv.Close()
+	_, err := newAPIClient(srv.URL, "").GetOrder(context.Background(), "missing")
+	if err != postgres.ErrNotFound {
+		t.Errorf("ожидалась postgres.ErrNotFound, получено %v", err)
+	}
+}
+
+func TestReadOrders(t *testing.T) {
+	for name, data := range map[string]string{
+		"объект": `{"order_uid": "a"}`,
+		"массив": ` [{"order_uid": "a"}, {"order_uid": "b"}]`,
+		"NDJSON": "{\"order_uid\": \"a\"}\n{\"order_uid\": \"b\"}\n",
+	} {
+		var uids []string
+		err := readOrders(strings.NewReader(data), func(o *model.Order) error {
+			uids = append(uids, o.OrderUID)
+			return nil
+		})
+		if err != nil || len(uids) == 0 || uids[0] != "a" {
+			t.Errorf("%s: uids=%v err=%v", name, uids, err)
+		}
+	}
+
+	err := readOrders(strings.NewReader(`{"order_uid": "a"} {"track_number": "x"}`), func(*model.Order) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "запись 2") {
+		t.Errorf("заказ без order_uid: ожидалась ошибка с номером записи, получено %v", err)
+	}
+}
@@ -0,0 +1,550 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"demo-service/internal/backfill"
+	"demo-service/internal/codec"
+	"demo-service/internal/export"
+	"demo-service/internal/infrastructure/kafka"
+	"demo-service/internal/infrastructure/postgres"
+	"demo-service/internal/model"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+const (
+	// размер страницы при обходе всех заказов
+	scanPageSize = 500
+	// сообщений в пачке при загрузке через Kafka
+	importBatch = 100
+)
+
+func runGet(ctx context.Context, e *env, args []string) error {
+	fs := newFlags("get")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("не указан order_uid")
+	}
+	store, err := e.orders(ctx)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	missing := 0
+	for _, uid := range fs.Args() {
+		o, err := store.GetOrder(ctx, uid)
+		if errors.Is(err, postgres.ErrNotFound) {
+			fmt.Fprintf(os.Stderr, "Заказ %s не найден\n", uid)
+			missing++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		enc.Encode(o)
+	}
+	if missing > 0 {
+		return fmt.Errorf("не найдено заказов: %d", missing)
+	}
+	return nil
+}
+
+func runList(ctx context.Context, e *env, args []string) error {
+	fs := newFlags("list")
+	customer := fs.String("customer", "", "только заказы покупателя")
+	service := fs.String("service", "", "только заказы службы доставки")
+	after := fs.String("after", "", "заказы с order_uid больше этого, для следующей страницы")
+	limit := fs.Int("limit", 50, "сколько заказов показать")
+	asJSON := fs.Bool("json", false, "печатать заказы в NDJSON")
+	fs.Parse(args)
+	if *limit <= 0 {
+		return errors.New("-limit должен быть положительным")
+	}
+
+	store, err := e.orders(ctx)
+	if err != nil {
+		return err
+	}
+	orders, err := store.ListOrders(ctx, postgres.ListFilter{
+		CustomerID:      *customer,
+		DeliveryService: *service,
+		After:           *after,
+		Limit:           *limit,
+	})
+	if err != nil {
+		return err
+	}
+	return printOrders(orders, *asJSON)
+}
+
+// searchFilter - условия поиска, пустые поля не ограничивают
+type searchFilter struct {
+	status string
+	track  string
+	// подстрока в данных получателя: имени, телефоне, email, городе или адресе
+	text     string
+	from, to time.Time
+}
+
+func (f searchFilter) match(o *model.Order) bool {
+	switch {
+	case f.status != "" && string(o.Status) != f.status:
+		return false
+	case f.track != "" && !strings.EqualFold(o.TrackNumber, f.track):
+		return false
+	case !f.from.IsZero() && o.DateCreated.Before(f.from):
+		return false
+	case !f.to.IsZero() && !o.DateCreated.Before(f.to):
+		return false
+	}
+	if f.text == "" {
+		return true
+	}
+	text := strings.ToLower(f.text)
+	d := o.Delivery
+	for _, field := range []string{d.Name, d.Phone, d.Email, d.City, d.Address, o.OrderUID} {
+		if strings.Contains(strings.ToLower(field), text) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDate принимает дату 2006-01-02 или время RFC 3339
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func runSearch(ctx context.Context, e *env, args []string) error {
+	fs := newFlags("search")
+	customer := fs.String("customer", "", "покупатель")
+	service := fs.String("service", "", "служба доставки")
+	status := fs.String("status", "", "статус заказа")
+	track := fs.String("track", "", "трек-номер")
+	text := fs.String("text", "", "подстрока в имени, телефоне, email, городе, адресе получателя или order_uid")
+	from := fs.String("from", "", "созданные не раньше: 2006-01-02 или RFC 3339")
+	to := fs.String("to", "", "созданные раньше: 2006-01-02 или RFC 3339")
+	limit := fs.Int("limit", 50, "сколько заказов показать, 0 - все")
+	asJSON := fs.Bool("json", false, "печатать заказы в NDJSON")
+	fs.Parse(args)
+
+	f := searchFilter{status: *status, track: *track, text: *text}
+	var err error
+	if f.from, err = parseDate(*from); err != nil {
+		return fmt.Errorf("некорректный -from: %w", err)
+	}
+	if f.to, err = parseDate(*to); err != nil {
+		return fmt.Errorf("некорректный -to: %w", err)
+	}
+	if f.status != "" && !model.Status(f.status).Valid() {
+		return fmt.Errorf("неизвестный статус %q", f.status)
+	}
+
+	store, err := e.orders(ctx)
+	if err != nil {
+		return err
+	}
+	// customer_id и служба доставки фильтруются хранилищем, остальное - здесь
+	var found []*model.Order
+	err = eachOrder(ctx, store, postgres.ListFilter{CustomerID: *customer, DeliveryService: *service}, func(o *model.Order) bool {
+		if f.match(o) {
+			found = append(found, o)
+		}
+		return *limit <= 0 || len(found) < *limit
+	})
+	if err != nil {
+		return err
+	}
+	return printOrders(found, *asJSON)
+}
+
+// eachOrder обходит заказы постранично, пока fn возвращает true
+func eachOrder(ctx context.Context, store orderStore, f postgres.ListFilter, fn func(*model.Order) bool) error {
+	f.Limit = scanPageSize
+	for {
+		page, err := store.ListOrders(ctx, f)
+		if err != nil {
+			return err
+		}
+		for _, o := range page {
+			if !fn(o) {
+				return nil
+			}
+		}
+		if len(page) < f.Limit {
+			return nil
+		}
+		f.After = page[len(page)-1].OrderUID
+	}
+}
+
+func printOrders(orders []*model.Order, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, o := range orders {
+			if err := enc.Encode(o); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ORDER_UID\tСОЗДАН\tПОКУПАТЕЛЬ\tДОСТАВКА\tСТАТУС\tТОВАРОВ\tСУММА")
+	for _, o := range orders {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%d %s\n", o.OrderUID, o.DateCreated.Format(time.DateTime),
+			o.CustomerID, o.DeliveryService, o.Status, len(o.Items), o.Payment.Amount, o.Payment.Currency)
+	}
+	return tw.Flush()
+}
+
+func runExport(ctx context.Context, e *env, args []string) error {
+	fs := newFlags("export")
+	customer := fs.String("customer", "", "только заказы покупателя")
+	service := fs.String("service", "", "только заказы службы доставки")
+	status := fs.String("status", "", "только заказы в статусе")
+	from := fs.String("from", "", "созданные не раньше: 2006-01-02 или RFC 3339")
+	to := fs.String("to", "", "созданные раньше: 2006-01-02 или RFC 3339")
+	formatName := fs.String("format", "ndjson", "формат: ndjson, csv или xlsx")
+	rows := fs.String("rows", "order", "csv и xlsx: строка на заказ (order) или на товар (item)")
+	pii := fs.String("pii", "masked", "данные доставки: masked или full (нужен токен администратора)")
+	out := fs.String("o", "-", "файл для выгрузки, - - stdout")
+	fs.Parse(args)
+
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+	if *rows != "order" && *rows != "item" {
+		return errors.New("-rows: ожидалось order или item")
+	}
+	if *pii != "masked" && *pii != "full" {
+		return errors.New("-pii: ожидалось masked или full")
+	}
+	f := postgres.ListFilter{CustomerID: *customer, DeliveryService: *service, Status: model.Status(*status)}
+	if f.Status != "" && !f.Status.Valid() {
+		return fmt.Errorf("неизвестный статус %q", f.Status)
+	}
+	if f.From, err = parseDate(*from); err != nil {
+		return fmt.Errorf("некорректный -from: %w", err)
+	}
+	if f.To, err = parseDate(*to); err != nil {
+		return fmt.Errorf("некорректный -to: %w", err)
+	}
+
+	var w io.Writer = os.Stdout
+	var file *os.File
+	if *out != "-" {
+		if file, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	bw := bufio.NewWriter(w)
+
+	if e.useDB {
+		err = exportFromDB(ctx, e, bw, f, export.Options{Format: format, PerItem: *rows == "item", Unmasked: *pii == "full"})
+	} else {
+		// выгрузку собирает сервис: маскирование и аудит те же, что у GET /orders/export
+		q := url.Values{"format": {string(format)}, "rows": {*rows}, "pii": {*pii}}
+		for k, v := range map[string]string{"customer_id": *customer, "delivery_service": *service, "status": *status} {
+			if v != "" {
+				q.Set(k, v)
+			}
+		}
+		if !f.From.IsZero() {
+			q.Set("from", f.From.Format(time.RFC3339))
+		}
+		if !f.To.IsZero() {
+			q.Set("to", f.To.Format(time.RFC3339))
+		}
+		var size int64
+		if size, err = e.api.download(ctx, "/orders/export?"+q.Encode(), bw); err == nil {
+			fmt.Fprintf(os.Stderr, "Выгружено байт: %d\n", size)
+		}
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil && file != nil {
+		err = file.Close()
+	}
+	return err
+}
+
+// exportFromDB выгружает заказы прямо из postgres
+func exportFromDB(ctx context.Context, e *env, w io.Writer, f postgres.ListFilter, opts export.Options) error {
+	store, err := e.postgres(ctx)
+	if err != nil {
+		return err
+	}
+	enc, err := export.NewEncoder(w, opts)
+	if err != nil {
+		return err
+	}
+	n := 0
+	var encErr error
+	err = eachOrder(ctx, store, f, func(o *model.Order) bool {
+		if encErr = enc.Encode(o); encErr != nil {
+			return false
+		}
+		n++
+		return true
+	})
+	if err == nil {
+		err = encErr
+	}
+	if err == nil {
+		err = enc.Close()
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Выгружено заказов: %d\n", n)
+	return nil
+}
+
+// readOrders читает заказы из JSON-объекта, массива объектов или NDJSON
+func readOrders(r io.Reader, fn func(*model.Order) error) error {
+	br := bufio.NewReader(r)
+	dec := json.NewDecoder(br)
+	// массив верхнего уровня читается по элементам, а не целиком
+	inArray := false
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if c := b[0]; c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			br.ReadByte()
+			continue
+		}
+		if b[0] == '[' {
+			if _, err := dec.Token(); err != nil {
+				return err
+			}
+			inArray = true
+		}
+		break
+	}
+
+	for n := 1; dec.More(); n++ {
+		var o model.Order
+		if err := dec.Decode(&o); err != nil {
+			return fmt.Errorf("запись %d: %w", n, err)
+		}
+		if o.OrderUID == "" {
+			return fmt.Errorf("запись %d: нет order_uid", n)
+		}
+		if err := fn(&o); err != nil {
+			return fmt.Errorf("заказ %s: %w", o.OrderUID, err)
+		}
+	}
+	if inArray {
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runImport(ctx context.Context, e *env, args []string) error {
+	fs := newFlags("import")
+	dryRun := fs.Bool("dry-run", false, "только прочитать и проверить файлы")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("не указан файл, - - stdin")
+	}
+
+	var save func(*model.Order) error
+	flush := func() error { return nil }
+	switch {
+	case *dryRun:
+		save = func(*model.Order) error { return nil }
+	case e.useDB:
+		store, err := e.postgres(ctx)
+		if err != nil {
+			return err
+		}
+		save = func(o *model.Order) error { return store.SaveOrder(o, nil) }
+	default:
+		// через топик заказов: сервис проверит заказы, обновит кеш и поток
+		w, err := kafka.NewWriter(e.cfg.Kafka, e.cfg.Kafka.Topic)
+		if err != nil {
+			return fmt.Errorf("настройки Kafka: %w", err)
+		}
+		defer w.Close()
+		// writer ждёт пачку до BatchTimeout, поэтому сообщения отправляются пачками
+		var batch []kafkago.Message
+		flush = func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			err := w.WriteMessages(ctx, batch...)
+			batch = batch[:0]
+			return err
+		}
+		save = func(o *model.Order) error {
+			data, err := json.Marshal(o)
+			if err != nil {
+				return err
+			}
+			batch = append(batch, kafkago.Message{
+				Key:     []byte(o.OrderUID),
+				Value:   data,
+				Headers: []kafkago.Header{{Key: codec.HeaderContentType, Value: []byte(codec.JSON.ContentType())}},
+			})
+			if len(batch) >= importBatch {
+				return flush()
+			}
+			return nil
+		}
+	}
+
+	n := 0
+	for _, name := range fs.Args() {
+		r := io.Reader(os.Stdin)
+		if name != "-" {
+			f, err := os.Open(name)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		err := readOrders(r, func(o *model.Order) error {
+			if err := save(o); err != nil {
+				return err
+			}
+			n++
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w (загружено до ошибки: %d)", name, err, n)
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	switch {
+	case *dryRun:
+		fmt.Printf("Проверено заказов: %d\n", n)
+	case e.useDB:
+		fmt.Printf("Записано в бд заказов: %d. Кеш работающего сервиса не знает о них: orderctl cache reload\n", n)
+	default:
+		fmt.Printf("Отправлено в топик %s заказов: %d\n", e.cfg.Kafka.Topic, n)
+	}
+	return nil
+}
+
+func runBackfill(ctx context.Context, e *env, args []string) error {
+	fs := newFlags("backfill")
+	formatName := fs.String("format", "", "ndjson или csv, по умолчанию - по расширению файла")
+	batch := fs.Int("batch", backfill.DefaultBatch, "заказов в пачке")
+	conflict := fs.String("conflict", "skip", "заказ уже есть в бд: skip, overwrite или fail")
+	rejectsName := fs.String("rejects", "rejects.ndjson", "файл отклонённых записей, дописывается")
+	checkpoint := fs.String("checkpoint", "backfill.checkpoint.json", "файл контрольной точки, пустой - загрузка не продолжается после прерывания")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("не указан файл")
+	}
+	policy, err := postgres.ParseConflictPolicy(*conflict)
+	if err != nil {
+		return err
+	}
+	if *formatName != "" && *formatName != "ndjson" && *formatName != "csv" {
+		return errors.New("-format: ожидалось ndjson или csv")
+	}
+
+	store, err := e.postgres(ctx)
+	if err != nil {
+		return err
+	}
+	rejects, err := os.OpenFile(*rejectsName, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer rejects.Close()
+	im, err := backfill.New(store, backfill.Options{Batch: *batch, Conflict: policy, Rejects: rejects, Checkpoint: *checkpoint})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range fs.Args() {
+		if err := backfillFile(ctx, im, name, *formatName); err != nil {
+			st := im.Stats()
+			return fmt.Errorf("%w (до ошибки добавлено %d, обновлено %d; повторите команду, чтобы продолжить)", err, st.Inserted, st.Updated)
+		}
+	}
+	if *checkpoint != "" {
+		// все файлы загружены, продолжать нечего
+		os.Remove(*checkpoint)
+	}
+
+	st := im.Stats()
+	fmt.Printf("Прочитано заказов: %d, добавлено: %d, обновлено: %d, пропущено: %d, отклонено: %d\n",
+		st.Read, st.Inserted, st.Updated, st.Skipped, st.Rejected)
+	if st.Rejected > 0 {
+		fmt.Printf("Отклонённые записи: %s\n", *rejectsName)
+	}
+	fmt.Println("Кеш работающего сервиса не знает о новых заказах: orderctl cache reload")
+	return nil
+}
+
+// backfillFile загружает один файл, формат без -format берётся по расширению
+func backfillFile(ctx context.Context, im *backfill.Importer, name, format string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if format == "" {
+		format = "ndjson"
+		if strings.EqualFold(filepath.Ext(name), ".csv") {
+			format = "csv"
+		}
+	}
+	var r backfill.Reader
+	if format == "csv" {
+		if r, err = backfill.NewCSVReader(f); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	} else {
+		r = backfill.NewNDJSONReader(f)
+	}
+	return im.Import(ctx, backfill.Source{
+		Name:    name,
+		Version: fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano()),
+		Reader:  r,
+	})
+}
@@ -0,0 +1,9 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- заказы, вынесенные из основных таблиц заданием хранения
+CREATE TABLE IF NOT EXISTS orders_archive (
+    order_uid VARCHAR(50) PRIMARY KEY,
+    data JSONB NOT NULL,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    archived_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
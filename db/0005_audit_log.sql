@@ -0,0 +1,27 @@
+-- журнал аудита: каждая запись хранит хеш предыдущей, правка записи рвёт цепочку
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    at TIMESTAMP WITH TIME ZONE NOT NULL,
+    actor VARCHAR(100) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    order_uid VARCHAR(50),
+    customer_id VARCHAR(50),
+    fields TEXT[],
+    details TEXT,
+    prev_hash CHAR(64) NOT NULL,
+    hash CHAR(64) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_order_uid_idx ON audit_log (order_uid, id);
+CREATE INDEX IF NOT EXISTS audit_log_customer_id_idx ON audit_log (customer_id, id);
+
+-- журнал только дополняется
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log: изменение и удаление записей запрещены';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
@@ -0,0 +1,2 @@
+-- догрузка кеша после снимка читает заказы, изменённые с момента снимка
+CREATE INDEX IF NOT EXISTS orders_updated_at_idx ON orders (updated_at);
@@ -0,0 +1,38 @@
+-- отчёты /analytics выбирают заказы по времени создания
+CREATE INDEX IF NOT EXISTS orders_date_created_idx ON orders (date_created);
+
+-- дневные сводки для /analytics, сервис обновляет их раз в ANALYTICS_ROLLUP_INTERVAL.
+-- Сутки - по UTC, пустые значения заменены на '', чтобы ключ сводки был уникальным.
+CREATE MATERIALIZED VIEW IF NOT EXISTS analytics_orders_daily AS
+SELECT date_trunc('day', o.date_created AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' AS day,
+       COALESCE(p.currency, '') AS currency,
+       COALESCE(o.delivery_service, '') AS delivery_service,
+       COALESCE(d.region, '') AS region,
+       COALESCE(d.city, '') AS city,
+       count(*) AS orders,
+       COALESCE(sum(p.amount), 0) AS revenue,
+       sum(n.items) AS items
+FROM orders o
+LEFT JOIN payments p ON p.order_uid = o.order_uid
+LEFT JOIN deliveries d ON d.order_uid = o.order_uid
+CROSS JOIN LATERAL (SELECT count(*) AS items FROM items i WHERE i.order_uid = o.order_uid) n
+WHERE o.deleted_at IS NULL AND o.date_created IS NOT NULL
+GROUP BY 1, 2, 3, 4, 5;
+
+-- уникальный индекс нужен для REFRESH MATERIALIZED VIEW CONCURRENTLY
+CREATE UNIQUE INDEX IF NOT EXISTS analytics_orders_daily_key ON analytics_orders_daily (day, currency, delivery_service, region, city);
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS analytics_items_daily AS
+SELECT date_trunc('day', o.date_created AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' AS day,
+       COALESCE(p.currency, '') AS currency,
+       COALESCE(i.brand, '') AS brand,
+       COALESCE(i.nm_id, 0) AS nm_id,
+       count(*) AS quantity,
+       COALESCE(sum(i.total_price), 0) AS revenue
+FROM items i
+JOIN orders o ON o.order_uid = i.order_uid
+LEFT JOIN payments p ON p.order_uid = i.order_uid
+WHERE o.deleted_at IS NULL AND o.date_created IS NOT NULL
+GROUP BY 1, 2, 3, 4;
+
+CREATE UNIQUE INDEX IF NOT EXISTS analytics_items_daily_key ON analytics_items_daily (day, currency, brand, nm_id);
@@ -0,0 +1,5 @@
+-- покупатели, чьи данные стёрты: их заказы при повторной записи приходят без данных доставки
+CREATE TABLE IF NOT EXISTS erased_customers (
+    customer_id VARCHAR(50) PRIMARY KEY,
+    erased_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
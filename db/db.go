@@ -0,0 +1,41 @@
+// Package db встраивает схему бд в бинарники, чтобы её можно было применить
+// командой orderctl migrate без исходников.
+package db
+
+import (
+	_ "embed"
+
+	"demo-service/internal/infrastructure/postgres"
+)
+
+var (
+	//go:embed init.sql
+	initSQL string
+	//go:embed 0002_order_updated_at.sql
+	orderUpdatedAtSQL string
+	//go:embed 0003_order_status.sql
+	orderStatusSQL string
+	//go:embed 0004_soft_delete.sql
+	softDeleteSQL string
+	//go:embed 0005_audit_log.sql
+	auditLogSQL string
+	//go:embed 0006_orders_updated_at_idx.sql
+	ordersUpdatedAtIdxSQL string
+	//go:embed 0007_analytics.sql
+	analyticsSQL string
+	//go:embed 0008_erased_customers.sql
+	erasedCustomersSQL string
+)
+
+// Migrations - миграции схемы по порядку. Новая миграция добавляется в конец
+// отдельным файлом, init.sql после выпуска не меняется.
+var Migrations = []postgres.Migration{
+	{Version: "0001_init", SQL: initSQL},
+	{Version: "0002_order_updated_at", SQL: orderUpdatedAtSQL},
+	{Version: "0003_order_status", SQL: orderStatusSQL},
+	{Version: "0004_soft_delete", SQL: softDeleteSQL},
+	{Version: "0005_audit_log", SQL: auditLogSQL},
+	{Version: "0006_orders_updated_at_idx", SQL: ordersUpdatedAtIdxSQL},
+	{Version: "0007_analytics", SQL: analyticsSQL},
+	{Version: "0008_erased_customers", SQL: erasedCustomersSQL},
+}
@@ -0,0 +1,17 @@
+package db
+
+import "testing"
+
+func TestMigrationsOrdered(t *testing.T) {
+	if len(Migrations) == 0 || Migrations[0].Version != "0001_init" {
+		t.Fatalf("первая миграция должна быть 0001_init")
+	}
+	for i, m := range Migrations {
+		if m.SQL == "" {
+			t.Errorf("миграция %s пустая", m.Version)
+		}
+		if i > 0 && m.Version <= Migrations[i-1].Version {
+			t.Errorf("миграция %s идёт после %s", m.Version, Migrations[i-1].Version)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+// Package audit записывает чтения и изменения данных заказов в журнал,
+// защищённый цепочкой хешей: каждая запись содержит хеш предыдущей,
+// поэтому удаление или правка записи обнаруживается проверкой Verify.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Действия, которые попадают в журнал
+const (
+	ActionOrderRead      = "order.read"
+	ActionOrderStream    = "order.stream"
+	ActionOrderExport    = "order.export"
+	ActionOrderWrite     = "order.write"
+	ActionStatusChange   = "order.status"
+	ActionOrderDelete    = "order.delete"
+	ActionCustomerErase  = "customer.erase"
+	ActionRetention      = "retention.expire"
+	ActionReplayStart    = "replay.start"
+	ActionReplayCancel   = "replay.cancel"
+	ActionCacheEvict     = "cache.evict"
+	ActionCacheReload    = "cache.reload"
+	ActionConsumerPause  = "consumer.pause"
+	ActionConsumerResume = "consumer.resume"
+	ActionConsumerSeek   = "consumer.seek"
+)
+
+// Event - запись журнала аудита
+type Event struct {
+	// порядковый номер, назначается хранилищем
+	ID         int64     `json:"id"`
+	Time       time.Time `json:"time"`
+	Actor      string    `json:"actor"`
+	Action     string    `json:"action"`
+	OrderUID   string    `json:"order_uid,omitempty"`
+	CustomerID string    `json:"customer_id,omitempty"`
+	// поля заказа, отданные при чтении
+	Fields   []string `json:"fields,omitempty"`
+	Details  string   `json:"details,omitempty"`
+	PrevHash string   `json:"prev_hash"`
+	Hash     string   `json:"hash"`
+}
+
+// Seal связывает событие с предыдущей записью журнала. Вызывается
+// хранилищем под блокировкой, чтобы цепочка не ветвилась.
+func (e *Event) Seal(prevHash string) {
+	e.PrevHash = prevHash
+	e.Hash = e.computeHash()
+}
+
+// хеш считается по всем полям, кроме ID и самого хеша
+func (e *Event) computeHash() string {
+	data, _ := json.Marshal(struct {
+		Time       string   `json:"time"`
+		Actor      string   `json:"actor"`
+		Action     string   `json:"action"`
+		OrderUID   string   `json:"order_uid"`
+		CustomerID string   `json:"customer_id"`
+		Fields     []string `json:"fields"`
+		Details    string   `json:"details"`
+		PrevHash   string   `json:"prev_hash"`
+	}{
+		Time:       e.Time.UTC().Format(time.RFC3339Nano),
+		Actor:      e.Actor,
+		Action:     e.Action,
+		OrderUID:   e.OrderUID,
+		CustomerID: e.CustomerID,
+		Fields:     e.Fields,
+		Details:    e.Details,
+		PrevHash:   e.PrevHash,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Query - условия выборки журнала, пустые поля не ограничивают
+type Query struct {
+	OrderUID   string
+	CustomerID string
+	Actor      string
+	Action     string
+	From       time.Time
+	To         time.Time
+	// записи с ID строго больше AfterID, по возрастанию ID
+	AfterID int64
+	Limit   int
+}
+
+// Sink - хранилище журнала. Записи только добавляются: AppendAudit пишет
+// пачку целиком или не пишет ничего и сам запечатывает события по цепочке
+// от последней записи.
+type Sink interface {
+	AppendAudit(ctx context.Context, events []Event) error
+	QueryAudit(ctx context.Context, q Query) ([]Event, error)
+}
+
+const (
+	// размер очереди записи: чтения не ждут хранилище, пока очередь не заполнена
+	queueSize = 1024
+	// не больше стольких событий пишется за одно обращение к хранилищу
+	maxBatch = 256
+	// пауза перед первым повтором записи, дальше удваивается до maxRetryBackoff
+	retryBackoff    = 100 * time.Millisecond
+	maxRetryBackoff = 30 * time.Second
+	// сколько повторять запись после Close, прежде чем сдаться
+	closeTimeout = 30 * time.Second
+)
+
+// Auditor пишет события в Sink в фоне, в порядке вызова Record. Nil Auditor
+// ничего не делает, так компоненты работают и без настроенного аудита.
+type Auditor struct {
+	sink    Sink
+	queue   chan Event
+	done    chan struct{}
+	closing atomic.Bool
+
+	// mu защищает очередь от закрытия, пока Record в неё пишет
+	mu     sync.RWMutex
+	closed bool
+}
+
+func New(sink Sink) *Auditor {
+	a := &Auditor{sink: sink, queue: make(chan Event, queueSize), done: make(chan struct{})}
+	go a.run()
+	return a
+}
+
+// Record ставит событие в очередь записи. При заполненной очереди ждёт,
+// пока не отменят ctx: терять записи аудита нельзя, но и запрос, который
+// уже отменён, ждать не должен. После Close событие не пишется.
+func (a *Auditor) Record(ctx context.Context, e Event) {
+	if a == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		// postgres хранит микросекунды, хеш должен совпасть после чтения
+		e.Time = time.Now().UTC().Truncate(time.Microsecond)
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		log.Printf("Событие аудита после остановки не записано: %s %s %s", e.Actor, e.Action, e.OrderUID)
+		return
+	}
+	select {
+	case a.queue <- e:
+		return
+	default:
+	}
+	select {
+	case a.queue <- e:
+	case <-ctx.Done():
+		log.Printf("Событие аудита не записано, очередь заполнена: %s %s %s: %v", e.Actor, e.Action, e.OrderUID, ctx.Err())
+	}
+}
+
+// run забирает из очереди всё накопившееся, не больше maxBatch, и пишет
+// одной пачкой
+func (a *Auditor) run() {
+	defer close(a.done)
+	batch := make([]Event, 0, maxBatch)
+	for e := range a.queue {
+		batch = append(batch[:0], e)
+	fill:
+		for len(batch) < maxBatch {
+			select {
+			case e, ok := <-a.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, e)
+			default:
+				break fill
+			}
+		}
+		a.write(batch)
+	}
+}
+
+// write повторяет запись пачки с нарастающей паузой, пока она не удастся.
+// Очередь в это время не разбирается, и Record при заполненной очереди ждёт,
+// так что события не теряются. После Close повторы ограничены closeTimeout.
+func (a *Auditor) write(batch []Event) {
+	backoff := retryBackoff
+	var deadline time.Time
+	for {
+		err := a.sink.AppendAudit(context.Background(), batch)
+		if err == nil {
+			return
+		}
+		if a.closing.Load() {
+			if deadline.IsZero() {
+				deadline = time.Now().Add(closeTimeout)
+			} else if time.Now().After(deadline) {
+				log.Printf("Журнал аудита недоступен при остановке, не записано событий: %d: %v", len(batch)+len(a.queue), err)
+				return
+			}
+		}
+		log.Printf("Ошибка записи аудита (%d событий), повтор через %s: %v", len(batch), backoff, err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// Close дописывает очередь и останавливает запись. Record после Close
+// ничего не пишет.
+func (a *Auditor) Close() {
+	if a == nil {
+		return
+	}
+	// сначала ограничиваем повторы: Record, ждущий места в очереди, держит mu
+	a.closing.Store(true)
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+	<-a.done
+}
+
+// Query читает журнал
+func (a *Auditor) Query(ctx context.Context, q Query) ([]Event, error) {
+	return a.sink.QueryAudit(ctx, q)
+}
+
+// VerifyResult - итог проверки цепочки
+type VerifyResult struct {
+	OK      bool  `json:"ok"`
+	Checked int64 `json:"checked"`
+	// первая запись, на которой цепочка нарушена
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+const verifyPage = 1000
+
+// Verify проходит весь журнал и проверяет хеши и связи между записями
+func (a *Auditor) Verify(ctx context.Context) (VerifyResult, error) {
+	var res VerifyResult
+	prev := ""
+	q := Query{Limit: verifyPage}
+	for {
+		page, err := a.sink.QueryAudit(ctx, q)
+		if err != nil {
+			return VerifyResult{}, err
+		}
+		for _, e := range page {
+			res.Checked++
+			switch {
+			case e.PrevHash != prev:
+				res.BrokenAt, res.Reason = e.ID, "prev_hash не совпадает с хешем предыдущей записи"
+				return res, nil
+			case e.Hash != e.computeHash():
+				res.BrokenAt, res.Reason = e.ID, "хеш не совпадает с содержимым записи"
+				return res, nil
+			}
+			prev = e.Hash
+		}
+		if len(page) < q.Limit {
+			res.OK = true
+			return res, nil
+		}
+		q.AfterID = page[len(page)-1].ID
+	}
+}
+
+// FieldsOf возвращает имена JSON-полей верхнего уровня структуры v,
+// ими в журнале описывается, какие данные отданы при чтении
+func FieldsOf(v any) []string {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var out []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		out = append(out, name)
+	}
+	return out
+}
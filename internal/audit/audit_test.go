@@ -0,0 +1,226 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileSink_Chain(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	sink, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New(sink)
+	a.Record(ctx, Event{Actor: "anonymous:web@10.0.0.1", Action: ActionOrderRead, OrderUID: "order-1", Fields: []string{"order_uid"}})
+	a.Record(ctx, Event{Actor: "kafka:orders", Action: ActionOrderWrite, OrderUID: "order-2"})
+	a.Close()
+	sink.Close()
+
+	// после переоткрытия цепочка продолжается с последней записи
+	sink, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	a = New(sink)
+	a.Record(ctx, Event{Actor: "admin@127.0.0.1", Action: ActionOrderDelete, OrderUID: "order-1"})
+	a.Close()
+
+	res, err := a.Verify(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.OK || res.Checked != 3 {
+		t.Fatalf("цепочка должна быть целой: %+v", res)
+	}
+
+	events, err := a.Query(ctx, Query{OrderUID: "order-1", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].ID != 1 || events[1].ID != 3 {
+		t.Fatalf("неверная выборка по заказу: %+v", events)
+	}
+
+	// правка записи задним числом обнаруживается
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(data, []byte(`"order-2"`), []byte(`"order-9"`), 1)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	res, err = a.Verify(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OK || res.BrokenAt != 2 {
+		t.Errorf("ожидалось нарушение цепочки на записи 2: %+v", res)
+	}
+}
+
+func TestFileSink_TornLine(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	sink, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.AppendAudit(ctx, []Event{{Actor: "admin", Action: ActionOrderRead, OrderUID: "order-1"}}); err != nil {
+		t.Fatal(err)
+	}
+	sink.Close()
+
+	// сбой посреди записи оставил недописанную строку
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":2,"actor":"adm`)
+	f.Close()
+
+	sink, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.AppendAudit(ctx, []Event{{Actor: "admin", Action: ActionOrderRead, OrderUID: "order-2"}}); err != nil {
+		t.Fatal(err)
+	}
+	sink.Close()
+
+	// после следующего открытия журнал читается целиком
+	sink, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	a := New(sink)
+	defer a.Close()
+	res, err := a.Verify(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.OK || res.Checked != 2 {
+		t.Errorf("ожидалось 2 целые записи: %+v", res)
+	}
+}
+
+// flakySink отказывает fail первых записей и запоминает размеры пачек
+type flakySink struct {
+	mu      sync.Mutex
+	fail    int
+	batches []int
+	events  []Event
+}
+
+func (s *flakySink) AppendAudit(_ context.Context, events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail > 0 {
+		s.fail--
+		return errors.New("бд недоступна")
+	}
+	s.batches = append(s.batches, len(events))
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *flakySink) QueryAudit(context.Context, Query) ([]Event, error) { return nil, nil }
+
+func TestAuditor_RetriesAndBatches(t *testing.T) {
+	sink := &flakySink{fail: 2}
+	a := New(sink)
+	for i := range 10 {
+		a.Record(context.Background(), Event{Actor: "kafka:orders", Action: ActionOrderWrite, OrderUID: fmt.Sprint("order-", i)})
+	}
+	a.Close()
+
+	if len(sink.events) != 10 {
+		t.Fatalf("после ошибок хранилища записано %d событий из 10", len(sink.events))
+	}
+	for i, e := range sink.events {
+		if e.OrderUID != fmt.Sprint("order-", i) {
+			t.Fatalf("нарушен порядок записи: %d - %s", i, e.OrderUID)
+		}
+	}
+	if len(sink.batches) >= 10 {
+		t.Errorf("события не собраны в пачки: %v", sink.batches)
+	}
+}
+
+// blockSink держит первую запись, пока не закрыт release
+type blockSink struct {
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (s *blockSink) AppendAudit(context.Context, []Event) error {
+	s.once.Do(func() { close(s.started) })
+	<-s.release
+	return nil
+}
+
+func (s *blockSink) QueryAudit(context.Context, Query) ([]Event, error) { return nil, nil }
+
+func TestAuditor_RecordCancelAndClose(t *testing.T) {
+	sink := &blockSink{started: make(chan struct{}), release: make(chan struct{})}
+	a := New(sink)
+	a.Record(context.Background(), Event{Action: ActionOrderRead})
+	<-sink.started
+	for range queueSize {
+		a.Record(context.Background(), Event{Action: ActionOrderRead})
+	}
+
+	// очередь заполнена: отменённый запрос не должен ждать хранилище
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		a.Record(ctx, Event{Action: ActionOrderRead})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Record не вернулся после отмены контекста")
+	}
+
+	close(sink.release)
+	a.Close()
+	// после Close событие отбрасывается без паники
+	a.Record(context.Background(), Event{Action: ActionOrderRead})
+}
+
+func TestFieldsOf(t *testing.T) {
+	type sample struct {
+		UID     string `json:"uid"`
+		Comment string `json:"comment,omitempty"`
+		Secret  string `json:"-"`
+		Plain   int
+		hidden  int
+	}
+	got := FieldsOf(&sample{})
+	if want := []string{"uid", "comment", "Plain"}; !slices.Equal(got, want) {
+		t.Errorf("ожидалось %v, получено %v", want, got)
+	}
+}
+
+func TestNilAuditor(t *testing.T) {
+	var a *Auditor
+	a.Record(context.Background(), Event{Action: ActionOrderRead})
+	a.Close()
+}
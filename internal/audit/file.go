@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// FileSink хранит журнал в файле, по записи JSON в строке. Файл открывается
+// только на дозапись, поэтому процесс не может переписать старые записи.
+type FileSink struct {
+	path string
+
+	mu       sync.Mutex
+	f        *os.File
+	lastID   int64
+	lastHash string
+	// размер файла до конца последней целой записи
+	size int64
+}
+
+// OpenFile открывает журнал, продолжая цепочку последней записи файла.
+// Недописанная при сбое последняя строка обрезается, иначе следующая запись
+// склеилась бы с ней.
+func OpenFile(path string) (*FileSink, error) {
+	s := &FileSink{path: path}
+	size, err := s.scan(func(e Event) bool {
+		s.lastID, s.lastHash = e.ID, e.Hash
+		return true
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть журнал аудита: %w", err)
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("не удалось обрезать журнал аудита: %w", err)
+	}
+	s.f, s.size = f, size
+	return s, nil
+}
+
+// AppendAudit дописывает пачку одной записью в файл. При ошибке файл
+// обрезается до прежнего размера, чтобы повтор не дописал пачку к её части.
+func (s *FileSink) AppendAudit(_ context.Context, events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, hash := s.lastID, s.lastHash
+	var buf []byte
+	for i := range events {
+		e := &events[i]
+		id++
+		e.ID = id
+		e.Seal(hash)
+		hash = e.Hash
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+	_, err := s.f.Write(buf)
+	if err == nil {
+		err = s.f.Sync()
+	}
+	if err != nil {
+		if terr := s.f.Truncate(s.size); terr != nil {
+			err = errors.Join(err, terr)
+		}
+		return fmt.Errorf("ошибка записи журнала аудита: %w", err)
+	}
+	s.lastID, s.lastHash = id, hash
+	s.size += int64(len(buf))
+	return nil
+}
+
+func (s *FileSink) QueryAudit(_ context.Context, q Query) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := []Event{}
+	_, err := s.scan(func(e Event) bool {
+		if e.ID > q.AfterID && q.match(e) {
+			out = append(out, e)
+		}
+		return q.Limit <= 0 || len(out) < q.Limit
+	})
+	return out, err
+}
+
+func (s *FileSink) Close() error {
+	return s.f.Close()
+}
+
+// scan читает записи файла по порядку, пока fn возвращает true, и возвращает
+// размер прочитанных целых строк
+func (s *FileSink) scan(fn func(Event) bool) (int64, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var size int64
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// недописанная последняя строка - сбой при записи, не запись журнала
+			return size, nil
+		}
+		if err != nil {
+			return size, fmt.Errorf("ошибка чтения журнала аудита: %w", err)
+		}
+		var e Event
+		if err := json.Unmarshal(data, &e); err != nil {
+			return size, fmt.Errorf("журнал аудита, строка %d: %w", line, err)
+		}
+		size += int64(len(data))
+		if !fn(e) {
+			return size, nil
+		}
+	}
+}
+
+func (q Query) match(e Event) bool {
+	return (q.OrderUID == "" || e.OrderUID == q.OrderUID) &&
+		(q.CustomerID == "" || e.CustomerID == q.CustomerID) &&
+		(q.Actor == "" || strings.EqualFold(e.Actor, q.Actor)) &&
+		(q.Action == "" || e.Action == q.Action) &&
+		(q.From.IsZero() || !e.Time.Before(q.From)) &&
+		(q.To.IsZero() || e.Time.Before(q.To))
+}
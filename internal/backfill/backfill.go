@@ -0,0 +1,311 @@
+// Package backfill загружает исторические заказы из файлов NDJSON и CSV прямо
+// в postgres большими пачками, минуя Kafka. Заказы с ошибками не прерывают
+// загрузку, а пишутся в файл отклонённых; прерванная загрузка продолжается
+// с места, сохранённого в файле контрольной точки.
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"demo-service/internal/infrastructure/postgres"
+	"demo-service/internal/model"
+)
+
+const DefaultBatch = 1000
+
+// Store - операция хранилища, нужная загрузке
+type Store interface {
+	SaveOrders(ctx context.Context, orders []*model.Order, policy postgres.ConflictPolicy) (postgres.BatchResult, error)
+}
+
+// Options - настройки загрузки
+type Options struct {
+	// заказов в пачке, 0 - DefaultBatch
+	Batch int
+	// что делать с заказами, которые уже есть в бд, пустая - ConflictSkip
+	Conflict postgres.ConflictPolicy
+	// сюда пишутся отклонённые записи, по JSON-объекту Reject в строке
+	Rejects io.Writer
+	// файл контрольной точки, пустой - загрузка не продолжается после прерывания
+	Checkpoint string
+}
+
+// Stats - счётчики загрузки
+type Stats struct {
+	Read     int
+	Inserted int
+	Updated  int
+	Skipped  int
+	Rejected int
+}
+
+// Reject - отклонённая запись
+type Reject struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	OrderUID string `json:"order_uid,omitempty"`
+	Error    string `json:"error"`
+	Raw      string `json:"raw"`
+}
+
+// Source - файл загрузки. Version отличает содержимое файла: по ней
+// контрольная точка не применяется к файлу, изменившемуся после прерывания.
+type Source struct {
+	Name    string
+	Version string
+	Reader  Reader
+}
+
+// checkpoint - прогресс загрузки по файлам
+type checkpoint struct {
+	Files map[string]*fileState `json:"files"`
+}
+
+type fileState struct {
+	Version string `json:"version"`
+	// последняя строка файла, записанная в бд или в отклонённые
+	Line int  `json:"line"`
+	Done bool `json:"done,omitempty"`
+}
+
+// Importer загружает файлы по одному, общие счётчики - в Stats
+type Importer struct {
+	store Store
+	opts  Options
+	state checkpoint
+	stats Stats
+}
+
+// New читает контрольную точку opts.Checkpoint, если она есть
+func New(store Store, opts Options) (*Importer, error) {
+	if opts.Batch <= 0 {
+		opts.Batch = DefaultBatch
+	}
+	if opts.Conflict == "" {
+		opts.Conflict = postgres.ConflictSkip
+	}
+	im := &Importer{store: store, opts: opts, state: checkpoint{Files: make(map[string]*fileState)}}
+	if opts.Checkpoint == "" {
+		return im, nil
+	}
+	data, err := os.ReadFile(opts.Checkpoint)
+	if errors.Is(err, os.ErrNotExist) {
+		return im, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать контрольную точку: %w", err)
+	}
+	if err := json.Unmarshal(data, &im.state); err != nil {
+		return nil, fmt.Errorf("некорректная контрольная точка %s: %w", opts.Checkpoint, err)
+	}
+	if im.state.Files == nil {
+		im.state.Files = make(map[string]*fileState)
+	}
+	return im, nil
+}
+
+func (im *Importer) Stats() Stats {
+	return im.stats
+}
+
+// Import загружает файл. Записи до контрольной точки пропускаются, файл,
+// загруженный целиком, не читается. Контрольная точка сохраняется после
+// каждой пачки, поэтому при повторе после сбоя отклонённые записи последней
+// пачки могут попасть в файл отклонённых дважды.
+func (im *Importer) Import(ctx context.Context, src Source) error {
+	st := im.state.Files[src.Name]
+	if st == nil {
+		st = &fileState{Version: src.Version}
+		im.state.Files[src.Name] = st
+	}
+	if st.Version != src.Version {
+		return fmt.Errorf("%s изменился после прерванной загрузки, удалите контрольную точку %s", src.Name, im.opts.Checkpoint)
+	}
+	if st.Done {
+		return nil
+	}
+
+	var batch []Record
+	uids := make(map[string]bool)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := im.save(ctx, batch); err != nil {
+			return fmt.Errorf("%s, строки %d-%d: %w", src.Name, batch[0].Line, batch[len(batch)-1].End, err)
+		}
+		if err := im.reject(src.Name, batch); err != nil {
+			return err
+		}
+		st.Line = batch[len(batch)-1].End
+		batch = batch[:0]
+		clear(uids)
+		return im.saveCheckpoint()
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rec, err := src.Reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", src.Name, err)
+		}
+		if rec.End <= st.Line {
+			continue
+		}
+		im.stats.Read++
+		if rec.Err == nil {
+			rec.Err = validate(rec.Order)
+		}
+		// один заказ дважды в пачке записать нельзя, повтор уходит в следующую
+		if rec.Err == nil && uids[rec.Order.OrderUID] {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if rec.Err == nil {
+			uids[rec.Order.OrderUID] = true
+		}
+		batch = append(batch, rec)
+		if len(batch) >= im.opts.Batch {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	st.Done = true
+	return im.saveCheckpoint()
+}
+
+func validate(o *model.Order) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	if o.Status != "" && !o.Status.Valid() {
+		return fmt.Errorf("заказ %s: %w: %q", o.OrderUID, model.ErrUnknownStatus, o.Status)
+	}
+	return nil
+}
+
+// save пишет заказы пачки. Если бд не приняла пачку из-за некорректных
+// данных, заказы пишутся по одному, чтобы отклонить только плохие.
+// Отклонённые заказы получают Err.
+func (im *Importer) save(ctx context.Context, batch []Record) error {
+	var orders []*model.Order
+	for _, rec := range batch {
+		if rec.Err == nil {
+			orders = append(orders, rec.Order)
+		}
+	}
+	if len(orders) == 0 {
+		return nil
+	}
+	res, err := im.store.SaveOrders(ctx, orders, im.opts.Conflict)
+	if err == nil {
+		im.count(batch, res)
+		return nil
+	}
+	if !errors.Is(err, postgres.ErrInvalid) {
+		return err
+	}
+	for i := range batch {
+		rec := &batch[i]
+		if rec.Err != nil {
+			continue
+		}
+		res, err := im.store.SaveOrders(ctx, []*model.Order{rec.Order}, im.opts.Conflict)
+		if errors.Is(err, postgres.ErrInvalid) {
+			rec.Err = err
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		im.count(batch[i:i+1], res)
+	}
+	return nil
+}
+
+// count добавляет итог записи к счётчикам и отмечает заказы, отклонённые бд
+func (im *Importer) count(batch []Record, res postgres.BatchResult) {
+	im.stats.Inserted += res.Inserted
+	im.stats.Updated += res.Updated
+	im.stats.Skipped += res.Skipped
+	if len(res.Rejected) == 0 {
+		return
+	}
+	for i := range batch {
+		if rec := &batch[i]; rec.Err == nil {
+			if err, ok := res.Rejected[rec.Order.OrderUID]; ok {
+				rec.Err = err
+			}
+		}
+	}
+}
+
+// reject пишет отклонённые записи пачки
+func (im *Importer) reject(file string, batch []Record) error {
+	var enc *json.Encoder
+	if im.opts.Rejects != nil {
+		enc = json.NewEncoder(im.opts.Rejects)
+		enc.SetEscapeHTML(false)
+	}
+	for _, rec := range batch {
+		if rec.Err == nil {
+			continue
+		}
+		im.stats.Rejected++
+		if enc == nil {
+			continue
+		}
+		r := Reject{File: file, Line: rec.Line, Error: rec.Err.Error(), Raw: rec.Raw}
+		if rec.Order != nil {
+			r.OrderUID = rec.Order.OrderUID
+		}
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("не удалось записать отклонённые: %w", err)
+		}
+	}
+	return nil
+}
+
+// saveCheckpoint заменяет файл контрольной точки целиком через rename,
+// чтобы прерывание не оставило его недописанным
+func (im *Importer) saveCheckpoint() error {
+	if im.opts.Checkpoint == "" {
+		return nil
+	}
+	data, err := json.Marshal(im.state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(im.opts.Checkpoint), filepath.Base(im.opts.Checkpoint)+".*")
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить контрольную точку: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), im.opts.Checkpoint)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("не удалось сохранить контрольную точку: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package backfill
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"demo-service/internal/export"
+	"demo-service/internal/infrastructure/postgres"
+	"demo-service/internal/model"
+)
+
+func testOrder(uid string) *model.Order {
+	return &model.Order{
+		OrderUID:    uid,
+		TrackNumber: "WBILMTESTTRACK",
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		Status:      model.StatusDelivered,
+		Delivery:    model.Delivery{Name: "Test Testov", Phone: "+9720000000", City: "Kiryat Mozkin", Email: "test@gmail.com"},
+		Payment:     model.Payment{Transaction: uid, Currency: "USD", Amount: 1817, PaymentDt: 1637907727},
+		Items: []model.Item{
+			{ChrtID: 9934930, Name: "Mascaras", Brand: "Vivienne Sabo", TotalPrice: 317},
+			{ChrtID: 9934931, Name: "=HYPERLINK(\"x\")", Brand: "<Brand & Co>", TotalPrice: 1500},
+		},
+	}
+}
+
+// fakeStore ведёт себя как SaveOrders: повтор в пачке и заказ с пустым
+// треком (как нарушение ограничения бд) отклоняют всю пачку
+type fakeStore struct {
+	saved   map[string]*model.Order
+	batches int
+	// после стольких пачек запись падает, 0 - не падает
+	failAfter int
+}
+
+func (f *fakeStore) SaveOrders(_ context.Context, orders []*model.Order, policy postgres.ConflictPolicy) (postgres.BatchResult, error) {
+	var res postgres.BatchResult
+	if f.failAfter > 0 && f.batches >= f.failAfter {
+		return res, errors.New("соединение разорвано")
+	}
+	seen := make(map[string]bool)
+	for _, o := range orders {
+		if seen[o.OrderUID] || o.Payment.Bank == "bad" {
+			return res, fmt.Errorf("%w: заказ %s", postgres.ErrInvalid, o.OrderUID)
+		}
+		seen[o.OrderUID] = true
+		if _, ok := f.saved[o.OrderUID]; ok && policy == postgres.ConflictFail {
+			return res, fmt.Errorf("%w: %s", postgres.ErrConflict, o.OrderUID)
+		}
+	}
+	f.batches++
+	for _, o := range orders {
+		_, ok := f.saved[o.OrderUID]
+		switch {
+		case !ok:
+			res.Inserted++
+		case policy == postgres.ConflictSkip:
+			res.Skipped++
+			continue
+		default:
+			res.Updated++
+		}
+		f.saved[o.OrderUID] = o
+	}
+	return res, nil
+}
+
+func ndjson(t *testing.T, orders ...*model.Order) string {
+	t.Helper()
+	var b strings.Builder
+	for _, o := range orders {
+		data, err := json.Marshal(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Write(data)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func TestImport(t *testing.T) {
+	store := &fakeStore{saved: map[string]*model.Order{"order-1": testOrder("order-1")}}
+	bad := testOrder("order-4")
+	bad.Payment.Bank = "bad"
+	noItems := testOrder("order-5")
+	noItems.Items = nil
+	data := ndjson(t, testOrder("order-1"), testOrder("order-2"), testOrder("order-2")) +
+		"{не json\n\n" + ndjson(t, testOrder("order-3"), bad, noItems)
+
+	var rejects bytes.Buffer
+	im, err := New(store, Options{Batch: 10, Conflict: postgres.ConflictOverwrite, Rejects: &rejects})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := im.Import(context.Background(), Source{Name: "orders.ndjson", Reader: NewNDJSONReader(strings.NewReader(data))}); err != nil {
+		t.Fatal(err)
+	}
+	want := Stats{Read: 7, Inserted: 2, Updated: 2, Rejected: 3}
+	if got := im.Stats(); got != want {
+		t.Errorf("счётчики %+v, ожидалось %+v", got, want)
+	}
+
+	var lines []int
+	dec := json.NewDecoder(&rejects)
+	for dec.More() {
+		var r Reject
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		if r.File != "orders.ndjson" || r.Error == "" || r.Raw == "" {
+			t.Errorf("отклонённая запись %+v", r)
+		}
+		lines = append(lines, r.Line)
+	}
+	// пустая строка 5 пропускается, но учитывается в номерах строк
+	if !reflect.DeepEqual(lines, []int{4, 7, 8}) {
+		t.Errorf("отклонены строки %v, ожидались 4, 7, 8", lines)
+	}
+}
+
+func TestImport_ConflictFail(t *testing.T) {
+	store := &fakeStore{saved: map[string]*model.Order{"order-2": testOrder("order-2")}}
+	im, err := New(store, Options{Conflict: postgres.ConflictFail})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := Source{Name: "orders.ndjson", Reader: NewNDJSONReader(strings.NewReader(ndjson(t, testOrder("order-1"), testOrder("order-2"))))}
+	if err := im.Import(context.Background(), src); !errors.Is(err, postgres.ErrConflict) {
+		t.Fatalf("ожидалась ErrConflict, получено %v", err)
+	}
+	if len(store.saved) != 1 {
+		t.Errorf("пачка с конфликтом записана частично: %d заказов", len(store.saved))
+	}
+}
+
+func TestImport_Resume(t *testing.T) {
+	var orders []*model.Order
+	for i := range 7 {
+		orders = append(orders, testOrder(fmt.Sprintf("order-%d", i)))
+	}
+	data := ndjson(t, orders...)
+	checkpoint := filepath.Join(t.TempDir(), "backfill.json")
+	opts := Options{Batch: 3, Checkpoint: checkpoint}
+	src := func() Source {
+		return Source{Name: "orders.ndjson", Version: "v1", Reader: NewNDJSONReader(strings.NewReader(data))}
+	}
+
+	// запись падает на второй пачке, первая сохранена в контрольной точке
+	store := &fakeStore{saved: make(map[string]*model.Order), failAfter: 1}
+	im, err := New(store, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := im.Import(context.Background(), src()); err == nil {
+		t.Fatal("ожидалась ошибка записи")
+	}
+
+	store.failAfter = 0
+	im, err = New(store, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := im.Import(context.Background(), src()); err != nil {
+		t.Fatal(err)
+	}
+	if got := im.Stats(); got.Read != 4 || got.Inserted != 4 || len(store.saved) != 7 {
+		t.Errorf("после продолжения %+v, в бд %d заказов", got, len(store.saved))
+	}
+
+	// загруженный файл повторно не читается, изменённый - ошибка
+	im, err = New(store, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := im.Import(context.Background(), src()); err != nil || im.Stats().Read != 0 {
+		t.Errorf("повторная загрузка: %v, %+v", err, im.Stats())
+	}
+	changed := src()
+	changed.Version = "v2"
+	if err := im.Import(context.Background(), changed); err == nil {
+		t.Error("изменённый файл загружен по старой контрольной точке")
+	}
+}
+
+func TestCSVReader_Export(t *testing.T) {
+	orders := []*model.Order{testOrder("order-1"), testOrder("order-2")}
+	var buf bytes.Buffer
+	enc, err := export.NewEncoder(&buf, export.Options{Format: export.CSV, PerItem: true, Unmasked: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range orders {
+		if err := enc.Encode(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewCSVReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range orders {
+		rec, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Err != nil {
+			t.Fatal(rec.Err)
+		}
+		// заголовок - строка 1, у заказа по строке на товар
+		if rec.Line != 2+2*i || rec.End != 3+2*i {
+			t.Errorf("заказ %s на строках %d-%d", want.OrderUID, rec.Line, rec.End)
+		}
+		if !reflect.DeepEqual(rec.Order, want) {
+			t.Errorf("прочитан заказ %+v, ожидался %+v", rec.Order, want)
+		}
+	}
+	if _, err := r.Next(); err == nil {
+		t.Error("ожидался конец файла")
+	}
+}
@@ -0,0 +1,275 @@
+package backfill
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"demo-service/internal/model"
+)
+
+// Record - заказ из файла и строки, которые он занимает
+type Record struct {
+	// первая и последняя строка заказа в файле, считая с 1
+	Line, End int
+	// исходный текст записи для файла отклонённых
+	Raw   string
+	Order *model.Order
+	// ошибка разбора, заказ при ней не записывается
+	Err error
+}
+
+// Reader читает записи по одной, в конце файла возвращает io.EOF. Ошибка
+// отдельной записи возвращается в Record.Err, ошибка Next прерывает загрузку.
+type Reader interface {
+	Next() (Record, error)
+}
+
+// ndjsonReader читает заказ из каждой непустой строки
+type ndjsonReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+func NewNDJSONReader(r io.Reader) Reader {
+	return &ndjsonReader{r: bufio.NewReaderSize(r, 1<<16)}
+}
+
+func (n *ndjsonReader) Next() (Record, error) {
+	for {
+		data, err := n.r.ReadBytes('\n')
+		if len(data) == 0 && err != nil {
+			return Record{}, err
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return Record{}, err
+		}
+		n.line++
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+		rec := Record{Line: n.line, End: n.line, Raw: string(data)}
+		var o model.Order
+		if err := json.Unmarshal(data, &o); err != nil {
+			rec.Err = fmt.Errorf("некорректный JSON: %w", err)
+		} else {
+			rec.Order = &o
+		}
+		return rec, nil
+	}
+}
+
+// csvReader читает таблицу выгрузки GET /orders/export?format=csv&rows=item:
+// строки одного заказа идут подряд, поля заказа берутся из первой, товары - из каждой
+type csvReader struct {
+	r       *csv.Reader
+	columns []string
+	// прочитанная наперёд первая строка следующего заказа
+	next     []string
+	nextLine int
+	nextErr  error
+}
+
+// NewCSVReader разбирает заголовок таблицы, столбец order_uid обязателен
+func NewCSVReader(r io.Reader) (Reader, error) {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = false
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать заголовок CSV: %w", err)
+	}
+	hasUID := false
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		header[i] = name
+		if _, ok := csvSetters[name]; !ok && name != "items_count" {
+			return nil, fmt.Errorf("неизвестный столбец CSV %q", name)
+		}
+		hasUID = hasUID || name == "order_uid"
+	}
+	if !hasUID {
+		return nil, errors.New("в CSV нет столбца order_uid")
+	}
+	c := &csvReader{r: cr, columns: header}
+	c.advance()
+	return c, nil
+}
+
+// advance читает следующую строку наперёд
+func (c *csvReader) advance() {
+	c.next, c.nextErr = c.r.Read()
+	var pe *csv.ParseError
+	switch {
+	case c.nextErr == nil:
+		c.nextLine, _ = c.r.FieldPos(0)
+	case errors.As(c.nextErr, &pe):
+		c.nextLine = pe.StartLine
+	}
+}
+
+func (c *csvReader) Next() (Record, error) {
+	if c.nextErr != nil {
+		var pe *csv.ParseError
+		if !errors.As(c.nextErr, &pe) {
+			return Record{}, c.nextErr
+		}
+		// испорченная строка отклоняется, чтение продолжается со следующей
+		rec := Record{Line: c.nextLine, End: pe.Line, Raw: strings.Join(c.next, ","), Err: c.nextErr}
+		c.advance()
+		return rec, nil
+	}
+
+	rows := [][]string{c.next}
+	rec := Record{Line: c.nextLine, End: c.nextLine}
+	uid := c.value(c.next, "order_uid")
+	for {
+		c.advance()
+		if c.nextErr != nil || c.value(c.next, "order_uid") != uid {
+			break
+		}
+		rows = append(rows, c.next)
+		rec.End = c.nextLine
+	}
+
+	var raw strings.Builder
+	w := csv.NewWriter(&raw)
+	w.WriteAll(rows)
+	rec.Raw = strings.TrimSuffix(raw.String(), "\n")
+	rec.Order, rec.Err = c.order(rows)
+	return rec, nil
+}
+
+func (c *csvReader) value(row []string, column string) string {
+	for i, name := range c.columns {
+		if name == column && i < len(row) {
+			return unescapeFormula(row[i])
+		}
+	}
+	return ""
+}
+
+// order собирает заказ из его строк
+func (c *csvReader) order(rows [][]string) (*model.Order, error) {
+	o := &model.Order{}
+	for n, row := range rows {
+		var it model.Item
+		hasItem := false
+		for i, name := range c.columns {
+			set, ok := csvSetters[name]
+			if !ok {
+				continue
+			}
+			v := unescapeFormula(row[i])
+			if set.item {
+				hasItem = hasItem || v != ""
+			} else if n > 0 {
+				// поля заказа повторяются в каждой строке, берутся из первой
+				continue
+			}
+			if v == "" {
+				continue
+			}
+			if err := set.fn(o, &it, v); err != nil {
+				return nil, fmt.Errorf("столбец %s: %w", name, err)
+			}
+		}
+		if hasItem {
+			o.Items = append(o.Items, it)
+		}
+	}
+	return o, nil
+}
+
+// unescapeFormula снимает апостроф, которым выгрузка защищает строки от формул
+func unescapeFormula(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+type csvSetter struct {
+	item bool
+	fn   func(o *model.Order, it *model.Item, v string) error
+}
+
+func str(set func(o *model.Order, v string)) csvSetter {
+	return csvSetter{fn: func(o *model.Order, _ *model.Item, v string) error { set(o, v); return nil }}
+}
+
+func integer(set func(o *model.Order, v int)) csvSetter {
+	return csvSetter{fn: func(o *model.Order, _ *model.Item, v string) error {
+		n, err := strconv.Atoi(v)
+		set(o, n)
+		return err
+	}}
+}
+
+func itemStr(set func(it *model.Item, v string)) csvSetter {
+	return csvSetter{item: true, fn: func(_ *model.Order, it *model.Item, v string) error { set(it, v); return nil }}
+}
+
+func itemInt(set func(it *model.Item, v int)) csvSetter {
+	return csvSetter{item: true, fn: func(_ *model.Order, it *model.Item, v string) error {
+		n, err := strconv.Atoi(v)
+		set(it, n)
+		return err
+	}}
+}
+
+// столбцы выгрузки export и поля заказа, куда они читаются
+var csvSetters = map[string]csvSetter{
+	"order_uid": str(func(o *model.Order, v string) { o.OrderUID = v }),
+	"date_created": {fn: func(o *model.Order, _ *model.Item, v string) (err error) {
+		o.DateCreated, err = time.Parse(time.RFC3339, v)
+		return err
+	}},
+	"status":                str(func(o *model.Order, v string) { o.Status = model.Status(v) }),
+	"customer_id":           str(func(o *model.Order, v string) { o.CustomerID = v }),
+	"track_number":          str(func(o *model.Order, v string) { o.TrackNumber = v }),
+	"entry":                 str(func(o *model.Order, v string) { o.Entry = v }),
+	"locale":                str(func(o *model.Order, v string) { o.Locale = v }),
+	"delivery_service":      str(func(o *model.Order, v string) { o.DeliveryService = v }),
+	"shardkey":              str(func(o *model.Order, v string) { o.Shardkey = v }),
+	"sm_id":                 integer(func(o *model.Order, v int) { o.SmID = v }),
+	"oof_shard":             str(func(o *model.Order, v string) { o.OofShard = v }),
+	"delivery_name":         str(func(o *model.Order, v string) { o.Delivery.Name = v }),
+	"delivery_phone":        str(func(o *model.Order, v string) { o.Delivery.Phone = v }),
+	"delivery_email":        str(func(o *model.Order, v string) { o.Delivery.Email = v }),
+	"delivery_zip":          str(func(o *model.Order, v string) { o.Delivery.Zip = v }),
+	"delivery_region":       str(func(o *model.Order, v string) { o.Delivery.Region = v }),
+	"delivery_city":         str(func(o *model.Order, v string) { o.Delivery.City = v }),
+	"delivery_address":      str(func(o *model.Order, v string) { o.Delivery.Address = v }),
+	"payment_transaction":   str(func(o *model.Order, v string) { o.Payment.Transaction = v }),
+	"payment_request_id":    str(func(o *model.Order, v string) { o.Payment.RequestID = v }),
+	"payment_provider":      str(func(o *model.Order, v string) { o.Payment.Provider = v }),
+	"payment_bank":          str(func(o *model.Order, v string) { o.Payment.Bank = v }),
+	"payment_currency":      str(func(o *model.Order, v string) { o.Payment.Currency = v }),
+	"payment_amount":        integer(func(o *model.Order, v int) { o.Payment.Amount = v }),
+	"payment_delivery_cost": integer(func(o *model.Order, v int) { o.Payment.DeliveryCost = v }),
+	"payment_goods_total":   integer(func(o *model.Order, v int) { o.Payment.GoodsTotal = v }),
+	"payment_custom_fee":    integer(func(o *model.Order, v int) { o.Payment.CustomFee = v }),
+	"payment_dt": {fn: func(o *model.Order, _ *model.Item, v string) (err error) {
+		o.Payment.PaymentDt, err = strconv.ParseInt(v, 10, 64)
+		return err
+	}},
+	"item_chrt_id":      itemInt(func(it *model.Item, v int) { it.ChrtID = v }),
+	"item_nm_id":        itemInt(func(it *model.Item, v int) { it.NmID = v }),
+	"item_rid":          itemStr(func(it *model.Item, v string) { it.Rid = v }),
+	"item_name":         itemStr(func(it *model.Item, v string) { it.Name = v }),
+	"item_brand":        itemStr(func(it *model.Item, v string) { it.Brand = v }),
+	"item_size":         itemStr(func(it *model.Item, v string) { it.Size = v }),
+	"item_track_number": itemStr(func(it *model.Item, v string) { it.TrackNumber = v }),
+	"item_price":        itemInt(func(it *model.Item, v int) { it.Price = v }),
+	"item_sale":         itemInt(func(it *model.Item, v int) { it.Sale = v }),
+	"item_total_price":  itemInt(func(it *model.Item, v int) { it.TotalPrice = v }),
+	"item_status":       itemInt(func(it *model.Item, v int) { it.Status = v }),
+}
@@ -0,0 +1,298 @@
+// Package codec - форматы сообщений с заказами (JSON, Protobuf, Avro)
+// и заголовок Confluent с id схемы из реестра.
+package codec
+
+import (
+	"context"
+	_ "embed"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"sync"
+
+	"demo-service/api/orderpb"
+	"demo-service/internal/model"
+
+	"github.com/hamba/avro/v2"
+	"google.golang.org/protobuf/proto"
+)
+
+type Format string
+
+const (
+	JSON     Format = "json"
+	Protobuf Format = "protobuf"
+	Avro     Format = "avro"
+)
+
+// HeaderContentType - заголовок Kafka-сообщения с форматом значения
+const HeaderContentType = "content-type"
+
+// ErrUnsupported - формат сообщения не распознан
+var ErrUnsupported = errors.New("неподдерживаемый формат сообщения")
+
+// AvroSchema - схема заказа в Avro
+//
+//go:embed order.avsc
+var AvroSchema string
+
+var (
+	orderAvroSchema = avro.MustParse(AvroSchema)
+	// поля Avro совпадают с JSON-тегами model.Order
+	avroAPI = avro.Config{TagKey: "json"}.Freeze()
+)
+
+var contentTypes = map[string]Format{
+	"application/json":                JSON,
+	"application/x-protobuf":          Protobuf,
+	"application/protobuf":            Protobuf,
+	"application/vnd.google.protobuf": Protobuf,
+	"application/avro":                Avro,
+	"avro/binary":                     Avro,
+}
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case JSON, Protobuf, Avro:
+		return f, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnsupported, s)
+}
+
+// ContentType - значение заголовка content-type для формата
+func (f Format) ContentType() string {
+	switch f {
+	case Protobuf:
+		return "application/x-protobuf"
+	case Avro:
+		return "application/avro"
+	default:
+		return "application/json"
+	}
+}
+
+// FormatFromContentType определяет формат по content-type, параметры игнорируются
+func FormatFromContentType(ct string) (Format, error) {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", ErrUnsupported, ct)
+	}
+	f, ok := contentTypes[mt]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnsupported, ct)
+	}
+	return f, nil
+}
+
+func schemaType(f Format) string {
+	switch f {
+	case Protobuf:
+		return SchemaProtobuf
+	case Avro:
+		return SchemaAvro
+	default:
+		return SchemaJSON
+	}
+}
+
+func formatOf(schemaType string) (Format, error) {
+	switch schemaType {
+	case SchemaProtobuf:
+		return Protobuf, nil
+	case SchemaAvro, "":
+		return Avro, nil
+	case SchemaJSON:
+		return JSON, nil
+	}
+	return "", fmt.Errorf("%w: схема типа %q", ErrUnsupported, schemaType)
+}
+
+// Decoder разбирает сообщения в любом из форматов. Для сообщений с
+// заголовком Confluent схема берётся из реестра по id.
+type Decoder struct {
+	registry Registry
+
+	mu          sync.Mutex
+	avroSchemas map[int]avro.Schema
+}
+
+// NewDecoder создаёт декодер, registry может быть nil - тогда принимаются
+// только сообщения без заголовка Confluent
+func NewDecoder(registry Registry) *Decoder {
+	return &Decoder{registry: registry, avroSchemas: make(map[int]avro.Schema)}
+}
+
+// Decode разбирает значение сообщения. Формат задаётся contentType, а если
+// он пуст - определяется по заголовку Confluent; без него считается JSON.
+func (d *Decoder) Decode(ctx context.Context, contentType string, data []byte) (*model.Order, error) {
+	format := JSON
+	if contentType != "" {
+		f, err := FormatFromContentType(contentType)
+		if err != nil {
+			return nil, err
+		}
+		format = f
+	}
+
+	var schema Schema
+	schemaID := -1
+	payload := data
+	if framed(data) {
+		if d.registry == nil {
+			return nil, fmt.Errorf("%w: сообщение с id схемы, но реестр не настроен", ErrUnsupported)
+		}
+		schemaID = int(binary.BigEndian.Uint32(data[1:5]))
+		payload = data[5:]
+
+		var err error
+		schema, err = d.registry.SchemaByID(ctx, schemaID)
+		if err != nil {
+			return nil, err
+		}
+		f, err := formatOf(schema.Type)
+		if err != nil {
+			return nil, err
+		}
+		if contentType != "" && f != format {
+			return nil, fmt.Errorf("%w: content-type %s, а схема %d типа %s", ErrUnsupported, contentType, schemaID, schema.Type)
+		}
+		format = f
+	}
+
+	var o model.Order
+	switch format {
+	case JSON:
+		if err := json.Unmarshal(payload, &o); err != nil {
+			return nil, fmt.Errorf("ошибка парсинга JSON: %w", err)
+		}
+		return &o, nil
+
+	case Protobuf:
+		if schemaID >= 0 {
+			var err error
+			if payload, err = skipMessageIndexes(payload); err != nil {
+				return nil, err
+			}
+		}
+		var msg orderpb.Order
+		if err := proto.Unmarshal(payload, &msg); err != nil {
+			return nil, fmt.Errorf("ошибка парсинга Protobuf: %w", err)
+		}
+		return msg.ToModel(), nil
+
+	case Avro:
+		writer := orderAvroSchema
+		if schemaID >= 0 {
+			var err error
+			if writer, err = d.avroSchema(schemaID, schema.Schema); err != nil {
+				return nil, err
+			}
+		}
+		if err := avroAPI.Unmarshal(writer, payload, &o); err != nil {
+			return nil, fmt.Errorf("ошибка парсинга Avro: %w", err)
+		}
+		return &o, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupported, format)
+}
+
+func (d *Decoder) avroSchema(id int, text string) (avro.Schema, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if s, ok := d.avroSchemas[id]; ok {
+		return s, nil
+	}
+	s, err := avro.Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("некорректная Avro-схема %d: %w", id, err)
+	}
+	d.avroSchemas[id] = s
+	return s, nil
+}
+
+// Encoder сериализует заказы в одном формате. С реестром схема Avro/Protobuf
+// регистрируется под subject, а сообщения получают заголовок Confluent.
+type Encoder struct {
+	format   Format
+	schemaID int
+}
+
+// NewEncoder создаёт кодировщик, registry может быть nil. JSON всегда
+// пишется без заголовка, чтобы старые потребители его понимали.
+func NewEncoder(ctx context.Context, format Format, registry Registry, subject string) (*Encoder, error) {
+	e := &Encoder{format: format, schemaID: -1}
+	if registry == nil || format == JSON {
+		return e, nil
+	}
+
+	schema := Schema{Type: schemaType(format), Schema: AvroSchema}
+	if format == Protobuf {
+		schema.Schema = orderpb.Schema
+	}
+	id, err := registry.Register(ctx, subject, schema)
+	if err != nil {
+		return nil, err
+	}
+	e.schemaID = id
+	return e, nil
+}
+
+func (e *Encoder) Format() Format {
+	return e.format
+}
+
+func (e *Encoder) ContentType() string {
+	return e.format.ContentType()
+}
+
+func (e *Encoder) Encode(o *model.Order) ([]byte, error) {
+	var payload []byte
+	var err error
+	switch e.format {
+	case JSON:
+		return json.Marshal(o)
+	case Protobuf:
+		payload, err = proto.Marshal(orderpb.FromModel(o))
+	case Avro:
+		payload, err = avroAPI.Marshal(orderAvroSchema, o)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, e.format)
+	}
+	if err != nil || e.schemaID < 0 {
+		return payload, err
+	}
+
+	out := make([]byte, 5, 5+2*binary.MaxVarintLen64+len(payload))
+	binary.BigEndian.PutUint32(out[1:5], uint32(e.schemaID))
+	if e.format == Protobuf {
+		// индексы сообщения в файле: [OrderMessageIndex]
+		out = binary.AppendVarint(out, 1)
+		out = binary.AppendVarint(out, orderpb.OrderMessageIndex)
+	}
+	return append(out, payload...), nil
+}
+
+// framed - сообщение начинается с magic byte 0 и id схемы
+func framed(data []byte) bool {
+	return len(data) >= 5 && data[0] == 0
+}
+
+// пропускает индексы сообщения Protobuf из заголовка Confluent
+func skipMessageIndexes(data []byte) ([]byte, error) {
+	count, n := binary.Varint(data)
+	if n <= 0 || count < 0 {
+		return nil, fmt.Errorf("%w: некорректные индексы сообщения Protobuf", ErrUnsupported)
+	}
+	data = data[n:]
+	for i := int64(0); i < count; i++ {
+		_, n := binary.Varint(data)
+		if n <= 0 {
+			return nil, fmt.Errorf("%w: некорректные индексы сообщения Protobuf", ErrUnsupported)
+		}
+		data = data[n:]
+	}
+	return data, nil
+}
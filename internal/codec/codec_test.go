@@ -0,0 +1,168 @@
+package codec
+
+import (
+	"context"
+	"demo-service/internal/model"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testOrder() *model.Order {
+	return &model.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: model.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809",
+			City: "Kiryat Mozkin", Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: model.Payment{
+			Transaction: "b563feb7b2b84b6test", Currency: "USD", Provider: "wbpay",
+			Amount: 1817, PaymentDt: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Items: []model.Item{{
+			ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, Rid: "ab4219087a764ae0btest",
+			Name: "Mascaras", Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202,
+		}},
+		Locale: "en", CustomerID: "test", DeliveryService: "meest", Shardkey: "9", SmID: 99,
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC), OofShard: "1",
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	reg, err := NewFileRegistry(t.TempDir())
+	if err != nil {
+		t.Fatalf("Ошибка создания реестра: %v", err)
+	}
+	want := testOrder()
+
+	for _, format := range []Format{JSON, Protobuf, Avro} {
+		for _, registry := range []Registry{nil, reg} {
+			enc, err := NewEncoder(ctx, format, registry, "orders-value")
+			if err != nil {
+				t.Fatalf("%s: ошибка создания кодировщика: %v", format, err)
+			}
+			data, err := enc.Encode(want)
+			if err != nil {
+				t.Fatalf("%s: ошибка кодирования: %v", format, err)
+			}
+
+			dec := NewDecoder(registry)
+			got, err := dec.Decode(ctx, enc.ContentType(), data)
+			if err != nil {
+				t.Fatalf("%s (реестр %v): ошибка декодирования: %v", format, registry != nil, err)
+			}
+			got.DateCreated = got.DateCreated.UTC()
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("%s: получен %+v, ожидался %+v", format, got, want)
+			}
+		}
+	}
+}
+
+func TestDecode_FormatFromSchemaID(t *testing.T) {
+	ctx := context.Background()
+	reg, _ := NewFileRegistry(t.TempDir())
+
+	enc, err := NewEncoder(ctx, Avro, reg, "orders-value")
+	if err != nil {
+		t.Fatalf("Ошибка создания кодировщика: %v", err)
+	}
+	data, _ := enc.Encode(testOrder())
+
+	// без content-type формат определяется по типу схемы в реестре
+	got, err := NewDecoder(reg).Decode(ctx, "", data)
+	if err != nil {
+		t.Fatalf("Ошибка декодирования: %v", err)
+	}
+	if got.OrderUID != testOrder().OrderUID {
+		t.Errorf("неожиданный order_uid %s", got.OrderUID)
+	}
+
+	if _, err := NewDecoder(reg).Decode(ctx, "text/plain", data); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("ожидалась ErrUnsupported, получено %v", err)
+	}
+}
+
+func TestFileRegistry_Persists(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	reg, _ := NewFileRegistry(dir)
+
+	id, err := reg.Register(ctx, "orders-value", Schema{Type: SchemaAvro, Schema: AvroSchema})
+	if err != nil {
+		t.Fatalf("Ошибка регистрации: %v", err)
+	}
+	again, _ := reg.Register(ctx, "orders-value", Schema{Type: SchemaAvro, Schema: AvroSchema})
+	if again != id {
+		t.Errorf("повторная регистрация выдала новый id %d, ожидался %d", again, id)
+	}
+
+	reopened, err := NewFileRegistry(dir)
+	if err != nil {
+		t.Fatalf("Ошибка открытия реестра: %v", err)
+	}
+	s, err := reopened.SchemaByID(ctx, id)
+	if err != nil || s.Type != SchemaAvro {
+		t.Errorf("схема не найдена после перезапуска: %v %v", s.Type, err)
+	}
+}
+
+func TestRegistryClient(t *testing.T) {
+	var lookups int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/subjects/orders-value/versions":
+			var s Schema
+			json.NewDecoder(r.Body).Decode(&s)
+			if s.Type != SchemaProtobuf || !strings.Contains(s.Schema, "message Order") {
+				http.Error(w, `{"error_code":42201,"message":"bad schema"}`, http.StatusUnprocessableEntity)
+				return
+			}
+			w.Write([]byte(`{"id":7}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/schemas/ids/8":
+			lookups++
+			w.Write([]byte(`{"schema":` + mustJSON(AvroSchema) + `}`))
+		default:
+			http.Error(w, `{"error_code":40403,"message":"not found"}`, http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := NewRegistryClient(srv.URL)
+
+	enc, err := NewEncoder(ctx, Protobuf, client, "orders-value")
+	if err != nil {
+		t.Fatalf("Ошибка регистрации: %v", err)
+	}
+	data, _ := enc.Encode(testOrder())
+	if data[0] != 0 || data[4] != 7 {
+		t.Errorf("ожидался заголовок Confluent с id 7, получено % x", data[:5])
+	}
+
+	for i := 0; i < 2; i++ {
+		s, err := client.SchemaByID(ctx, 8)
+		if err != nil || s.Type != SchemaAvro {
+			t.Fatalf("неожиданная схема: %v %v", s.Type, err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("схема должна кешироваться, запросов %d", lookups)
+	}
+	if _, err := client.SchemaByID(ctx, 99); err == nil {
+		t.Error("ожидалась ошибка для неизвестного id")
+	}
+}
+
+func mustJSON(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
@@ -0,0 +1,92 @@
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FileRegistry - реестр схем в каталоге, замена Schema Registry для тестов
+// и локального запуска. Каждая схема хранится в файле <id>.json.
+type FileRegistry struct {
+	dir string
+
+	mu      sync.Mutex
+	schemas map[int]fileSchema
+	nextID  int
+}
+
+type fileSchema struct {
+	Subject string `json:"subject"`
+	Schema
+}
+
+func NewFileRegistry(dir string) (*FileRegistry, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("не удалось создать каталог реестра: %w", err)
+	}
+	r := &FileRegistry{dir: dir, schemas: make(map[int]fileSchema), nextID: 1}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать каталог реестра: %w", err)
+	}
+	for _, e := range entries {
+		id, err := strconv.Atoi(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil || e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать схему %d: %w", id, err)
+		}
+		var s fileSchema
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("некорректная схема %d: %w", id, err)
+		}
+		r.schemas[id] = s
+		if id >= r.nextID {
+			r.nextID = id + 1
+		}
+	}
+	return r, nil
+}
+
+func (r *FileRegistry) SchemaByID(ctx context.Context, id int) (Schema, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.schemas[id]
+	if !ok {
+		return Schema{}, fmt.Errorf("схема %d не найдена", id)
+	}
+	return s.Schema, nil
+}
+
+// Register возвращает id уже зарегистрированной такой же схемы или сохраняет новую
+func (r *FileRegistry) Register(ctx context.Context, subject string, schema Schema) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, s := range r.schemas {
+		if s.Subject == subject && s.Schema == schema {
+			return id, nil
+		}
+	}
+
+	id := r.nextID
+	data, err := json.MarshalIndent(fileSchema{Subject: subject, Schema: schema}, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(filepath.Join(r.dir, strconv.Itoa(id)+".json"), data, 0o644); err != nil {
+		return 0, fmt.Errorf("не удалось сохранить схему: %w", err)
+	}
+	r.schemas[id] = fileSchema{Subject: subject, Schema: schema}
+	r.nextID++
+	return id, nil
+}
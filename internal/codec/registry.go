@@ -0,0 +1,151 @@
+package codec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Типы схем в терминах Confluent Schema Registry
+const (
+	SchemaAvro     = "AVRO"
+	SchemaProtobuf = "PROTOBUF"
+	SchemaJSON     = "JSON"
+)
+
+// Schema - схема из реестра
+type Schema struct {
+	Type   string `json:"schemaType"`
+	Schema string `json:"schema"`
+}
+
+// Registry - реестр схем: поиск по id и регистрация под subject
+type Registry interface {
+	SchemaByID(ctx context.Context, id int) (Schema, error)
+	Register(ctx context.Context, subject string, schema Schema) (int, error)
+}
+
+// NewRegistry выбирает реализацию по адресу: file://<каталог> - файловый
+// реестр, http(s):// - клиент Confluent-совместимого реестра
+func NewRegistry(addr string) (Registry, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный адрес реестра схем: %w", err)
+	}
+	switch u.Scheme {
+	case "file":
+		return NewFileRegistry(u.Host + u.Path)
+	case "http", "https":
+		return NewRegistryClient(addr), nil
+	default:
+		return nil, fmt.Errorf("неподдерживаемая схема адреса реестра: %q", u.Scheme)
+	}
+}
+
+// RegistryClient - клиент REST API Confluent Schema Registry.
+// Схемы по id неизменяемы, поэтому кешируются без ограничения срока.
+type RegistryClient struct {
+	baseURL string
+	http    *http.Client
+
+	mu    sync.RWMutex
+	byID  map[int]Schema
+	idsBy map[string]int
+}
+
+func NewRegistryClient(baseURL string) *RegistryClient {
+	return &RegistryClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: 10 * time.Second},
+		byID:    make(map[int]Schema),
+		idsBy:   make(map[string]int),
+	}
+}
+
+func (c *RegistryClient) SchemaByID(ctx context.Context, id int) (Schema, error) {
+	c.mu.RLock()
+	s, ok := c.byID[id]
+	c.mu.RUnlock()
+	if ok {
+		return s, nil
+	}
+
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, &s); err != nil {
+		return Schema{}, fmt.Errorf("схема %d: %w", id, err)
+	}
+	// реестр не возвращает schemaType для AVRO
+	if s.Type == "" {
+		s.Type = SchemaAvro
+	}
+
+	c.mu.Lock()
+	c.byID[id] = s
+	c.mu.Unlock()
+	return s, nil
+}
+
+func (c *RegistryClient) Register(ctx context.Context, subject string, schema Schema) (int, error) {
+	key := subject + "\x00" + schema.Type + "\x00" + schema.Schema
+	c.mu.RLock()
+	id, ok := c.idsBy[key]
+	c.mu.RUnlock()
+	if ok {
+		return id, nil
+	}
+
+	var resp struct {
+		ID int `json:"id"`
+	}
+	path := "/subjects/" + url.PathEscape(subject) + "/versions"
+	if err := c.do(ctx, http.MethodPost, path, schema, &resp); err != nil {
+		return 0, fmt.Errorf("регистрация схемы %s: %w", subject, err)
+	}
+
+	c.mu.Lock()
+	c.idsBy[key] = resp.ID
+	c.byID[resp.ID] = schema
+	c.mu.Unlock()
+	return resp.ID, nil
+}
+
+func (c *RegistryClient) do(ctx context.Context, method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Code    int    `json:"error_code"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&e)
+		return fmt.Errorf("реестр ответил %d: %s", resp.StatusCode, e.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
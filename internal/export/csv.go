@@ -0,0 +1,49 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+type csvEncoder struct {
+	*tableEncoder
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVEncoder(w io.Writer, perItem bool) (Encoder, error) {
+	e := &csvEncoder{w: csv.NewWriter(w)}
+	t, err := newTableEncoder(e, perItem)
+	if err != nil {
+		return nil, err
+	}
+	e.tableEncoder = t
+	return e, nil
+}
+
+func (e *csvEncoder) writeRow(cells []any) error {
+	e.record = e.record[:0]
+	for _, v := range cells {
+		s := text(v)
+		if _, ok := v.(string); ok {
+			s = escapeFormula(s)
+		}
+		e.record = append(e.record, s)
+	}
+	return e.w.Write(e.record)
+}
+
+func (e *csvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// escapeFormula не даёт табличному редактору принять строку за формулу:
+// перед =, +, -, @ и управляющими символами в начале ставится апостроф
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
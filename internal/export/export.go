@@ -0,0 +1,227 @@
+// Package export пишет заказы в файлы выгрузки: NDJSON, CSV и XLSX. Заказы
+// пишутся по одному, поэтому выгрузка любого размера не держится в памяти целиком.
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"demo-service/internal/model"
+)
+
+// Format - формат выгрузки
+type Format string
+
+const (
+	NDJSON Format = "ndjson"
+	CSV    Format = "csv"
+	XLSX   Format = "xlsx"
+)
+
+// ParseFormat проверяет имя формата, пустое имя - NDJSON
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case "":
+		return NDJSON, nil
+	case NDJSON, CSV, XLSX:
+		return f, nil
+	}
+	return "", fmt.Errorf("неизвестный формат выгрузки %q, ожидался ndjson, csv или xlsx", s)
+}
+
+// ContentType - тип содержимого для ответа HTTP
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case XLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "application/x-ndjson"
+}
+
+// Options - формат и содержимое выгрузки
+type Options struct {
+	Format Format
+	// CSV и XLSX: строка на каждый товар вместо строки на заказ
+	PerItem bool
+	// выгрузить данные доставки без маскирования, см. model.Delivery.Masked
+	Unmasked bool
+}
+
+// Encoder пишет заказы в выгрузку
+type Encoder interface {
+	Encode(o *model.Order) error
+	// Close дописывает конец файла, нижележащий writer не закрывается
+	Close() error
+}
+
+// NewEncoder создаёт Encoder формата opts.Format поверх w
+func NewEncoder(w io.Writer, opts Options) (Encoder, error) {
+	var enc Encoder
+	var err error
+	switch opts.Format {
+	case NDJSON, "":
+		j := json.NewEncoder(w)
+		j.SetEscapeHTML(false)
+		enc = ndjsonEncoder{j}
+	case CSV:
+		enc, err = newCSVEncoder(w, opts.PerItem)
+	case XLSX:
+		enc, err = newXLSXEncoder(w, opts.PerItem)
+	default:
+		return nil, fmt.Errorf("неизвестный формат выгрузки %q", opts.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if opts.Unmasked {
+		return enc, nil
+	}
+	return maskingEncoder{enc}, nil
+}
+
+type maskingEncoder struct {
+	Encoder
+}
+
+func (e maskingEncoder) Encode(o *model.Order) error {
+	return e.Encoder.Encode(o.Masked())
+}
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e ndjsonEncoder) Encode(o *model.Order) error { return e.enc.Encode(o) }
+func (e ndjsonEncoder) Close() error                { return nil }
+
+// rowEncoder пишет таблицу: строки заказа или его товаров
+type rowEncoder interface {
+	writeRow(cells []any) error
+}
+
+// column - столбец табличной выгрузки. value получает товар, если выгрузка
+// построчная по товарам, иначе nil. Значение - string или int64.
+type column struct {
+	name  string
+	value func(o *model.Order, it *model.Item) any
+}
+
+func orderColumn(name string, value func(o *model.Order) any) column {
+	return column{name: name, value: func(o *model.Order, _ *model.Item) any { return value(o) }}
+}
+
+func itemColumn(name string, value func(it *model.Item) any) column {
+	return column{name: name, value: func(_ *model.Order, it *model.Item) any {
+		if it == nil {
+			return ""
+		}
+		return value(it)
+	}}
+}
+
+func num[T int | int64](v T) any { return int64(v) }
+
+var orderColumns = []column{
+	orderColumn("order_uid", func(o *model.Order) any { return o.OrderUID }),
+	orderColumn("date_created", func(o *model.Order) any { return o.DateCreated.UTC().Format(time.RFC3339) }),
+	orderColumn("status", func(o *model.Order) any { return string(o.Status) }),
+	orderColumn("customer_id", func(o *model.Order) any { return o.CustomerID }),
+	orderColumn("track_number", func(o *model.Order) any { return o.TrackNumber }),
+	orderColumn("entry", func(o *model.Order) any { return o.Entry }),
+	orderColumn("locale", func(o *model.Order) any { return o.Locale }),
+	orderColumn("delivery_service", func(o *model.Order) any { return o.DeliveryService }),
+	orderColumn("shardkey", func(o *model.Order) any { return o.Shardkey }),
+	orderColumn("sm_id", func(o *model.Order) any { return num(o.SmID) }),
+	orderColumn("oof_shard", func(o *model.Order) any { return o.OofShard }),
+	orderColumn("delivery_name", func(o *model.Order) any { return o.Delivery.Name }),
+	orderColumn("delivery_phone", func(o *model.Order) any { return o.Delivery.Phone }),
+	orderColumn("delivery_email", func(o *model.Order) any { return o.Delivery.Email }),
+	orderColumn("delivery_zip", func(o *model.Order) any { return o.Delivery.Zip }),
+	orderColumn("delivery_region", func(o *model.Order) any { return o.Delivery.Region }),
+	orderColumn("delivery_city", func(o *model.Order) any { return o.Delivery.City }),
+	orderColumn("delivery_address", func(o *model.Order) any { return o.Delivery.Address }),
+	orderColumn("payment_transaction", func(o *model.Order) any { return o.Payment.Transaction }),
+	orderColumn("payment_request_id", func(o *model.Order) any { return o.Payment.RequestID }),
+	orderColumn("payment_provider", func(o *model.Order) any { return o.Payment.Provider }),
+	orderColumn("payment_bank", func(o *model.Order) any { return o.Payment.Bank }),
+	orderColumn("payment_currency", func(o *model.Order) any { return o.Payment.Currency }),
+	orderColumn("payment_amount", func(o *model.Order) any { return num(o.Payment.Amount) }),
+	orderColumn("payment_delivery_cost", func(o *model.Order) any { return num(o.Payment.DeliveryCost) }),
+	orderColumn("payment_goods_total", func(o *model.Order) any { return num(o.Payment.GoodsTotal) }),
+	orderColumn("payment_custom_fee", func(o *model.Order) any { return num(o.Payment.CustomFee) }),
+	orderColumn("payment_dt", func(o *model.Order) any { return o.Payment.PaymentDt }),
+}
+
+var itemColumns = []column{
+	itemColumn("item_chrt_id", func(it *model.Item) any { return num(it.ChrtID) }),
+	itemColumn("item_nm_id", func(it *model.Item) any { return num(it.NmID) }),
+	itemColumn("item_rid", func(it *model.Item) any { return it.Rid }),
+	itemColumn("item_name", func(it *model.Item) any { return it.Name }),
+	itemColumn("item_brand", func(it *model.Item) any { return it.Brand }),
+	itemColumn("item_size", func(it *model.Item) any { return it.Size }),
+	itemColumn("item_track_number", func(it *model.Item) any { return it.TrackNumber }),
+	itemColumn("item_price", func(it *model.Item) any { return num(it.Price) }),
+	itemColumn("item_sale", func(it *model.Item) any { return num(it.Sale) }),
+	itemColumn("item_total_price", func(it *model.Item) any { return num(it.TotalPrice) }),
+	itemColumn("item_status", func(it *model.Item) any { return num(it.Status) }),
+}
+
+// tableEncoder раскладывает заказы по строкам таблицы: строка на заказ с
+// числом товаров или строка на каждый товар
+type tableEncoder struct {
+	rows    rowEncoder
+	columns []column
+	perItem bool
+	cells   []any
+}
+
+// newTableEncoder пишет строку заголовков и возвращает tableEncoder
+func newTableEncoder(rows rowEncoder, perItem bool) (*tableEncoder, error) {
+	columns := append([]column(nil), orderColumns...)
+	if perItem {
+		columns = append(columns, itemColumns...)
+	} else {
+		columns = append(columns, orderColumn("items_count", func(o *model.Order) any { return num(len(o.Items)) }))
+	}
+	t := &tableEncoder{rows: rows, columns: columns, perItem: perItem, cells: make([]any, len(columns))}
+	for i, c := range columns {
+		t.cells[i] = c.name
+	}
+	return t, rows.writeRow(t.cells)
+}
+
+func (t *tableEncoder) Encode(o *model.Order) error {
+	if !t.perItem || len(o.Items) == 0 {
+		// заказ без товаров в построчной выгрузке - одна строка с пустыми полями товара
+		return t.row(o, nil)
+	}
+	for i := range o.Items {
+		if err := t.row(o, &o.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *tableEncoder) row(o *model.Order, it *model.Item) error {
+	for i, c := range t.columns {
+		t.cells[i] = c.value(o, it)
+	}
+	return t.rows.writeRow(t.cells)
+}
+
+// text переводит значение ячейки в строку
+func text(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,166 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"testing"
+	"time"
+
+	"demo-service/internal/model"
+)
+
+var testOrders = []*model.Order{
+	{
+		OrderUID:    "b563feb7b2b84b6test",
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		Delivery:    model.Delivery{Name: "Test Testov", Phone: "+9720000000", City: "Kiryat Mozkin", Email: "test@gmail.com"},
+		Payment:     model.Payment{Currency: "USD", Amount: 1817},
+		Items: []model.Item{
+			{ChrtID: 9934930, Name: "Mascaras", Brand: "Vivienne Sabo", TotalPrice: 317},
+			{ChrtID: 9934931, Name: "=HYPERLINK(\"x\")", Brand: "<Brand & Co>", TotalPrice: 1500},
+		},
+	},
+	{OrderUID: "empty", Payment: model.Payment{Currency: "RUB"}},
+}
+
+func encodeAll(t *testing.T, opts Options) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc, err := NewEncoder(&buf, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range testOrders {
+		if err := enc.Encode(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// record собирает строку таблицы в map по заголовкам
+func record(header, row []string) map[string]string {
+	m := make(map[string]string, len(header))
+	for i, h := range header {
+		m[h] = row[i]
+	}
+	return m
+}
+
+func TestCSV(t *testing.T) {
+	rows, err := csv.NewReader(bytes.NewReader(encodeAll(t, Options{Format: CSV}))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("ожидались заголовок и 2 заказа, получено строк: %d", len(rows))
+	}
+	first := record(rows[0], rows[1])
+	if first["order_uid"] != "b563feb7b2b84b6test" || first["items_count"] != "2" ||
+		first["payment_amount"] != "1817" || first["date_created"] != "2021-11-26T06:22:19Z" {
+		t.Errorf("неверная строка заказа: %v", first)
+	}
+	// данные доставки маскируются, телефон ещё и защищён от формулы
+	if first["delivery_name"] != "T*** T***" || first["delivery_email"] != "t***@gmail.com" ||
+		first["delivery_phone"] != "'+********00" || first["delivery_city"] != "Kiryat Mozkin" {
+		t.Errorf("данные доставки не маскированы: %v", first)
+	}
+
+	rows, err = csv.NewReader(bytes.NewReader(encodeAll(t, Options{Format: CSV, PerItem: true, Unmasked: true}))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("ожидались заголовок, 2 товара и заказ без товаров, получено строк: %d", len(rows))
+	}
+	item := record(rows[0], rows[2])
+	if item["item_chrt_id"] != "9934931" || item["item_name"] != `'=HYPERLINK("x")` || item["delivery_name"] != "Test Testov" {
+		t.Errorf("неверная строка товара: %v", item)
+	}
+	if empty := record(rows[0], rows[3]); empty["order_uid"] != "empty" || empty["item_chrt_id"] != "" {
+		t.Errorf("заказ без товаров: %v", empty)
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	dec := json.NewDecoder(bytes.NewReader(encodeAll(t, Options{Format: NDJSON})))
+	var got []model.Order
+	for {
+		var o model.Order
+		if err := dec.Decode(&o); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, o)
+	}
+	if len(got) != 2 || got[0].Delivery.Name != "T*** T***" || len(got[0].Items) != 2 {
+		t.Errorf("неверная выгрузка: %+v", got)
+	}
+}
+
+func TestXLSX(t *testing.T) {
+	data := encodeAll(t, Options{Format: XLSX, PerItem: true})
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet []byte
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			sheet, _ = io.ReadAll(rc)
+			rc.Close()
+		}
+	}
+	if len(zr.File) != 5 || sheet == nil {
+		t.Fatalf("неполная книга: %d частей", len(zr.File))
+	}
+
+	var ws struct {
+		Rows []struct {
+			Cells []struct {
+				Type  string `xml:"t,attr"`
+				Value string `xml:"v"`
+				Text  string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.Unmarshal(sheet, &ws); err != nil {
+		t.Fatalf("лист не разбирается: %v", err)
+	}
+	if len(ws.Rows) != 4 {
+		t.Fatalf("ожидалось 4 строки, получено %d", len(ws.Rows))
+	}
+	var header []string
+	for _, c := range ws.Rows[0].Cells {
+		header = append(header, c.Text)
+	}
+	var row []string
+	for _, c := range ws.Rows[2].Cells {
+		row = append(row, c.Text+c.Value)
+	}
+	item := record(header, row)
+	if item["item_brand"] != "<Brand & Co>" || item["item_total_price"] != "1500" || item["delivery_name"] != "T*** T***" {
+		t.Errorf("неверная строка товара: %v", item)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat(""); err != nil || f != NDJSON {
+		t.Errorf("пустой формат: %q, %v", f, err)
+	}
+	if _, err := ParseFormat("xls"); err == nil {
+		t.Error("ожидалась ошибка для неизвестного формата")
+	}
+}
@@ -0,0 +1,112 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// строк на листе XLSX не больше этого, включая заголовок
+const xlsxMaxRows = 1 << 20
+
+// минимальная книга из одного листа: лист пишется последним и потоково,
+// строки хранятся прямо в ячейках (inlineStr), без общей таблицы строк
+var xlsxParts = []struct{ name, body string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="orders" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+const (
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+type xlsxEncoder struct {
+	*tableEncoder
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	rows  int
+	buf   []byte
+}
+
+func newXLSXEncoder(w io.Writer, perItem bool) (Encoder, error) {
+	zw := zip.NewWriter(w)
+	for _, p := range xlsxParts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, p.body); err != nil {
+			return nil, err
+		}
+	}
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	e := &xlsxEncoder{zw: zw, sheet: bufio.NewWriter(f)}
+	e.sheet.WriteString(xlsxSheetStart)
+	t, err := newTableEncoder(e, perItem)
+	if err != nil {
+		return nil, err
+	}
+	e.tableEncoder = t
+	return e, nil
+}
+
+func (e *xlsxEncoder) writeRow(cells []any) error {
+	if e.rows >= xlsxMaxRows {
+		return errors.New("выгрузка не помещается на лист XLSX, сузьте фильтр")
+	}
+	e.rows++
+	b := append(e.buf[:0], `<row r="`...)
+	b = strconv.AppendInt(b, int64(e.rows), 10)
+	b = append(b, `">`...)
+	for _, v := range cells {
+		switch v := v.(type) {
+		case int64:
+			b = append(b, `<c><v>`...)
+			b = strconv.AppendInt(b, v, 10)
+			b = append(b, `</v></c>`...)
+		default:
+			b = append(b, `<c t="inlineStr"><is><t xml:space="preserve">`...)
+			var sb strings.Builder
+			xml.EscapeText(&sb, []byte(text(v)))
+			b = append(b, sb.String()...)
+			b = append(b, `</t></is></c>`...)
+		}
+	}
+	b = append(b, `</row>`...)
+	e.buf = b
+	_, err := e.sheet.Write(b)
+	return err
+}
+
+func (e *xlsxEncoder) Close() error {
+	e.sheet.WriteString(xlsxSheetEnd)
+	if err := e.sheet.Flush(); err != nil {
+		return err
+	}
+	return e.zw.Close()
+}
@@ -0,0 +1,173 @@
+// Package hub - внутрипроцессная рассылка событий о сохранённых заказах.
+package hub
+
+import (
+	"demo-service/internal/model"
+	"sync"
+	"time"
+)
+
+const (
+	// сколько последних событий хранится для возобновления по Last-Event-ID
+	DefaultHistory = 1024
+	// размер очереди подписчика, при переполнении подписка закрывается
+	DefaultBuffer = 256
+)
+
+// Event - событие о сохранённом заказе. ID растёт монотонно в пределах процесса.
+type Event struct {
+	ID    uint64
+	Time  time.Time
+	Order *model.Order
+}
+
+// Filter отбирает события по полям заказа, пустые поля не проверяются
+type Filter struct {
+	CustomerID      string
+	DeliveryService string
+}
+
+func (f Filter) Match(o *model.Order) bool {
+	if f.CustomerID != "" && o.CustomerID != f.CustomerID {
+		return false
+	}
+	if f.DeliveryService != "" && o.DeliveryService != f.DeliveryService {
+		return false
+	}
+	return true
+}
+
+// Subscription - подписка на события. Канал C закрывается при отписке
+// или когда подписчик не успевает читать (тогда Lagged возвращает true).
+type Subscription struct {
+	C <-chan Event
+
+	ch     chan Event
+	filter Filter
+	hub    *Hub
+	lagged bool
+	closed bool
+}
+
+// Lagged сообщает, что подписка закрыта из-за переполнения очереди
+func (s *Subscription) Lagged() bool {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.lagged
+}
+
+// Close отписывает подписчика
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+type Hub struct {
+	mu      sync.Mutex
+	nextID  uint64
+	history []Event
+	start   int
+	size    int
+	buffer  int
+	subs    map[*Subscription]struct{}
+}
+
+func New() *Hub {
+	return NewWithLimits(DefaultHistory, DefaultBuffer)
+}
+
+// NewWithLimits создаёт хаб с заданной глубиной истории и размером очереди подписчика
+func NewWithLimits(history, buffer int) *Hub {
+	if history < 1 {
+		history = 1
+	}
+	if buffer < 1 {
+		buffer = 1
+	}
+	return &Hub{
+		history: make([]Event, history),
+		buffer:  buffer,
+		subs:    make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish рассылает заказ подписчикам. Не блокируется: медленные
+// подписчики отключаются и могут переподключиться с Last-Event-ID.
+func (h *Hub) Publish(order *model.Order) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nextID++
+	ev := Event{ID: h.nextID, Time: time.Now().UTC(), Order: order}
+	h.push(ev)
+
+	for s := range h.subs {
+		if !s.filter.Match(order) {
+			continue
+		}
+		select {
+		case s.ch <- ev:
+		default:
+			s.lagged = true
+			h.remove(s)
+		}
+	}
+}
+
+// Subscribe подписывает на события. Если lastID > 0, сначала в очередь
+// попадают сохранённые события после lastID; если их больше, чем помещается
+// в очередь, отдаются самые свежие.
+func (h *Hub) Subscribe(filter Filter, lastID uint64) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan Event, h.buffer)
+	s := &Subscription{C: ch, ch: ch, filter: filter, hub: h}
+
+	if lastID > 0 {
+		var missed []Event
+		for i := 0; i < h.size; i++ {
+			ev := h.history[(h.start+i)%len(h.history)]
+			if ev.ID > lastID && filter.Match(ev.Order) {
+				missed = append(missed, ev)
+			}
+		}
+		if len(missed) > h.buffer {
+			missed = missed[len(missed)-h.buffer:]
+		}
+		for _, ev := range missed {
+			ch <- ev
+		}
+	}
+
+	h.subs[s] = struct{}{}
+	return s
+}
+
+// LastID - идентификатор последнего опубликованного события
+func (h *Hub) LastID() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.nextID
+}
+
+func (h *Hub) push(ev Event) {
+	if h.size < len(h.history) {
+		h.history[(h.start+h.size)%len(h.history)] = ev
+		h.size++
+		return
+	}
+	h.history[h.start] = ev
+	h.start = (h.start + 1) % len(h.history)
+}
+
+// вызывается под h.mu
+func (h *Hub) remove(s *Subscription) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(h.subs, s)
+	close(s.ch)
+}
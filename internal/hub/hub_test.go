@@ -0,0 +1,63 @@
+package hub
+
+import (
+	"demo-service/internal/model"
+	"testing"
+)
+
+func TestPublish_Filter(t *testing.T) {
+	h := New()
+	sub := h.Subscribe(Filter{CustomerID: "c1"}, 0)
+	defer sub.Close()
+
+	h.Publish(&model.Order{OrderUID: "a", CustomerID: "c2"})
+	h.Publish(&model.Order{OrderUID: "b", CustomerID: "c1"})
+
+	ev := <-sub.C
+	if ev.Order.OrderUID != "b" || ev.ID != 2 {
+		t.Errorf("ожидался заказ b с id 2, получен %s с id %d", ev.Order.OrderUID, ev.ID)
+	}
+	select {
+	case ev := <-sub.C:
+		t.Errorf("лишнее событие %d", ev.ID)
+	default:
+	}
+}
+
+func TestSubscribe_Resume(t *testing.T) {
+	h := NewWithLimits(3, 10)
+	for _, uid := range []string{"a", "b", "c", "d"} {
+		h.Publish(&model.Order{OrderUID: uid})
+	}
+
+	// в истории остались события 2..4, после id 2 - только 3 и 4
+	sub := h.Subscribe(Filter{}, 2)
+	defer sub.Close()
+	for _, want := range []string{"c", "d"} {
+		ev := <-sub.C
+		if ev.Order.OrderUID != want {
+			t.Errorf("ожидался %s, получен %s", want, ev.Order.OrderUID)
+		}
+	}
+}
+
+func TestPublish_SlowSubscriberDropped(t *testing.T) {
+	h := NewWithLimits(10, 2)
+	slow := h.Subscribe(Filter{}, 0)
+
+	for i := 0; i < 3; i++ {
+		h.Publish(&model.Order{OrderUID: "x"})
+	}
+
+	n := 0
+	for range slow.C {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("ожидалось 2 события до отключения, получено %d", n)
+	}
+	if !slow.Lagged() {
+		t.Error("подписка должна быть помечена как отставшая")
+	}
+	slow.Close()
+}
@@ -1,26 +1,69 @@
 package cache
 
 import (
+	"context"
 	"demo-service/internal/model"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// время жизни отметки об отсутствии заказа
+	DefaultMissingTTL = 30 * time.Second
+	// ограничение на число отметок, чтобы поток случайных uid не раздувал память
+	maxMissing = 100000
 )
 
+// Loader загружает заказ из хранилища. (nil, nil) означает, что заказа нет.
+type Loader func(ctx context.Context, orderUID string) (*model.Order, error)
+
 type Cache struct {
-	orders map[string]*model.Order
-	mu     sync.RWMutex
+	orders     map[string]*model.Order
+	missing    map[string]time.Time
+	missingTTL time.Duration
+	group      singleflight.Group
+	mu         sync.RWMutex
+
+	hits, misses, loads, loadErrors atomic.Int64
+}
+
+// Stats - размер кеша и счётчики обращений с момента запуска
+type Stats struct {
+	Orders  int `json:"orders"`
+	Missing int `json:"missing"`
+	// чтения, найденные в кеше, и промахи, включая известные отсутствующие заказы
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	// обращения к хранилищу при промахах и ошибки этих обращений
+	Loads      int64 `json:"loads"`
+	LoadErrors int64 `json:"load_errors"`
 }
 
 func NewCache() *Cache {
 	return &Cache{
-		orders: make(map[string]*model.Order),
+		orders:     make(map[string]*model.Order),
+		missing:    make(map[string]time.Time),
+		missingTTL: DefaultMissingTTL,
 	}
 }
 
+// SetMissingTTL задаёт время жизни негативного кеша, 0 отключает его
+func (c *Cache) SetMissingTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.missingTTL = ttl
+}
+
 func (c *Cache) Set(order *model.Order) bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	delete(c.missing, order.OrderUID)
+
 	if _, exists := c.orders[order.OrderUID]; exists {
 		log.Printf("Заказ %s уже существует в кэше, пропущена перезапись", order.OrderUID)
 		return false
@@ -31,14 +74,212 @@ func (c *Cache) Set(order *model.Order) bool {
 	return true
 }
 
+// Replace кладёт заказ в кеш, заменяя уже существующий
+func (c *Cache) Replace(order *model.Order) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.missing, order.OrderUID)
+	c.orders[order.OrderUID] = order
+}
+
+// Delete убирает заказ из кеша и оставляет отметку об отсутствии,
+// чтобы удалённый заказ не подгрузился обратно до истечения её срока
+func (c *Cache) Delete(orderUID string) {
+	c.mu.Lock()
+	delete(c.orders, orderUID)
+	c.mu.Unlock()
+	c.SetMissing(orderUID)
+}
+
+// Evict убирает заказ из кеша без отметки об отсутствии: следующее
+// чтение загрузит его из хранилища. Возвращает false, если заказа в кеше не было.
+func (c *Cache) Evict(orderUID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, exists := c.orders[orderUID]
+	delete(c.orders, orderUID)
+	delete(c.missing, orderUID)
+	return exists
+}
+
+// Clear очищает кеш вместе с отметками об отсутствии и возвращает число убранных заказов
+func (c *Cache) Clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.orders)
+	c.orders = make(map[string]*model.Order)
+	c.missing = make(map[string]time.Time)
+	return n
+}
+
 func (c *Cache) Get(orderUID string) (*model.Order, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	order, exists := c.orders[orderUID]
 	if exists {
+		c.hits.Add(1)
 		log.Printf("Заказ %s найден в кэше", orderUID)
 	} else {
+		c.misses.Add(1)
 		log.Printf("Заказ %s не найден в кэше", orderUID)
 	}
 	return order, exists
 }
+
+// Stats возвращает размер кеша и счётчики обращений
+func (c *Cache) Stats() Stats {
+	c.mu.RLock()
+	st := Stats{Orders: len(c.orders), Missing: len(c.missing)}
+	c.mu.RUnlock()
+	st.Hits = c.hits.Load()
+	st.Misses = c.misses.Load()
+	st.Loads = c.loads.Load()
+	st.LoadErrors = c.loadErrors.Load()
+	return st
+}
+
+// SetMissing запоминает, что заказа нет в хранилище
+func (c *Cache) SetMissing(orderUID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.missingTTL <= 0 {
+		return
+	}
+	if _, exists := c.orders[orderUID]; exists {
+		return
+	}
+	now := time.Now()
+	if len(c.missing) >= maxMissing {
+		for uid, exp := range c.missing {
+			if now.After(exp) {
+				delete(c.missing, uid)
+			}
+		}
+		if len(c.missing) >= maxMissing {
+			return
+		}
+	}
+	c.missing[orderUID] = now.Add(c.missingTTL)
+}
+
+// IsMissing сообщает, что заказ недавно не был найден в хранилище
+func (c *Cache) IsMissing(orderUID string) bool {
+	c.mu.RLock()
+	exp, exists := c.missing[orderUID]
+	c.mu.RUnlock()
+	if !exists {
+		return false
+	}
+	if time.Now().After(exp) {
+		c.mu.Lock()
+		if exp2, ok := c.missing[orderUID]; ok && exp2 == exp {
+			delete(c.missing, orderUID)
+		}
+		c.mu.Unlock()
+		return false
+	}
+	return true
+}
+
+// GetOrLoad возвращает заказ из кеша, а при промахе загружает его через load.
+// Параллельные промахи по одному uid схлопываются в одну загрузку,
+// отсутствующие заказы запоминаются на missingTTL.
+func (c *Cache) GetOrLoad(ctx context.Context, orderUID string, load Loader) (*model.Order, bool, error) {
+	if order, ok := c.Get(orderUID); ok {
+		return order, true, nil
+	}
+	if c.IsMissing(orderUID) {
+		return nil, false, nil
+	}
+
+	ch := c.group.DoChan(orderUID, func() (any, error) {
+		// загрузка не должна обрываться из-за отмены запроса, который её начал
+		c.loads.Add(1)
+		order, err := load(context.WithoutCancel(ctx), orderUID)
+		if err != nil {
+			c.loadErrors.Add(1)
+			return nil, err
+		}
+		if order == nil {
+			c.SetMissing(orderUID)
+			return nil, nil
+		}
+		c.Set(order)
+		return order, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, false, res.Err
+		}
+		order, _ := res.Val.(*model.Order)
+		return order, order != nil, nil
+	}
+}
+
+// ManyLoader загружает несколько заказов сразу, отсутствующие в результат не попадают
+type ManyLoader func(ctx context.Context, orderUIDs []string) ([]*model.Order, error)
+
+// GetManyOrLoad отдаёт заказы из кеша, а все промахи загружает одним вызовом load.
+// Порядок найденных заказов совпадает с порядком uid, повторы убираются.
+func (c *Cache) GetManyOrLoad(ctx context.Context, orderUIDs []string, load ManyLoader) ([]*model.Order, []string, error) {
+	uids := make([]string, 0, len(orderUIDs))
+	seen := make(map[string]bool, len(orderUIDs))
+	for _, uid := range orderUIDs {
+		if !seen[uid] {
+			seen[uid] = true
+			uids = append(uids, uid)
+		}
+	}
+
+	byUID := make(map[string]*model.Order, len(uids))
+	var misses []string
+	now := time.Now()
+	c.mu.RLock()
+	for _, uid := range uids {
+		if o, ok := c.orders[uid]; ok {
+			byUID[uid] = o
+			continue
+		}
+		if exp, ok := c.missing[uid]; ok && now.Before(exp) {
+			continue
+		}
+		misses = append(misses, uid)
+	}
+	c.mu.RUnlock()
+	c.hits.Add(int64(len(byUID)))
+	c.misses.Add(int64(len(uids) - len(byUID)))
+
+	if len(misses) > 0 {
+		c.loads.Add(1)
+		loaded, err := load(ctx, misses)
+		if err != nil {
+			c.loadErrors.Add(1)
+			return nil, nil, err
+		}
+		for _, o := range loaded {
+			byUID[o.OrderUID] = o
+			c.Set(o)
+		}
+		for _, uid := range misses {
+			if _, ok := byUID[uid]; !ok {
+				c.SetMissing(uid)
+			}
+		}
+	}
+
+	found := make([]*model.Order, 0, len(byUID))
+	var missing []string
+	for _, uid := range uids {
+		if o, ok := byUID[uid]; ok {
+			found = append(found, o)
+		} else {
+			missing = append(missing, uid)
+		}
+	}
+	return found, missing, nil
+}
@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"context"
+	"demo-service/internal/model"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoad_Coalescing(t *testing.T) {
+	c := NewCache()
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	load := func(ctx context.Context, uid string) (*model.Order, error) {
+		calls.Add(1)
+		<-release
+		return &model.Order{OrderUID: uid}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			order, found, err := c.GetOrLoad(context.Background(), "uid-1", load)
+			if err != nil || !found || order.OrderUID != "uid-1" {
+				t.Errorf("неожиданный результат: %v %v %v", order, found, err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("ожидалась 1 загрузка, получено %d", n)
+	}
+	if _, ok := c.Get("uid-1"); !ok {
+		t.Error("заказ должен попасть в кеш")
+	}
+}
+
+func TestGetOrLoad_NegativeCache(t *testing.T) {
+	c := NewCache()
+	var calls atomic.Int32
+	load := func(ctx context.Context, uid string) (*model.Order, error) {
+		calls.Add(1)
+		return nil, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		_, found, err := c.GetOrLoad(context.Background(), "missing", load)
+		if err != nil || found {
+			t.Fatalf("ожидалось отсутствие заказа, получено found=%v err=%v", found, err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("ожидалась 1 загрузка, получено %d", n)
+	}
+
+	// сохранение заказа снимает негативную отметку
+	c.Set(&model.Order{OrderUID: "missing"})
+	if c.IsMissing("missing") {
+		t.Error("отметка об отсутствии должна быть снята после Set")
+	}
+	if _, found, _ := c.GetOrLoad(context.Background(), "missing", load); !found {
+		t.Error("заказ должен находиться после Set")
+	}
+}
+
+func TestGetOrLoad_MissingExpires(t *testing.T) {
+	c := NewCache()
+	c.SetMissingTTL(10 * time.Millisecond)
+	c.SetMissing("uid")
+	if !c.IsMissing("uid") {
+		t.Fatal("ожидалась отметка об отсутствии")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if c.IsMissing("uid") {
+		t.Error("отметка должна истечь")
+	}
+}
+
+func TestGetOrLoad_ErrorNotCached(t *testing.T) {
+	c := NewCache()
+	loadErr := errors.New("db down")
+	load := func(ctx context.Context, uid string) (*model.Order, error) {
+		return nil, loadErr
+	}
+	if _, _, err := c.GetOrLoad(context.Background(), "uid", load); !errors.Is(err, loadErr) {
+		t.Fatalf("ожидалась ошибка загрузки, получено %v", err)
+	}
+	if c.IsMissing("uid") {
+		t.Error("ошибка загрузки не должна попадать в негативный кеш")
+	}
+}
+
+func TestDelete_Tombstone(t *testing.T) {
+	c := NewCache()
+	c.Set(&model.Order{OrderUID: "uid"})
+	c.Delete("uid")
+
+	if _, ok := c.Get("uid"); ok {
+		t.Fatal("заказ должен быть удалён из кеша")
+	}
+	loaded := false
+	_, found, err := c.GetOrLoad(context.Background(), "uid", func(context.Context, string) (*model.Order, error) {
+		loaded = true
+		return &model.Order{OrderUID: "uid"}, nil
+	})
+	if err != nil || found || loaded {
+		t.Errorf("удалённый заказ не должен загружаться: found=%v loaded=%v err=%v", found, loaded, err)
+	}
+}
+
+func TestEvict_Reloads(t *testing.T) {
+	c := NewCache()
+	c.Set(&model.Order{OrderUID: "uid"})
+	if !c.Evict("uid") {
+		t.Fatal("Evict должен сообщить, что заказ был в кеше")
+	}
+	if c.Evict("uid") {
+		t.Error("повторный Evict: заказа в кеше уже нет")
+	}
+
+	_, found, err := c.GetOrLoad(context.Background(), "uid", func(context.Context, string) (*model.Order, error) {
+		return &model.Order{OrderUID: "uid"}, nil
+	})
+	if err != nil || !found {
+		t.Errorf("вытесненный заказ должен загружаться из хранилища: found=%v err=%v", found, err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	c := NewCache()
+	c.Set(&model.Order{OrderUID: "a"})
+	load := func(_ context.Context, uid string) (*model.Order, error) {
+		if uid == "broken" {
+			return nil, errors.New("бд недоступна")
+		}
+		return nil, nil
+	}
+	ctx := context.Background()
+	c.GetOrLoad(ctx, "a", load)
+	c.GetOrLoad(ctx, "none", load)
+	c.GetOrLoad(ctx, "none", load)
+	c.GetOrLoad(ctx, "broken", load)
+	c.GetManyOrLoad(ctx, []string{"a", "b"}, func(context.Context, []string) ([]*model.Order, error) {
+		return []*model.Order{{OrderUID: "b"}}, nil
+	})
+
+	want := Stats{Orders: 2, Missing: 1, Hits: 2, Misses: 4, Loads: 3, LoadErrors: 1}
+	if got := c.Stats(); got != want {
+		t.Errorf("получено %+v, ожидалось %+v", got, want)
+	}
+}
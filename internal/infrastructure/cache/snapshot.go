@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"demo-service/internal/model"
+)
+
+// версия формата снимка, снимок другой версии считается непригодным.
+// 2 - в записи заказа есть updated_at.
+const snapshotVersion = 2
+
+var ErrBadSnapshot = errors.New("снимок кеша повреждён")
+
+// Offsets - следующие смещения Kafka по топикам и разделам
+type Offsets map[string]map[int]int64
+
+// SnapshotInfo описывает снимок кеша
+type SnapshotInfo struct {
+	// момент, с которого изменения в бд могут не попасть в снимок
+	TakenAt time.Time `json:"taken_at"`
+	// смещения Kafka, обработанные к моменту снимка
+	Offsets Offsets `json:"offsets,omitempty"`
+	Orders  int     `json:"orders"`
+}
+
+type snapshotHeader struct {
+	Version int `json:"version"`
+	SnapshotInfo
+}
+
+// snapshotOrder - запись заказа в снимке. В JSON заказа UpdatedAt не
+// передаётся, а без него после загрузки снимка не работают Last-Modified
+// и If-Modified-Since.
+type snapshotOrder struct {
+	*model.Order
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+}
+
+// WriteSnapshot сохраняет заказы кеша в файл: заголовок и по заказу в строке,
+// всё сжато gzip. Файл пишется рядом и переименовывается, поэтому при сбое
+// остаётся предыдущий снимок.
+func (c *Cache) WriteSnapshot(path string, offsets Offsets) (SnapshotInfo, error) {
+	// время берётся до чтения кеша: изменения во время записи догонятся из бд
+	info := SnapshotInfo{TakenAt: time.Now().UTC(), Offsets: offsets}
+	c.mu.RLock()
+	orders := make([]*model.Order, 0, len(c.orders))
+	for _, o := range c.orders {
+		orders = append(orders, o)
+	}
+	c.mu.RUnlock()
+	info.Orders = len(orders)
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return SnapshotInfo{}, fmt.Errorf("не удалось создать снимок кеша: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	bw := bufio.NewWriter(tmp)
+	zw := gzip.NewWriter(bw)
+	enc := json.NewEncoder(zw)
+	if err := enc.Encode(snapshotHeader{Version: snapshotVersion, SnapshotInfo: info}); err != nil {
+		return SnapshotInfo{}, fmt.Errorf("ошибка записи снимка кеша: %w", err)
+	}
+	for _, o := range orders {
+		if err := enc.Encode(snapshotOrder{Order: o, UpdatedAt: o.UpdatedAt}); err != nil {
+			return SnapshotInfo{}, fmt.Errorf("ошибка записи снимка кеша: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return SnapshotInfo{}, fmt.Errorf("ошибка записи снимка кеша: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return SnapshotInfo{}, fmt.Errorf("ошибка записи снимка кеша: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return SnapshotInfo{}, fmt.Errorf("ошибка записи снимка кеша: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return SnapshotInfo{}, fmt.Errorf("ошибка записи снимка кеша: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return SnapshotInfo{}, fmt.Errorf("ошибка записи снимка кеша: %w", err)
+	}
+	return info, nil
+}
+
+// LoadSnapshot заполняет кеш из снимка. Снимок читается целиком до изменения
+// кеша: при повреждённом файле кеш остаётся прежним, а ошибка оборачивает
+// ErrBadSnapshot. Отсутствие файла - os.ErrNotExist.
+func (c *Cache) LoadSnapshot(path string) (SnapshotInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return SnapshotInfo{}, fmt.Errorf("%w: %v", ErrBadSnapshot, err)
+	}
+	dec := json.NewDecoder(zr)
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return SnapshotInfo{}, fmt.Errorf("%w: заголовок: %v", ErrBadSnapshot, err)
+	}
+	if header.Version != snapshotVersion {
+		return SnapshotInfo{}, fmt.Errorf("%w: версия %d, ожидалась %d", ErrBadSnapshot, header.Version, snapshotVersion)
+	}
+
+	orders := make([]*model.Order, 0, header.Orders)
+	for dec.More() {
+		rec := snapshotOrder{Order: &model.Order{}}
+		if err := dec.Decode(&rec); err != nil {
+			return SnapshotInfo{}, fmt.Errorf("%w: заказ %d: %v", ErrBadSnapshot, len(orders)+1, err)
+		}
+		rec.Order.UpdatedAt = rec.UpdatedAt
+		orders = append(orders, rec.Order)
+	}
+	// обрезанный файл: gzip проверяет контрольную сумму только в конце потока
+	if _, err := zr.Read(make([]byte, 1)); err != nil && !errors.Is(err, io.EOF) {
+		return SnapshotInfo{}, fmt.Errorf("%w: %v", ErrBadSnapshot, err)
+	}
+	if len(orders) != header.Orders {
+		return SnapshotInfo{}, fmt.Errorf("%w: заказов %d, в заголовке %d", ErrBadSnapshot, len(orders), header.Orders)
+	}
+
+	c.mu.Lock()
+	for _, o := range orders {
+		delete(c.missing, o.OrderUID)
+		c.orders[o.OrderUID] = o
+	}
+	c.mu.Unlock()
+	return header.SnapshotInfo, nil
+}
+
+// Retain убирает из кеша заказы, которых нет в keep, и возвращает их число
+func (c *Cache) Retain(keep map[string]bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for uid := range c.orders {
+		if !keep[uid] {
+			delete(c.orders, uid)
+			n++
+		}
+	}
+	return n
+}
+
+// RunSnapshots пишет снимок каждые interval до отмены ctx. offsets может быть nil.
+func (c *Cache) RunSnapshots(ctx context.Context, path string, interval time.Duration, offsets func() Offsets) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var off Offsets
+			if offsets != nil {
+				off = offsets()
+			}
+			info, err := c.WriteSnapshot(path, off)
+			if err != nil {
+				log.Printf("Ошибка снимка кеша: %v", err)
+				continue
+			}
+			log.Printf("Снимок кеша записан: %d заказов", info.Orders)
+		}
+	}
+}
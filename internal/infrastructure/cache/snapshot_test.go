@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"demo-service/internal/model"
+)
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	c := NewCache()
+	c.Set(&model.Order{OrderUID: "order-1", Items: []model.Item{{ChrtID: 1, Name: "Mascaras"}}})
+	updated := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	c.Set(&model.Order{OrderUID: "order-2", Status: model.StatusPaid, UpdatedAt: updated})
+
+	info, err := c.WriteSnapshot(path, Offsets{"orders": {0: 42}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Orders != 2 {
+		t.Fatalf("в снимке ожидалось 2 заказа, записано %d", info.Orders)
+	}
+
+	restored := NewCache()
+	got, err := restored.LoadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Orders != 2 || got.Offsets["orders"][0] != 42 || !got.TakenAt.Equal(info.TakenAt) {
+		t.Errorf("неверный заголовок снимка: %+v", got)
+	}
+	o, ok := restored.Get("order-1")
+	if !ok || len(o.Items) != 1 || o.Items[0].Name != "Mascaras" {
+		t.Errorf("заказ не восстановлен: %+v", o)
+	}
+	if o, _ := restored.Get("order-2"); o == nil || o.Status != model.StatusPaid {
+		t.Errorf("статус не восстановлен: %+v", o)
+	}
+	// по UpdatedAt отдаются Last-Modified и 304
+	if o, _ := restored.Get("order-2"); o == nil || !o.UpdatedAt.Equal(updated) {
+		t.Errorf("время изменения не восстановлено: %+v", o)
+	}
+
+	if n := restored.Retain(map[string]bool{"order-2": true}); n != 1 {
+		t.Errorf("ожидалось удаление одного заказа, удалено %d", n)
+	}
+	if _, ok := restored.Get("order-1"); ok {
+		t.Error("order-1 должен быть удалён")
+	}
+}
+
+func TestSnapshot_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCache()
+	if _, err := c.LoadSnapshot(filepath.Join(dir, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("для отсутствующего файла ожидалась os.ErrNotExist, получено %v", err)
+	}
+
+	path := filepath.Join(dir, "cache.snapshot")
+	src := NewCache()
+	for _, uid := range []string{"a", "b", "c"} {
+		src.Set(&model.Order{OrderUID: uid})
+	}
+	if _, err := src.WriteSnapshot(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, broken := range map[string][]byte{
+		"обрезан":  data[:len(data)-10],
+		"не gzip":  []byte("{}"),
+		"испорчен": append(append([]byte{}, data[:len(data)-8]...), 0, 0, 0, 0, 0, 0, 0, 0),
+	} {
+		if err := os.WriteFile(path, broken, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.LoadSnapshot(path); !errors.Is(err, ErrBadSnapshot) {
+			t.Errorf("%s: ожидалась ErrBadSnapshot, получено %v", name, err)
+		}
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("повреждённый снимок не должен менять кеш")
+	}
+}
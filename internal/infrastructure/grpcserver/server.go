@@ -0,0 +1,284 @@
+package grpcserver
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"demo-service/api/orderpb"
+	"demo-service/internal/audit"
+	"demo-service/internal/hub"
+	"demo-service/internal/infrastructure/cache"
+	"demo-service/internal/infrastructure/postgres"
+	"demo-service/internal/model"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// сколько Stop ждёт завершения запросов, прежде чем закрыть соединения
+	stopTimeout     = 10 * time.Second
+	defaultPageSize = 100
+	maxPageSize     = 1000
+	maxBatchSize    = 500
+)
+
+// Store - операции хранилища, нужные gRPC API
+type Store interface {
+	GetOrder(ctx context.Context, orderUID string) (*model.Order, error)
+	GetOrders(ctx context.Context, orderUIDs []string) ([]*model.Order, error)
+	ListOrders(ctx context.Context, f postgres.ListFilter) ([]*model.Order, error)
+}
+
+type Server struct {
+	orderpb.UnimplementedOrderServiceServer
+
+	cache   *cache.Cache
+	store   Store
+	hub     *hub.Hub
+	auditor *audit.Auditor
+	grpc    *grpc.Server
+
+	// закрывается в Stop: GracefulStop не отменяет контекст потоков WatchOrders
+	stopping chan struct{}
+	stopOnce sync.Once
+}
+
+// Option настраивает Server
+type Option func(*Server)
+
+// WithAuditor пишет отданные клиентам заказы в журнал аудита
+func WithAuditor(a *audit.Auditor) Option {
+	return func(s *Server) { s.auditor = a }
+}
+
+func NewServer(cacheStore *cache.Cache, store Store, h *hub.Hub, opts ...Option) *Server {
+	s := &Server{
+		cache:    cacheStore,
+		store:    store,
+		hub:      h,
+		grpc:     grpc.NewServer(),
+		stopping: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	orderpb.RegisterOrderServiceServer(s.grpc, s)
+	return s
+}
+
+func (s *Server) Start(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.Println("gRPC сервер запущен на", addr)
+	return s.Serve(lis)
+}
+
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// Stop завершает потоки WatchOrders и ждёт остальные запросы не дольше
+// stopTimeout, после чего закрывает соединения
+func (s *Server) Stop() {
+	s.stopOnce.Do(func() { close(s.stopping) })
+	done := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(stopTimeout):
+		log.Println("gRPC сервер не дождался завершения запросов, соединения закрываются")
+		s.grpc.Stop()
+		<-done
+	}
+}
+
+func (s *Server) GetOrder(ctx context.Context, req *orderpb.GetOrderRequest) (*orderpb.Order, error) {
+	if req.GetOrderUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_uid обязателен")
+	}
+	order, found, err := s.cache.GetOrLoad(ctx, req.GetOrderUid(), s.loadOrder)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if !found {
+		return nil, toStatus(postgres.ErrNotFound)
+	}
+	s.auditRead(ctx, order)
+	return orderpb.FromModel(order), nil
+}
+
+func (s *Server) ListOrders(ctx context.Context, req *orderpb.ListOrdersRequest) (*orderpb.ListOrdersResponse, error) {
+	size := int(req.GetPageSize())
+	switch {
+	case size < 0:
+		return nil, status.Error(codes.InvalidArgument, "page_size не может быть отрицательным")
+	case size == 0:
+		size = defaultPageSize
+	case size > maxPageSize:
+		size = maxPageSize
+	}
+
+	after, err := base64.RawURLEncoding.DecodeString(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "некорректный page_token")
+	}
+
+	// лишняя запись показывает, что есть следующая страница
+	orders, err := s.store.ListOrders(ctx, postgres.ListFilter{
+		CustomerID:      req.GetCustomerId(),
+		DeliveryService: req.GetDeliveryService(),
+		After:           string(after),
+		Limit:           size + 1,
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &orderpb.ListOrdersResponse{}
+	if len(orders) > size {
+		orders = orders[:size]
+		resp.NextPageToken = base64.RawURLEncoding.EncodeToString([]byte(orders[size-1].OrderUID))
+	}
+	for _, o := range orders {
+		s.auditRead(ctx, o)
+		resp.Orders = append(resp.Orders, orderpb.FromModel(o))
+	}
+	return resp, nil
+}
+
+func (s *Server) BatchGetOrders(ctx context.Context, req *orderpb.BatchGetOrdersRequest) (*orderpb.BatchGetOrdersResponse, error) {
+	if len(req.GetOrderUids()) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "не больше %d order_uid за запрос", maxBatchSize)
+	}
+	found, missing, err := s.cache.GetManyOrLoad(ctx, req.GetOrderUids(), s.store.GetOrders)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &orderpb.BatchGetOrdersResponse{MissingOrderUids: missing}
+	for _, o := range found {
+		s.auditRead(ctx, o)
+		resp.Orders = append(resp.Orders, orderpb.FromModel(o))
+	}
+	return resp, nil
+}
+
+func (s *Server) WatchOrders(req *orderpb.WatchOrdersRequest, stream grpc.ServerStreamingServer[orderpb.OrderEvent]) error {
+	if s.hub == nil {
+		return status.Error(codes.Unavailable, "поток заказов не настроен")
+	}
+
+	sub := s.hub.Subscribe(hub.Filter{
+		CustomerID:      req.GetCustomerId(),
+		DeliveryService: req.GetDeliveryService(),
+	}, req.GetLastEventId())
+	defer sub.Close()
+
+	s.auditor.Record(stream.Context(), audit.Event{
+		Actor:      caller(stream.Context()),
+		Action:     audit.ActionOrderStream,
+		CustomerID: req.GetCustomerId(),
+		Fields:     orderFields,
+		Details:    fmt.Sprintf("delivery_service=%q, last_event_id=%d", req.GetDeliveryService(), req.GetLastEventId()),
+	})
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.stopping:
+			return status.Error(codes.Unavailable, "сервер останавливается, переподключитесь с last_event_id")
+		case ev, ok := <-sub.C:
+			if !ok {
+				if sub.Lagged() {
+					return status.Error(codes.ResourceExhausted, "клиент не успевает читать поток, переподключитесь с last_event_id")
+				}
+				return nil
+			}
+			err := stream.Send(&orderpb.OrderEvent{
+				Id:    ev.ID,
+				Time:  timestamppb.New(ev.Time),
+				Order: orderpb.FromModel(ev.Order),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// поля, которые отдаёт orderpb.Order
+var orderFields = audit.FieldsOf(model.Order{})
+
+func (s *Server) auditRead(ctx context.Context, order *model.Order) {
+	s.auditor.Record(ctx, audit.Event{
+		Actor:      caller(ctx),
+		Action:     audit.ActionOrderRead,
+		OrderUID:   order.OrderUID,
+		CustomerID: order.CustomerID,
+		Fields:     orderFields,
+	})
+}
+
+// caller - адрес источника вызова. Метаданные x-client-id не проверяются,
+// поэтому вызов остаётся анонимным, а клиент дописывается как подсказка.
+func caller(ctx context.Context) string {
+	who := "anonymous"
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get("x-client-id"); len(ids) > 0 && ids[0] != "" {
+			who = "anonymous:" + ids[0]
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		who += "@" + host
+	}
+	return who
+}
+
+// загрузка заказа для кеша, отсутствие заказа не считается ошибкой
+func (s *Server) loadOrder(ctx context.Context, uid string) (*model.Order, error) {
+	order, err := s.store.GetOrder(ctx, uid)
+	if errors.Is(err, postgres.ErrNotFound) {
+		return nil, nil
+	}
+	return order, err
+}
+
+// toStatus сопоставляет ошибку хранилища с кодом gRPC так же, как HTTP API
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, postgres.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, postgres.ErrConflict):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, postgres.ErrInvalid):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		log.Printf("Ошибка gRPC запроса: %v", err)
+		return status.Error(codes.Unavailable, "хранилище недоступно")
+	}
+}
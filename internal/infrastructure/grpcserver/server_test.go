@@ -0,0 +1,220 @@
+package grpcserver
+
+import (
+	"context"
+	"demo-service/api/orderpb"
+	"demo-service/internal/hub"
+	"demo-service/internal/infrastructure/cache"
+	"demo-service/internal/infrastructure/postgres"
+	"demo-service/internal/model"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeStore - хранилище в памяти вместо postgres
+type fakeStore struct {
+	orders map[string]*model.Order
+}
+
+func (f *fakeStore) GetOrder(ctx context.Context, uid string) (*model.Order, error) {
+	if o, ok := f.orders[uid]; ok {
+		return o, nil
+	}
+	return nil, postgres.ErrNotFound
+}
+
+func (f *fakeStore) GetOrders(ctx context.Context, uids []string) ([]*model.Order, error) {
+	var out []*model.Order
+	for _, uid := range uids {
+		if o, ok := f.orders[uid]; ok {
+			out = append(out, o)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeStore) ListOrders(ctx context.Context, flt postgres.ListFilter) ([]*model.Order, error) {
+	var uids []string
+	for uid, o := range f.orders {
+		if uid > flt.After && (flt.CustomerID == "" || o.CustomerID == flt.CustomerID) {
+			uids = append(uids, uid)
+		}
+	}
+	sort.Strings(uids)
+	if len(uids) > flt.Limit {
+		uids = uids[:flt.Limit]
+	}
+	return f.GetOrders(ctx, uids)
+}
+
+func setupClient(t *testing.T, store Store, h *hub.Hub) orderpb.OrderServiceClient {
+	lis := bufconn.Listen(1 << 20)
+	srv := NewServer(cache.NewCache(), store, h)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Ошибка подключения: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return orderpb.NewOrderServiceClient(conn)
+}
+
+func newStore(uids ...string) *fakeStore {
+	f := &fakeStore{orders: make(map[string]*model.Order)}
+	for _, uid := range uids {
+		f.orders[uid] = &model.Order{
+			OrderUID:    uid,
+			CustomerID:  "c-" + uid,
+			DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+			Items:       []model.Item{{ChrtID: 1, Price: 453}},
+		}
+	}
+	return f
+}
+
+func TestGetOrder(t *testing.T) {
+	client := setupClient(t, newStore("a"), nil)
+	ctx := context.Background()
+
+	got, err := client.GetOrder(ctx, &orderpb.GetOrderRequest{OrderUid: "a"})
+	if err != nil {
+		t.Fatalf("Ошибка GetOrder: %v", err)
+	}
+	if got.GetOrderUid() != "a" || len(got.GetItems()) != 1 || got.GetItems()[0].GetPrice() != 453 {
+		t.Errorf("неожиданный заказ: %v", got)
+	}
+	if !got.GetDateCreated().AsTime().Equal(time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)) {
+		t.Errorf("неожиданная date_created: %v", got.GetDateCreated())
+	}
+
+	_, err = client.GetOrder(ctx, &orderpb.GetOrderRequest{OrderUid: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("ожидался NotFound, получено %v", err)
+	}
+}
+
+func TestListOrders_Pagination(t *testing.T) {
+	client := setupClient(t, newStore("a", "b", "c"), nil)
+	ctx := context.Background()
+
+	var got []string
+	token := ""
+	for {
+		resp, err := client.ListOrders(ctx, &orderpb.ListOrdersRequest{PageSize: 2, PageToken: token})
+		if err != nil {
+			t.Fatalf("Ошибка ListOrders: %v", err)
+		}
+		for _, o := range resp.GetOrders() {
+			got = append(got, o.GetOrderUid())
+		}
+		if token = resp.GetNextPageToken(); token == "" {
+			break
+		}
+	}
+	if len(got) != 3 || got[0] != "a" || got[2] != "c" {
+		t.Errorf("неожиданный список: %v", got)
+	}
+}
+
+func TestBatchGetOrders(t *testing.T) {
+	client := setupClient(t, newStore("a", "b"), nil)
+
+	resp, err := client.BatchGetOrders(context.Background(), &orderpb.BatchGetOrdersRequest{
+		OrderUids: []string{"b", "x", "a", "b"},
+	})
+	if err != nil {
+		t.Fatalf("Ошибка BatchGetOrders: %v", err)
+	}
+	if len(resp.GetOrders()) != 2 || resp.GetOrders()[0].GetOrderUid() != "b" {
+		t.Errorf("неожиданные заказы: %v", resp.GetOrders())
+	}
+	if len(resp.GetMissingOrderUids()) != 1 || resp.GetMissingOrderUids()[0] != "x" {
+		t.Errorf("неожиданные отсутствующие: %v", resp.GetMissingOrderUids())
+	}
+}
+
+func TestWatchOrders(t *testing.T) {
+	h := hub.New()
+	client := setupClient(t, newStore(), h)
+
+	h.Publish(&model.Order{OrderUID: "old", CustomerID: "c1"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	stream, err := client.WatchOrders(ctx, &orderpb.WatchOrdersRequest{CustomerId: "c1", LastEventId: 0})
+	if err != nil {
+		t.Fatalf("Ошибка WatchOrders: %v", err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		h.Publish(&model.Order{OrderUID: "skip", CustomerID: "c2"})
+		h.Publish(&model.Order{OrderUID: "new", CustomerID: "c1"})
+	}()
+
+	ev, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Ошибка чтения потока: %v", err)
+	}
+	if ev.GetOrder().GetOrderUid() != "new" || ev.GetId() != 3 {
+		t.Errorf("ожидался заказ new с id 3, получен %v", ev)
+	}
+}
+
+func TestStop_OpenWatchStream(t *testing.T) {
+	h := hub.New()
+	lis := bufconn.Listen(1 << 20)
+	srv := NewServer(cache.NewCache(), newStore(), h)
+	go srv.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Ошибка подключения: %v", err)
+	}
+	defer conn.Close()
+
+	stream, err := orderpb.NewOrderServiceClient(conn).WatchOrders(context.Background(), &orderpb.WatchOrdersRequest{})
+	if err != nil {
+		t.Fatalf("Ошибка WatchOrders: %v", err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		h.Publish(&model.Order{OrderUID: "new"})
+	}()
+	// первое событие пришло - поток открыт на сервере
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Ошибка чтения потока: %v", err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		srv.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stop завис на открытом потоке WatchOrders")
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("ожидался код Unavailable, получено %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"demo-service/internal/audit"
+	"demo-service/internal/infrastructure/kafka"
+
+	"github.com/gorilla/mux"
+)
+
+// requireAdmin пропускает только запросы с Authorization: Bearer <токен>.
+// Без настроенного токена административный API выключен.
+func (s *Server) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken == "" {
+			writeProblem(w, r, http.StatusForbidden, "административный API выключен")
+			return
+		}
+		if !bearerIs(r, s.adminToken) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeProblem(w, r, http.StatusUnauthorized, "нужен токен администратора")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) registerAdminRoutes() {
+	admin := s.router.PathPrefix("/admin").Subrouter()
+	admin.Use(s.requireAdmin)
+
+	admin.HandleFunc("/replay", s.handleStartReplay).Methods("POST")
+	admin.HandleFunc("/replay", s.handleListReplays).Methods("GET")
+	admin.HandleFunc("/replay/{id}", s.handleGetReplay).Methods("GET")
+	admin.HandleFunc("/replay/{id}", s.handleCancelReplay).Methods("DELETE")
+	admin.HandleFunc("/consumer", s.handleConsumerStatus).Methods("GET")
+	admin.HandleFunc("/consumer/metrics", s.handleConsumerMetrics).Methods("GET")
+	admin.HandleFunc("/consumer/pause", s.handlePauseConsumer(true)).Methods("POST")
+	admin.HandleFunc("/consumer/resume", s.handlePauseConsumer(false)).Methods("POST")
+	admin.HandleFunc("/consumer/seek", s.handleSeekConsumer).Methods("POST")
+	admin.HandleFunc("/customers/{customer_id}/erase", s.handleEraseCustomer).Methods("POST")
+	admin.HandleFunc("/cache", s.handleCacheStats).Methods("GET")
+	admin.HandleFunc("/cache", s.handleClearCache).Methods("DELETE")
+	admin.HandleFunc("/cache/reload", s.handleReloadCache).Methods("POST")
+	admin.HandleFunc("/cache/{order_uid}", s.handleEvictOrder).Methods("DELETE")
+}
+
+func (s *Server) handleStartReplay(w http.ResponseWriter, r *http.Request) {
+	if s.replayer == nil {
+		writeProblem(w, r, http.StatusServiceUnavailable, "переобработка не настроена")
+		return
+	}
+
+	var req kafka.ReplayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "некорректный JSON: "+err.Error())
+		return
+	}
+
+	progress, err := s.replayer.Start(r.Context(), req)
+	if errors.Is(err, kafka.ErrInvalidReplay) {
+		writeProblem(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запуска переобработки: %v", err)
+		writeProblem(w, r, http.StatusServiceUnavailable, "Kafka недоступна")
+		return
+	}
+	s.auditor.Record(r.Context(), audit.Event{
+		Actor:   s.caller(r),
+		Action:  audit.ActionReplayStart,
+		Details: fmt.Sprintf("задача %s, сообщений %d, dry_run=%v", progress.ID, progress.Total, progress.DryRun),
+	})
+	w.Header().Set("Location", "/admin/replay/"+progress.ID)
+	writeJSONStatus(w, http.StatusAccepted, progress)
+}
+
+func (s *Server) handleListReplays(w http.ResponseWriter, r *http.Request) {
+	if s.replayer == nil {
+		writeProblem(w, r, http.StatusServiceUnavailable, "переобработка не настроена")
+		return
+	}
+	writeJSON(w, s.replayer.List())
+}
+
+func (s *Server) handleGetReplay(w http.ResponseWriter, r *http.Request) {
+	if s.replayer == nil {
+		writeProblem(w, r, http.StatusServiceUnavailable, "переобработка не настроена")
+		return
+	}
+	progress, err := s.replayer.Get(mux.Vars(r)["id"])
+	if errors.Is(err, kafka.ErrReplayNotFound) {
+		writeProblem(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, progress)
+}
+
+func (s *Server) handleCancelReplay(w http.ResponseWriter, r *http.Request) {
+	if s.replayer == nil {
+		writeProblem(w, r, http.StatusServiceUnavailable, "переобработка не настроена")
+		return
+	}
+	id := mux.Vars(r)["id"]
+	if err := s.replayer.Cancel(id); errors.Is(err, kafka.ErrReplayNotFound) {
+		writeProblem(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+	s.auditor.Record(r.Context(), audit.Event{
+		Actor:   s.caller(r),
+		Action:  audit.ActionReplayCancel,
+		Details: "задача " + id,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleConsumerMetrics(w http.ResponseWriter, r *http.Request) {
+	if s.consumer == nil {
+		writeProblem(w, r, http.StatusServiceUnavailable, "consumer не настроен")
+		return
+	}
+	writeJSON(w, s.consumer.Metrics().Snapshot())
+}
@@ -0,0 +1,104 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"demo-service/internal/infrastructure/cache"
+	"demo-service/internal/model"
+)
+
+func TestAdminAuth(t *testing.T) {
+	cases := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{"API выключен", "", "Bearer secret", http.StatusForbidden},
+		{"без токена", "secret", "", http.StatusUnauthorized},
+		{"неверный токен", "secret", "Bearer wrong", http.StatusUnauthorized},
+		// токен верный, но переобработка не настроена
+		{"верный токен", "secret", "Bearer secret", http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range cases {
+		server := NewServer(nil, nil, WithAdminToken(tc.token))
+		req := httptest.NewRequest("GET", "/admin/replay", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Errorf("%s: ожидался код %d, получен %d", tc.name, tc.want, rr.Code)
+		}
+	}
+}
+
+func TestDeleteOrder_RequiresAdmin(t *testing.T) {
+	server := NewServer(nil, nil, WithAdminToken("secret"))
+	for _, req := range []*http.Request{
+		httptest.NewRequest("DELETE", "/order/b563feb7b2b84b6test", nil),
+		httptest.NewRequest("POST", "/admin/customers/test/erase", nil),
+	} {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s: ожидался код 401, получен %d", req.Method, req.URL.Path, rr.Code)
+		}
+	}
+}
+
+func TestAdminCache_Evict(t *testing.T) {
+	c := cache.NewCache()
+	c.Set(&model.Order{OrderUID: "a"})
+	c.Set(&model.Order{OrderUID: "b"})
+	server := NewServer(c, nil, WithAdminToken("secret"))
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do("DELETE", "/admin/cache/a"); rr.Code != http.StatusNoContent {
+		t.Errorf("вытеснение: ожидался код 204, получен %d", rr.Code)
+	}
+	if rr := do("DELETE", "/admin/cache/a"); rr.Code != http.StatusNotFound {
+		t.Errorf("повторное вытеснение: ожидался код 404, получен %d", rr.Code)
+	}
+	rr := do("DELETE", "/admin/cache")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"removed":1`) {
+		t.Errorf("очистка: код %d, тело %s", rr.Code, rr.Body)
+	}
+}
+
+func TestAdminConsumer_NotConfigured(t *testing.T) {
+	c := cache.NewCache()
+	c.Set(&model.Order{OrderUID: "a"})
+	server := NewServer(c, nil, WithAdminToken("secret"))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, path := range []string{"/admin/consumer/pause", "/admin/consumer/resume", "/admin/consumer/seek"} {
+		if rr := do("POST", path, `{"topic":"orders","partition":0,"offset":1}`); rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s без consumer: ожидался код 503, получен %d", path, rr.Code)
+		}
+	}
+	if rr := do("GET", "/admin/consumer", ""); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("состояние без consumer: ожидался код 503, получен %d", rr.Code)
+	}
+	rr := do("GET", "/admin/cache", "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"orders":1`) {
+		t.Errorf("статистика кеша: код %d, тело %s", rr.Code, rr.Body)
+	}
+}
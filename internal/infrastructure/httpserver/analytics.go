@@ -0,0 +1,240 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"demo-service/internal/infrastructure/postgres"
+	"demo-service/internal/model"
+)
+
+const (
+	// период отчёта без from
+	defaultAnalyticsPeriod = 30 * 24 * time.Hour
+	defaultAnalyticsLimit  = 10
+	maxAnalyticsLimit      = 1000
+)
+
+// WithAnalyticsRollups переключает /analytics на дневные сводки, их обновление
+// запускается отдельно, см. postgres.RefreshAnalytics
+func WithAnalyticsRollups(enabled bool) Option {
+	return func(s *Server) { s.analyticsRollups = enabled }
+}
+
+// WithExchangeRates добавляет в /analytics/sales итоги периода, пересчитанные
+// в одну валюту: ?currency=, по умолчанию базовая валюта таблицы
+func WithExchangeRates(rates *model.RateTable) Option {
+	return func(s *Server) { s.rates = rates }
+}
+
+// salesConverted - итоги периода в одной валюте
+type salesConverted struct {
+	Currency model.Currency `json:"currency"`
+	Orders   int64          `json:"orders"`
+	// в минимальных единицах валюты
+	Revenue model.Money `json:"revenue"`
+	// валюты без курса, их заказы и выручка не учтены
+	MissingRates []string `json:"missing_rates,omitempty"`
+}
+
+// convertTotals пересчитывает итоги по валютам в валюту to
+func convertTotals(rates model.ExchangeRates, to model.Currency, totals []postgres.SalesPoint) salesConverted {
+	out := salesConverted{Currency: to, Revenue: model.Money{Currency: to}}
+	for _, t := range totals {
+		m, err := model.FromMajor(t.Revenue, t.Currency)
+		if err == nil {
+			m, err = rates.Convert(m, to)
+		}
+		if err != nil {
+			out.MissingRates = append(out.MissingRates, t.Currency)
+			continue
+		}
+		out.Orders += t.Orders
+		out.Revenue.Amount += m.Amount
+	}
+	return out
+}
+
+// reportCurrency разбирает ?currency= для пересчёта итогов, пустая валюта -
+// пересчёт не нужен
+func (s *Server) reportCurrency(r *http.Request) (model.Currency, error) {
+	v := r.URL.Query().Get("currency")
+	if s.rates == nil {
+		if v != "" {
+			return "", errors.New("currency: пересчёт валют не настроен")
+		}
+		return "", nil
+	}
+	if v == "" {
+		return s.rates.Base(), nil
+	}
+	c, err := model.ParseCurrency(v)
+	if err != nil {
+		return "", errors.New("currency: " + err.Error())
+	}
+	if _, err := s.rates.Convert(model.Money{Currency: s.rates.Base()}, c); err != nil {
+		return "", errors.New("currency: " + err.Error())
+	}
+	return c, nil
+}
+
+// analyticsReport - общие поля ответов /analytics. Период - тот, что попал
+// в отчёт: по сводкам он расширен до целых суток.
+type analyticsReport struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// live - посчитано по заказам, rollup - по дневным сводкам
+	Source string `json:"source"`
+}
+
+// analyticsFilter разбирает период from, to (RFC 3339), по умолчанию -
+// последние 30 дней
+func (s *Server) analyticsFilter(r *http.Request) (postgres.AnalyticsFilter, analyticsReport, error) {
+	q := r.URL.Query()
+	f := postgres.AnalyticsFilter{Rollup: s.analyticsRollups}
+	var err error
+	if f.From, err = parseTimeParam(q.Get("from")); err != nil {
+		return f, analyticsReport{}, errors.New("from: ожидалось время RFC 3339")
+	}
+	if f.To, err = parseTimeParam(q.Get("to")); err != nil {
+		return f, analyticsReport{}, errors.New("to: ожидалось время RFC 3339")
+	}
+	if f.To.IsZero() {
+		f.To = time.Now().UTC()
+	}
+	if f.From.IsZero() {
+		f.From = f.To.Add(-defaultAnalyticsPeriod)
+	}
+	if !f.From.Before(f.To) {
+		return f, analyticsReport{}, errors.New("from должен быть раньше to")
+	}
+	rep := analyticsReport{Source: "live"}
+	rep.From, rep.To = f.Period()
+	if f.Rollup {
+		rep.Source = "rollup"
+	}
+	return f, rep, nil
+}
+
+func analyticsLimit(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return defaultAnalyticsLimit, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 || n > maxAnalyticsLimit {
+		return 0, errors.New("limit должен быть целым от 1 до " + strconv.Itoa(maxAnalyticsLimit))
+	}
+	return n, nil
+}
+
+// handleSales отдаёт число заказов, выручку, средний чек и среднее число
+// товаров по валютам: ряд с шагом bucket=day|week|month и итоги периода.
+// С настроенными курсами итоги также пересчитываются в одну валюту.
+func (s *Server) handleSales(w http.ResponseWriter, r *http.Request) {
+	f, rep, err := s.analyticsFilter(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	bucket, err := postgres.ParseBucket(r.URL.Query().Get("bucket"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	currency, err := s.reportCurrency(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	series, totals, err := s.store.Sales(r.Context(), f, bucket)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	var converted *salesConverted
+	if currency != "" {
+		c := convertTotals(s.rates, currency, totals)
+		converted = &c
+	}
+	writeJSON(w, struct {
+		analyticsReport
+		Bucket    postgres.Bucket       `json:"bucket"`
+		Totals    []postgres.SalesPoint `json:"totals"`
+		Converted *salesConverted       `json:"converted,omitempty"`
+		Series    []postgres.SalesPoint `json:"series"`
+	}{rep, bucket, totals, converted, series})
+}
+
+// handleBreakdown отдаёт limit значений разреза by=delivery_service|region|city
+// с наибольшим числом заказов, их долю и выручку по валютам
+func (s *Server) handleBreakdown(w http.ResponseWriter, r *http.Request) {
+	f, rep, err := s.analyticsFilter(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := analyticsLimit(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	dim := postgres.Dimension(r.URL.Query().Get("by"))
+	switch dim {
+	case "":
+		dim = postgres.DimDeliveryService
+	case postgres.DimDeliveryService, postgres.DimRegion, postgres.DimCity:
+	default:
+		writeProblem(w, r, http.StatusBadRequest, "by: ожидалось delivery_service, region или city")
+		return
+	}
+
+	rows, err := s.store.Breakdown(r.Context(), f, dim, limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, struct {
+		analyticsReport
+		By   postgres.Dimension   `json:"by"`
+		Rows []postgres.Breakdown `json:"rows"`
+	}{rep, dim, rows})
+}
+
+// handleTopItems отдаёт limit брендов или артикулов (by=brand|nm_id)
+// с наибольшим числом проданных товаров
+func (s *Server) handleTopItems(w http.ResponseWriter, r *http.Request) {
+	f, rep, err := s.analyticsFilter(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := analyticsLimit(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	dim := postgres.Dimension(r.URL.Query().Get("by"))
+	switch dim {
+	case "":
+		dim = postgres.DimBrand
+	case postgres.DimBrand, postgres.DimNmID:
+	default:
+		writeProblem(w, r, http.StatusBadRequest, "by: ожидалось brand или nm_id")
+		return
+	}
+
+	rows, err := s.store.TopItems(r.Context(), f, dim, limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, struct {
+		analyticsReport
+		By   postgres.Dimension `json:"by"`
+		Rows []postgres.TopItem `json:"rows"`
+	}{rep, dim, rows})
+}
@@ -0,0 +1,69 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"demo-service/internal/infrastructure/postgres"
+	"demo-service/internal/model"
+)
+
+func TestAnalytics_BadParams(t *testing.T) {
+	server := NewServer(nil, nil)
+	for _, url := range []string{
+		"/analytics/sales?bucket=year",
+		"/analytics/sales?from=yesterday",
+		"/analytics/sales?from=2024-02-01T00:00:00Z&to=2024-01-01T00:00:00Z",
+		"/analytics/breakdown?by=country",
+		"/analytics/breakdown?limit=0",
+		"/analytics/top?by=size",
+		"/analytics/top?limit=100000",
+	} {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался код 400, получен %d", url, rr.Code)
+		}
+	}
+}
+
+func TestConvertTotals(t *testing.T) {
+	rates, err := model.NewRateTable("RUB", map[string]string{"USD": "92.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := convertTotals(rates, "RUB", []postgres.SalesPoint{
+		{Currency: "RUB", Orders: 3, Revenue: 1000},
+		{Currency: "USD", Orders: 2, Revenue: 10},
+		{Currency: "EUR", Orders: 1, Revenue: 5},
+		{Currency: "", Orders: 1},
+	})
+	want := salesConverted{Currency: "RUB", Orders: 5, Revenue: model.Money{Amount: 192500, Currency: "RUB"}, MissingRates: []string{"EUR", ""}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("получено %+v, ожидалось %+v", got, want)
+	}
+}
+
+func TestSales_Currency(t *testing.T) {
+	rates, err := model.NewRateTable("RUB", map[string]string{"USD": "92.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		rates *model.RateTable
+		query string
+	}{
+		{nil, "?currency=RUB"},
+		{rates, "?currency=XYZ"},
+		{rates, "?currency=EUR"},
+	} {
+		server := NewServer(nil, nil, WithExchangeRates(tc.rates))
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/analytics/sales"+tc.query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался код 400, получен %d", tc.query, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package httpserver
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"demo-service/internal/audit"
+	"demo-service/internal/model"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// поля, которые отдаёт GET /order/<uid>
+var orderFields = audit.FieldsOf(model.Order{})
+
+// поля заказа, отданного с маскированными данными доставки, см. model.Order.Masked
+var maskedOrderFields = maskedFields(orderFields)
+
+func maskedFields(fields []string) []string {
+	out := make([]string, len(fields))
+	for i, f := range fields {
+		if f == "delivery" {
+			f = "delivery:masked"
+		}
+		out[i] = f
+	}
+	return out
+}
+
+// bearerIs сверяет токен из Authorization: Bearer с ожидаемым
+func bearerIs(r *http.Request, want string) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && want != "" && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1
+}
+
+// caller - кто сделал запрос, для журнала аудита: администратор или аудитор
+// по токену, иначе anonymous, вместе с адресом источника. Заголовок X-Client-ID
+// никто не проверяет, поэтому он дописывается только как подсказка: anonymous:<id>.
+func (s *Server) caller(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	who := "anonymous"
+	switch {
+	case bearerIs(r, s.adminToken):
+		who = "admin"
+	case bearerIs(r, s.auditToken):
+		who = "auditor"
+	case r.Header.Get("X-Client-ID") != "":
+		who = "anonymous:" + r.Header.Get("X-Client-ID")
+	}
+	return who + "@" + host
+}
+
+// auditRead записывает отданный клиенту заказ и отданные поля
+func (s *Server) auditRead(r *http.Request, order *model.Order, fields []string) {
+	s.auditor.Record(r.Context(), audit.Event{
+		Actor:      s.caller(r),
+		Action:     audit.ActionOrderRead,
+		OrderUID:   order.OrderUID,
+		CustomerID: order.CustomerID,
+		Fields:     fields,
+	})
+}
+
+// statusWriter запоминает код ответа, чтобы понять, было ли отдано тело
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// requireAuditor пускает к журналу аудита по токену аудитора или администратора
+func (s *Server) requireAuditor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.auditToken == "" && s.adminToken == "" {
+			writeProblem(w, r, http.StatusForbidden, "журнал аудита выключен")
+			return
+		}
+		if !bearerIs(r, s.auditToken) && !bearerIs(r, s.adminToken) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="audit"`)
+			writeProblem(w, r, http.StatusUnauthorized, "нужен токен аудитора")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) registerAuditRoutes() {
+	a := s.router.PathPrefix("/audit").Subrouter()
+	a.Use(s.requireAuditor)
+	a.HandleFunc("/events", s.handleAuditEvents).Methods("GET")
+	a.HandleFunc("/verify", s.handleAuditVerify).Methods("GET")
+}
+
+type auditPage struct {
+	Events []audit.Event `json:"events"`
+	// следующая страница: ?after_id=<next_after_id>
+	NextAfterID int64 `json:"next_after_id,omitempty"`
+}
+
+// handleAuditEvents отдаёт журнал по фильтрам order_uid, customer_id, actor,
+// action, from, to (RFC 3339) постранично через after_id и limit
+func (s *Server) handleAuditEvents(w http.ResponseWriter, r *http.Request) {
+	if s.auditor == nil {
+		writeProblem(w, r, http.StatusServiceUnavailable, "журнал аудита не настроен")
+		return
+	}
+	q := r.URL.Query()
+	query := audit.Query{
+		OrderUID:   q.Get("order_uid"),
+		CustomerID: q.Get("customer_id"),
+		Actor:      q.Get("actor"),
+		Action:     q.Get("action"),
+		Limit:      defaultAuditLimit,
+	}
+	var err error
+	if query.From, err = parseTimeParam(q.Get("from")); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "from: ожидалось время в формате RFC 3339")
+		return
+	}
+	if query.To, err = parseTimeParam(q.Get("to")); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "to: ожидалось время в формате RFC 3339")
+		return
+	}
+	if v := q.Get("after_id"); v != "" {
+		if query.AfterID, err = strconv.ParseInt(v, 10, 64); err != nil || query.AfterID < 0 {
+			writeProblem(w, r, http.StatusBadRequest, "after_id должен быть неотрицательным целым")
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit <= 0 || query.Limit > maxAuditLimit {
+			writeProblem(w, r, http.StatusBadRequest, "limit должен быть от 1 до "+strconv.Itoa(maxAuditLimit))
+			return
+		}
+	}
+
+	events, err := s.auditor.Query(r.Context(), query)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	page := auditPage{Events: events}
+	if len(events) == query.Limit {
+		page.NextAfterID = events[len(events)-1].ID
+	}
+	writeJSON(w, page)
+}
+
+// handleAuditVerify проверяет цепочку хешей всего журнала
+func (s *Server) handleAuditVerify(w http.ResponseWriter, r *http.Request) {
+	if s.auditor == nil {
+		writeProblem(w, r, http.StatusServiceUnavailable, "журнал аудита не настроен")
+		return
+	}
+	res, err := s.auditor.Verify(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, res)
+}
+
+func parseTimeParam(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
@@ -0,0 +1,62 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"demo-service/internal/audit"
+	"demo-service/internal/infrastructure/cache"
+)
+
+func TestAudit_OrderRead(t *testing.T) {
+	sink, err := audit.OpenFile(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	auditor := audit.New(sink)
+
+	c := cache.NewCache()
+	order := makeTestOrder("test-audit")
+	c.Set(&order)
+	server := NewServer(c, nil, WithAuditor(auditor), WithAuditToken("audit"))
+
+	req := httptest.NewRequest("GET", "/order/"+order.OrderUID, nil)
+	req.Header.Set("X-Client-ID", "web")
+	server.router.ServeHTTP(httptest.NewRecorder(), req)
+	// HEAD не отдаёт данные и в журнал не попадает
+	server.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("HEAD", "/order/"+order.OrderUID, nil))
+	auditor.Close()
+
+	get := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/audit/events?order_uid="+order.OrderUID, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := get(""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("без токена ожидался код 401, получен %d", rr.Code)
+	}
+
+	rr := get("audit")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ожидался код 200, получен %d: %s", rr.Code, rr.Body)
+	}
+	var page auditPage
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Events) != 1 {
+		t.Fatalf("ожидалась одна запись чтения, получено %+v", page.Events)
+	}
+	e := page.Events[0]
+	if e.Action != audit.ActionOrderRead || e.Actor != "anonymous:web@192.0.2.1" || len(e.Fields) == 0 || e.Hash == "" {
+		t.Errorf("неверная запись аудита: %+v", e)
+	}
+}
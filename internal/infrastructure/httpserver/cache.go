@@ -0,0 +1,64 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"demo-service/internal/audit"
+
+	"github.com/gorilla/mux"
+)
+
+type cacheResult struct {
+	// заказов загружено или заменено
+	Updated int `json:"updated"`
+	// заказов убрано из кеша
+	Removed int `json:"removed"`
+}
+
+func (s *Server) handleCacheStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.cache.Stats())
+}
+
+// handleEvictOrder убирает заказ из кеша, следующее чтение загрузит его из бд
+func (s *Server) handleEvictOrder(w http.ResponseWriter, r *http.Request) {
+	uid := mux.Vars(r)["order_uid"]
+	if !s.cache.Evict(uid) {
+		writeProblem(w, r, http.StatusNotFound, fmt.Sprintf("заказа %s нет в кеше", uid))
+		return
+	}
+	s.auditor.Record(r.Context(), audit.Event{
+		Actor:    s.caller(r),
+		Action:   audit.ActionCacheEvict,
+		OrderUID: uid,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleClearCache очищает кеш целиком, заказы загружаются из бд по мере чтения
+func (s *Server) handleClearCache(w http.ResponseWriter, r *http.Request) {
+	n := s.cache.Clear()
+	s.auditor.Record(r.Context(), audit.Event{
+		Actor:   s.caller(r),
+		Action:  audit.ActionCacheEvict,
+		Details: fmt.Sprintf("кеш очищен, заказов: %d", n),
+	})
+	writeJSON(w, cacheResult{Removed: n})
+}
+
+// handleReloadCache сверяет кеш с бд: все заказы перечитываются, удалённые
+// убираются. Кеш не очищается, поэтому чтения во время перезагрузки не идут в бд.
+func (s *Server) handleReloadCache(w http.ResponseWriter, r *http.Request) {
+	updated, removed, err := s.store.CatchUpCache(r.Context(), s.cache, time.Time{})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	s.auditor.Record(r.Context(), audit.Event{
+		Actor:   s.caller(r),
+		Action:  audit.ActionCacheReload,
+		Details: fmt.Sprintf("загружено %d, убрано %d", updated, removed),
+	})
+	writeJSON(w, cacheResult{Updated: updated, Removed: removed})
+}
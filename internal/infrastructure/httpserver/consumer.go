@@ -0,0 +1,96 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"demo-service/internal/audit"
+	"demo-service/internal/infrastructure/kafka"
+)
+
+type seekRequest struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	Offset    *int64 `json:"offset"`
+}
+
+// handleConsumerStatus показывает паузы, назначение разделов и смещения группы
+func (s *Server) handleConsumerStatus(w http.ResponseWriter, r *http.Request) {
+	if s.consumer == nil {
+		writeProblem(w, r, http.StatusServiceUnavailable, "consumer не настроен")
+		return
+	}
+	st, err := s.consumer.Status(r.Context())
+	if err != nil {
+		log.Printf("Ошибка получения состояния consumer: %v", err)
+		writeProblem(w, r, http.StatusServiceUnavailable, "Kafka недоступна")
+		return
+	}
+	writeJSON(w, st)
+}
+
+// handlePauseConsumer приостанавливает или возобновляет чтение топика из
+// параметра topic, без него - всех топиков
+func (s *Server) handlePauseConsumer(pause bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.consumer == nil {
+			writeProblem(w, r, http.StatusServiceUnavailable, "consumer не настроен")
+			return
+		}
+		topic := r.URL.Query().Get("topic")
+		action, set := audit.ActionConsumerResume, s.consumer.Resume
+		if pause {
+			action, set = audit.ActionConsumerPause, s.consumer.Pause
+		}
+		if err := set(topic); err != nil {
+			writeProblem(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		details := "все топики"
+		if topic != "" {
+			details = "топик " + topic
+		}
+		s.auditor.Record(r.Context(), audit.Event{Actor: s.caller(r), Action: action, Details: details})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleSeekConsumer переводит группу на смещение в разделе топика
+func (s *Server) handleSeekConsumer(w http.ResponseWriter, r *http.Request) {
+	if s.consumer == nil {
+		writeProblem(w, r, http.StatusServiceUnavailable, "consumer не настроен")
+		return
+	}
+	var req seekRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "некорректный JSON: "+err.Error())
+		return
+	}
+	if req.Topic == "" || req.Offset == nil {
+		writeProblem(w, r, http.StatusUnprocessableEntity, "нужны topic, partition и offset")
+		return
+	}
+
+	err := s.consumer.Seek(r.Context(), req.Topic, req.Partition, *req.Offset)
+	switch {
+	case errors.Is(err, kafka.ErrUnknownTopic):
+		writeProblem(w, r, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, kafka.ErrInvalidSeek):
+		writeProblem(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	case err != nil:
+		log.Printf("Ошибка смены смещения %s/%d: %v", req.Topic, req.Partition, err)
+		writeProblem(w, r, http.StatusServiceUnavailable, "не удалось сменить смещение: "+err.Error())
+		return
+	}
+	s.auditor.Record(r.Context(), audit.Event{
+		Actor:   s.caller(r),
+		Action:  audit.ActionConsumerSeek,
+		Details: fmt.Sprintf("%s/%d: смещение %d", req.Topic, req.Partition, *req.Offset),
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,56 @@
+package httpserver
+
+import (
+	"demo-service/internal/infrastructure/cache"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetOrder_ConditionalRequests(t *testing.T) {
+	c := cache.NewCache()
+	order := makeTestOrder("test-etag")
+	order.UpdatedAt = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	c.Set(&order)
+
+	server := NewServer(c, nil, WithCacheControl("private, max-age=5"))
+
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/order/"+order.OrderUID, nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := get("", "")
+	if first.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d", first.Code)
+	}
+	tag := first.Header().Get("ETag")
+	if tag == "" || tag[0] != '"' {
+		t.Fatalf("ожидался сильный ETag, получен %q", tag)
+	}
+	if got := first.Header().Get("Last-Modified"); got != "Wed, 01 May 2024 12:00:00 GMT" {
+		t.Errorf("неожиданный Last-Modified %q", got)
+	}
+	if got := first.Header().Get("Cache-Control"); got != "private, max-age=5" {
+		t.Errorf("неожиданный Cache-Control %q", got)
+	}
+
+	if rr := get("If-None-Match", tag); rr.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match: ожидался код 304, получен %d", rr.Code)
+	}
+	if rr := get("If-None-Match", `"other"`); rr.Code != http.StatusOK {
+		t.Errorf("If-None-Match с другим тегом: ожидался код 200, получен %d", rr.Code)
+	}
+	if rr := get("If-Modified-Since", "Wed, 01 May 2024 12:00:00 GMT"); rr.Code != http.StatusNotModified {
+		t.Errorf("If-Modified-Since: ожидался код 304, получен %d", rr.Code)
+	}
+	if rr := get("If-Modified-Since", "Tue, 30 Apr 2024 12:00:00 GMT"); rr.Code != http.StatusOK {
+		t.Errorf("If-Modified-Since в прошлом: ожидался код 200, получен %d", rr.Code)
+	}
+}
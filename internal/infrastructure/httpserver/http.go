@@ -1,65 +1,237 @@
 package httpserver
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"sync"
+	"time"
 
+	"demo-service/internal/audit"
+	"demo-service/internal/hub"
 	"demo-service/internal/infrastructure/cache"
+	"demo-service/internal/infrastructure/kafka"
 	"demo-service/internal/infrastructure/postgres"
+	"demo-service/internal/model"
+	"demo-service/web"
 
 	"github.com/gorilla/mux"
 )
 
 type Server struct {
-	cache  *cache.Cache
-	store  *postgres.Postgres
-	router *mux.Router
+	cache        *cache.Cache
+	store        *postgres.Postgres
+	router       *mux.Router
+	cacheControl string
+	hub          *hub.Hub
+	heartbeat    time.Duration
+	replayer     *kafka.Replayer
+	consumer     *kafka.KafkaConsumer
+	auditor      *audit.Auditor
+	adminToken   string
+	auditToken   string
+	// отчёты /analytics по дневным сводкам, а не по заказам
+	analyticsRollups bool
+	// курсы для итогов /analytics/sales в одной валюте, nil - без пересчёта
+	rates *model.RateTable
+
+	mu  sync.Mutex
+	srv *http.Server
+	// закрывается при Shutdown: потоки заказов сами не завершаются
+	stopping chan struct{}
+}
+
+// Option настраивает Server
+type Option func(*Server)
+
+// WithCacheControl задаёт заголовок Cache-Control для ответов с заказами,
+// пустая строка отключает заголовок
+func WithCacheControl(value string) Option {
+	return func(s *Server) { s.cacheControl = value }
+}
+
+// WithReplayer подключает переобработку сообщений Kafka через /admin/replay
+func WithReplayer(r *kafka.Replayer) Option {
+	return func(s *Server) { s.replayer = r }
+}
+
+// WithConsumer подключает управление consumer и его метрики в /admin/consumer
+func WithConsumer(c *kafka.KafkaConsumer) Option {
+	return func(s *Server) { s.consumer = c }
+}
+
+// WithAuditor подключает журнал аудита, без него действия не записываются
+func WithAuditor(a *audit.Auditor) Option {
+	return func(s *Server) { s.auditor = a }
+}
+
+// WithAuditToken задаёт токен аудитора для чтения журнала /audit
+func WithAuditToken(token string) Option {
+	return func(s *Server) { s.auditToken = token }
+}
+
+// WithAdminToken задаёт токен для /admin, пустой токен выключает административный API
+func WithAdminToken(token string) Option {
+	return func(s *Server) { s.adminToken = token }
 }
 
-func NewServer(cacheStore *cache.Cache, store *postgres.Postgres) *Server {
+// WithHub подключает поток новых заказов /orders/stream
+func WithHub(h *hub.Hub) Option {
+	return func(s *Server) { s.hub = h }
+}
+
+func NewServer(cacheStore *cache.Cache, store *postgres.Postgres, opts ...Option) *Server {
 	s := &Server{
-		cache:  cacheStore,
-		store:  store,
-		router: mux.NewRouter(),
+		cache:        cacheStore,
+		store:        store,
+		router:       mux.NewRouter(),
+		cacheControl: "no-cache",
+		heartbeat:    defaultHeartbeat,
+		stopping:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
-	s.router.HandleFunc("/order/{order_uid}", s.handleGetOrder).Methods("GET")
-	s.router.HandleFunc("/", s.handleUserOrder).Methods("GET")
+	s.router.HandleFunc("/order/{order_uid}", s.handleGetOrder).Methods("GET", "HEAD")
+	s.router.Handle("/order/{order_uid}", s.requireAdmin(http.HandlerFunc(s.handleDeleteOrder))).Methods("DELETE")
+	s.router.Handle("/order/{order_uid}/status", s.requireAdmin(http.HandlerFunc(s.handleUpdateStatus))).Methods("PATCH")
+	s.router.HandleFunc("/order/{order_uid}/status/history", s.handleStatusHistory).Methods("GET")
+	s.router.HandleFunc("/orders", s.handleListOrders).Methods("GET")
+	s.router.HandleFunc("/orders/stream", s.handleOrderStream).Methods("GET")
+	s.router.HandleFunc("/orders/export", s.handleExportOrders).Methods("GET")
+	s.router.HandleFunc("/orders/batch-get", s.handleBatchGet).Methods("POST")
+	s.router.Handle("/reports/reconciliation", s.requireAdmin(http.HandlerFunc(s.handleReconciliation))).Methods("GET")
+	s.router.HandleFunc("/analytics/sales", s.handleSales).Methods("GET")
+	s.router.HandleFunc("/analytics/breakdown", s.handleBreakdown).Methods("GET")
+	s.router.HandleFunc("/analytics/top", s.handleTopItems).Methods("GET")
+	s.registerAdminRoutes()
+	s.registerAuditRoutes()
+	s.router.PathPrefix("/assets/").Handler(http.FileServerFS(web.Files)).Methods("GET", "HEAD")
+	s.router.HandleFunc("/", s.handleUserOrder).Methods("GET", "HEAD")
+	s.router.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	s.router.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowedHandler)
 	return s
 }
 
 func (s *Server) handleGetOrder(w http.ResponseWriter, r *http.Request) {
 	uid := mux.Vars(r)["order_uid"]
 
-	if order, ok := s.cache.Get(uid); ok {
-		writeJSON(w, order)
+	order, found, err := s.cache.GetOrLoad(r.Context(), uid, s.loadOrder)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !found {
+		log.Println("Заказ не найден:", uid)
+		writeError(w, r, postgres.ErrNotFound)
 		return
 	}
 
-	order, err := s.store.GetOrder(r.Context(), uid)
+	if s.writeOrder(w, r, order) {
+		s.auditRead(r, order, orderFields)
+	}
+}
+
+// writeOrder отдаёт заказ с ETag и Last-Modified. Условные заголовки
+// If-None-Match/If-Modified-Since обрабатывает http.ServeContent и отвечает 304.
+// Возвращает true, если тело заказа отдано клиенту.
+func (s *Server) writeOrder(w http.ResponseWriter, r *http.Request, order *model.Order) bool {
+	body, err := json.Marshal(order)
 	if err != nil {
-		log.Println("Заказ не найден:", uid, err)
-		http.Error(w, "Заказ не найден", http.StatusNotFound)
-		return
+		log.Println("Ошибка при записи JSON:", err)
+		writeProblem(w, r, http.StatusInternalServerError, internalDetail)
+		return false
 	}
+	body = append(body, '\n')
+
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	h.Set("ETag", etag(body))
+	if s.cacheControl != "" {
+		h.Set("Cache-Control", s.cacheControl)
+	}
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	http.ServeContent(sw, r, "", order.UpdatedAt, bytes.NewReader(body))
+	return r.Method != http.MethodHead && sw.status == http.StatusOK
+}
 
-	s.cache.Set(order)
-	writeJSON(w, order)
+// сильный ETag по содержимому ответа
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
 }
 
+// загрузка заказа из бд для кеша, отсутствие заказа не считается ошибкой
+func (s *Server) loadOrder(ctx context.Context, uid string) (*model.Order, error) {
+	order, err := s.store.GetOrder(ctx, uid)
+	if errors.Is(err, postgres.ErrNotFound) {
+		return nil, nil
+	}
+	return order, err
+}
+
+// handleUserOrder отдаёт веб-интерфейс, встроенный в бинарник: сервис
+// не зависит от рабочего каталога
 func (s *Server) handleUserOrder(w http.ResponseWriter, r *http.Request) {
-	http.ServeFile(w, r, "web/index.html")
+	http.ServeFileFS(w, r, web.Files, "index.html")
 }
 
+// Start принимает запросы до Shutdown
 func (s *Server) Start(addr string) error {
+	srv := &http.Server{Addr: addr, Handler: s.router}
+	srv.RegisterOnShutdown(func() { close(s.stopping) })
+	s.mu.Lock()
+	s.srv = srv
+	s.mu.Unlock()
+
 	log.Println("Сервер запущен на", addr)
-	return http.ListenAndServe(addr, s.router)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown перестаёт принимать соединения, завершает потоки заказов и ждёт
+// остальные запросы до отмены ctx, после чего закрывает оставшиеся соединения
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	srv := s.srv
+	s.mu.Unlock()
+	if srv == nil {
+		return nil
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		srv.Close()
+		return err
+	}
+	return nil
 }
 
 func writeJSON(w http.ResponseWriter, data any) {
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(data); err != nil {
+	writeJSONStatus(w, http.StatusOK, data)
+}
+
+func writeJSONStatus(w http.ResponseWriter, status int, data any) {
+	// кодируем заранее, чтобы при ошибке ещё можно было отдать problem+json
+	body, err := json.Marshal(data)
+	if err != nil {
 		log.Println("Ошибка при записи JSON:", err)
-		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(Problem{
+			Type:   "about:blank",
+			Title:  http.StatusText(http.StatusInternalServerError),
+			Status: http.StatusInternalServerError,
+			Detail: internalDetail,
+		})
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
 }
@@ -0,0 +1,64 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+
+	"demo-service/internal/audit"
+
+	"github.com/gorilla/mux"
+)
+
+// handleDeleteOrder мягко удаляет заказ: в бд он помечается удалённым,
+// а в кеше остаётся отметка об отсутствии
+func (s *Server) handleDeleteOrder(w http.ResponseWriter, r *http.Request) {
+	uid := mux.Vars(r)["order_uid"]
+	if err := s.store.DeleteOrder(r.Context(), uid); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	s.cache.Delete(uid)
+	s.auditor.Record(r.Context(), audit.Event{
+		Actor:    s.caller(r),
+		Action:   audit.ActionOrderDelete,
+		OrderUID: uid,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type eraseResponse struct {
+	CustomerID string   `json:"customer_id"`
+	Orders     []string `json:"orders"`
+}
+
+// handleEraseCustomer стирает данные доставки во всех заказах покупателя
+func (s *Server) handleEraseCustomer(w http.ResponseWriter, r *http.Request) {
+	customerID := mux.Vars(r)["customer_id"]
+	uids, err := s.store.EraseCustomer(r.Context(), customerID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// в кеше не должно остаться копий со стёртыми данными
+	orders, err := s.store.GetOrders(r.Context(), uids)
+	if err != nil {
+		for _, uid := range uids {
+			s.cache.Delete(uid)
+		}
+	}
+	for _, o := range orders {
+		s.cache.Replace(o)
+	}
+
+	s.auditor.Record(r.Context(), audit.Event{
+		Actor:      s.caller(r),
+		Action:     audit.ActionCustomerErase,
+		CustomerID: customerID,
+		Details:    fmt.Sprintf("заказов: %d", len(uids)),
+	})
+	if uids == nil {
+		uids = []string{}
+	}
+	writeJSON(w, eraseResponse{CustomerID: customerID, Orders: uids})
+}
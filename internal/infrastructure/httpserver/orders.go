@@ -0,0 +1,151 @@
+package httpserver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"demo-service/internal/infrastructure/postgres"
+	"demo-service/internal/model"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+	// не больше стольких order_uid в одном POST /orders/batch-get
+	maxBatchGet = 500
+	// предел тела POST /orders/batch-get: с запасом на maxBatchGet длинных order_uid
+	maxBatchGetBody = 256 << 10
+)
+
+type ordersPage struct {
+	Orders []*model.Order `json:"orders"`
+	// пусто - страница последняя
+	NextPageToken string `json:"next_page_token,omitempty"`
+}
+
+// handleListOrders отдаёт заказы по order_uid постранично: фильтры customer_id
+// и delivery_service, размер page_size, следующая страница - page_token из ответа.
+// Каждая страница читается из бд, кеш не используется. Данные доставки
+// маскируются, если запрос без токена администратора.
+func (s *Server) handleListOrders(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	size := defaultPageSize
+	if v := q.Get("page_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeProblem(w, r, http.StatusBadRequest, "page_size должен быть положительным целым")
+			return
+		}
+		size = min(n, maxPageSize)
+	}
+	after, err := base64.RawURLEncoding.DecodeString(q.Get("page_token"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "некорректный page_token")
+		return
+	}
+
+	// лишняя запись показывает, что есть следующая страница
+	orders, err := s.store.ListOrders(r.Context(), postgres.ListFilter{
+		CustomerID:      q.Get("customer_id"),
+		DeliveryService: q.Get("delivery_service"),
+		After:           string(after),
+		Limit:           size + 1,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	page := ordersPage{Orders: orders}
+	if len(orders) > size {
+		page.Orders = orders[:size]
+		page.NextPageToken = base64.RawURLEncoding.EncodeToString([]byte(orders[size-1].OrderUID))
+	}
+	if page.Orders == nil {
+		page.Orders = []*model.Order{}
+	}
+	var fields []string
+	page.Orders, fields = s.maskUnlessAdmin(r, page.Orders)
+	for _, o := range page.Orders {
+		s.auditRead(r, o, fields)
+	}
+	writeJSON(w, page)
+}
+
+// orderMask возвращает, как отдавать заказы вызывающему: без токена
+// администратора данные доставки маскируются. fields - отдаваемые поля
+// для журнала аудита.
+func (s *Server) orderMask(r *http.Request) (mask func(*model.Order) *model.Order, fields []string) {
+	if bearerIs(r, s.adminToken) {
+		return func(o *model.Order) *model.Order { return o }, orderFields
+	}
+	return (*model.Order).Masked, maskedOrderFields
+}
+
+// maskUnlessAdmin маскирует данные доставки заказов, если запрос
+// без токена администратора, и возвращает отдаваемые поля
+func (s *Server) maskUnlessAdmin(r *http.Request, orders []*model.Order) ([]*model.Order, []string) {
+	mask, fields := s.orderMask(r)
+	out := make([]*model.Order, len(orders))
+	for i, o := range orders {
+		out[i] = mask(o)
+	}
+	return out, fields
+}
+
+type batchGetRequest struct {
+	OrderUIDs []string `json:"order_uids"`
+}
+
+type batchGetResponse struct {
+	// в порядке запроса, без повторов
+	Orders  []*model.Order `json:"orders"`
+	Missing []string       `json:"missing"`
+}
+
+// handleBatchGet отдаёт несколько заказов за запрос: найденные в кеше - сразу,
+// остальные загружаются из бд одним обращением
+func (s *Server) handleBatchGet(w http.ResponseWriter, r *http.Request) {
+	var req batchGetRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchGetBody)).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeProblem(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("тело запроса больше %d байт", maxBatchGetBody))
+			return
+		}
+		writeProblem(w, r, http.StatusBadRequest, "некорректный JSON: "+err.Error())
+		return
+	}
+	switch {
+	case len(req.OrderUIDs) == 0:
+		writeProblem(w, r, http.StatusBadRequest, "не указаны order_uids")
+		return
+	case len(req.OrderUIDs) > maxBatchGet:
+		writeProblem(w, r, http.StatusBadRequest, fmt.Sprintf("не больше %d order_uid за запрос", maxBatchGet))
+		return
+	}
+	for _, uid := range req.OrderUIDs {
+		if uid == "" {
+			writeProblem(w, r, http.StatusBadRequest, "пустой order_uid")
+			return
+		}
+	}
+
+	found, missing, err := s.cache.GetManyOrLoad(r.Context(), req.OrderUIDs, s.store.GetOrders)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if missing == nil {
+		missing = []string{}
+	}
+	found, fields := s.maskUnlessAdmin(r, found)
+	for _, o := range found {
+		s.auditRead(r, o, fields)
+	}
+	writeJSON(w, batchGetResponse{Orders: found, Missing: missing})
+}
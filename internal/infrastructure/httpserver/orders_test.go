@@ -0,0 +1,144 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"demo-service/internal/infrastructure/cache"
+	"demo-service/internal/model"
+)
+
+func TestListOrders_BadParams(t *testing.T) {
+	server := NewServer(nil, nil)
+	for _, q := range []string{"?page_size=0", "?page_size=abc", "?page_token=!!!"} {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/orders"+q, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался код 400, получен %d", q, rr.Code)
+		}
+	}
+}
+
+func TestMaskUnlessAdmin(t *testing.T) {
+	server := NewServer(nil, nil, WithAdminToken("secret"))
+	orders := []*model.Order{{OrderUID: "order-1", Delivery: model.Delivery{Name: "Test Testov", Phone: "+9720000000"}}}
+
+	req := httptest.NewRequest("GET", "/orders", nil)
+	got, fields := server.maskUnlessAdmin(req, orders)
+	if got[0].Delivery != orders[0].Delivery.Masked() {
+		t.Errorf("без токена данные не замаскированы: %+v", got[0].Delivery)
+	}
+	if !slices.Contains(fields, "delivery:masked") || slices.Contains(fields, "delivery") {
+		t.Errorf("в журнал должны попасть маскированные поля: %v", fields)
+	}
+	if orders[0].Delivery.Name != "Test Testov" {
+		t.Error("маскирование изменило исходный заказ")
+	}
+
+	req.Header.Set("Authorization", "Bearer secret")
+	got, fields = server.maskUnlessAdmin(req, orders)
+	if got[0].Delivery.Name != "Test Testov" {
+		t.Errorf("администратор получил замаскированные данные: %+v", got[0].Delivery)
+	}
+	if !slices.Contains(fields, "delivery") {
+		t.Errorf("администратору отданы полные данные доставки: %v", fields)
+	}
+}
+
+func TestExportOrders_BadParams(t *testing.T) {
+	server := NewServer(nil, nil, WithAdminToken("secret"))
+	for q, want := range map[string]int{
+		"?format=xls":          http.StatusBadRequest,
+		"?rows=customer":       http.StatusBadRequest,
+		"?status=lost":         http.StatusBadRequest,
+		"?from=2024-01-01":     http.StatusBadRequest,
+		"?pii=none":            http.StatusBadRequest,
+		"?format=csv&pii=full": http.StatusForbidden,
+	} {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/orders/export"+q, nil))
+		if rr.Code != want {
+			t.Errorf("%s: ожидался код %d, получен %d", q, want, rr.Code)
+		}
+	}
+}
+
+func TestBatchGet_FromCache(t *testing.T) {
+	c := cache.NewCache()
+	c.Set(&model.Order{OrderUID: "order-1"})
+	c.Set(&model.Order{OrderUID: "order-3"})
+	// известный промах не идёт в бд, поэтому хранилище не нужно
+	c.SetMissing("order-2")
+	server := NewServer(c, nil)
+
+	body := `{"order_uids": ["order-3", "order-2", "order-1", "order-3"]}`
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("POST", "/orders/batch-get", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ожидался код 200, получен %d: %s", rr.Code, rr.Body)
+	}
+	var resp batchGetResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	var uids []string
+	for _, o := range resp.Orders {
+		uids = append(uids, o.OrderUID)
+	}
+	if !reflect.DeepEqual(uids, []string{"order-3", "order-1"}) || !reflect.DeepEqual(resp.Missing, []string{"order-2"}) {
+		t.Errorf("найдены %v, не найдены %v", uids, resp.Missing)
+	}
+}
+
+func TestBatchGet_BadRequest(t *testing.T) {
+	server := NewServer(cache.NewCache(), nil)
+	many, _ := json.Marshal(map[string][]string{"order_uids": strings.Split(strings.Repeat("x,", maxBatchGet)+"x", ",")})
+	for _, body := range []string{`{`, `{}`, `{"order_uids": []}`, `{"order_uids": [""]}`, string(many)} {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("POST", "/orders/batch-get", strings.NewReader(body)))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался код 400, получен %d", fmt.Sprintf("%.40s", body), rr.Code)
+		}
+	}
+}
+
+func TestBatchGet_BodyTooLarge(t *testing.T) {
+	server := NewServer(cache.NewCache(), nil)
+	body := `{"order_uids": ["` + strings.Repeat("x", maxBatchGetBody) + `"]}`
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("POST", "/orders/batch-get", strings.NewReader(body)))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("ожидался код 413, получен %d", rr.Code)
+	}
+}
+
+func TestWebUI_Embedded(t *testing.T) {
+	server := NewServer(nil, nil)
+	for path, want := range map[string]string{
+		"/":               "text/html",
+		"/assets/app.js":  "javascript",
+		"/assets/app.css": "text/css",
+	} {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: ожидался код 200, получен %d", path, rr.Code)
+			continue
+		}
+		if ct := rr.Header().Get("Content-Type"); !strings.Contains(ct, want) {
+			t.Errorf("%s: Content-Type %q, ожидался %s", path, ct, want)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/assets/missing.js", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("ожидался код 404 для отсутствующего файла, получен %d", rr.Code)
+	}
+}
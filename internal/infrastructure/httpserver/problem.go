@@ -0,0 +1,91 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"demo-service/internal/infrastructure/postgres"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Problem - тело ошибки в формате RFC 7807
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// statusFor сопоставляет ошибку хранилища с HTTP-статусом
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, postgres.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, postgres.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, postgres.ErrInvalid):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
+
+// текст ответа на внутренние ошибки, подробности только в журнале сервиса
+const internalDetail = "внутренняя ошибка сервиса"
+
+// writeError отвечает problem+json по ошибке хранилища. Клиенту отдаётся
+// текст только ErrNotFound, ErrConflict и ErrInvalid, и то без текста
+// ошибки postgres внутри. Остальное логируется.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := statusFor(err)
+	var pgErr *pgconn.PgError
+	switch {
+	case status == http.StatusServiceUnavailable:
+		log.Printf("Ошибка обработки %s %s: %v", r.Method, r.URL.Path, err)
+		writeProblem(w, r, status, internalDetail)
+	case errors.As(err, &pgErr):
+		// ограничение схемы: в тексте имена таблиц и значения
+		log.Printf("Ошибка обработки %s %s: %v", r.Method, r.URL.Path, err)
+		writeProblem(w, r, status, storageError(err).Error())
+	default:
+		writeProblem(w, r, status, err.Error())
+	}
+}
+
+// storageError возвращает ошибку хранилища, которую оборачивает err
+func storageError(err error) error {
+	for _, target := range []error{postgres.ErrNotFound, postgres.ErrConflict, postgres.ErrInvalid} {
+		if errors.Is(err, target) {
+			return target
+		}
+	}
+	return err
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	p := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Println("Ошибка при записи problem+json:", err)
+	}
+}
+
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusNotFound, "ресурс не найден")
+}
+
+func methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusMethodNotAllowed, "метод не поддерживается")
+}
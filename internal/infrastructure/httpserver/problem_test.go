@@ -0,0 +1,82 @@
+package httpserver
+
+import (
+	"demo-service/internal/infrastructure/postgres"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestWriteError_StatusMapping(t *testing.T) {
+	cases := []struct {
+		err  error
+		want int
+	}{
+		{postgres.ErrNotFound, http.StatusNotFound},
+		{fmt.Errorf("ошибка добавления заказа: %w", postgres.ErrConflict), http.StatusConflict},
+		{fmt.Errorf("%w: пустой order_uid", postgres.ErrInvalid), http.StatusUnprocessableEntity},
+		{errors.New("connection refused"), http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", "/order/x", nil)
+		rr := httptest.NewRecorder()
+		writeError(rr, req, tc.err)
+
+		if rr.Code != tc.want {
+			t.Errorf("%v: ожидался код %d, получен %d", tc.err, tc.want, rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Errorf("неожиданный Content-Type %q", ct)
+		}
+		var p Problem
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatalf("Ошибка декодирования: %v", err)
+		}
+		if p.Status != tc.want || p.Instance != "/order/x" || p.Title == "" {
+			t.Errorf("неожиданное тело ошибки: %+v", p)
+		}
+	}
+}
+
+func TestWriteError_HidesInternalText(t *testing.T) {
+	pgErr := &pgconn.PgError{Code: "23505", Message: `duplicate key value violates unique constraint "orders_pkey"`}
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("%w: пустой order_uid", postgres.ErrInvalid), "некорректные данные: пустой order_uid"},
+		{fmt.Errorf("ошибка добавления заказа: %w", fmt.Errorf("%w: %w", postgres.ErrConflict, pgErr)), postgres.ErrConflict.Error()},
+		{fmt.Errorf("ошибка списка заказов: %w", errors.New("FATAL: password authentication failed")), internalDetail},
+	}
+	for _, tc := range cases {
+		rr := httptest.NewRecorder()
+		writeError(rr, httptest.NewRequest("GET", "/orders", nil), tc.err)
+		var p Problem
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatalf("Ошибка декодирования: %v", err)
+		}
+		if p.Detail != tc.want {
+			t.Errorf("%v: ожидалось %q, получено %q", tc.err, tc.want, p.Detail)
+		}
+	}
+}
+
+func TestNotFoundRoute(t *testing.T) {
+	server := NewServer(nil, nil)
+	req := httptest.NewRequest("GET", "/unknown/path", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("неожиданный Content-Type %q", ct)
+	}
+}
@@ -0,0 +1,31 @@
+package httpserver
+
+import (
+	"net/http"
+	"strconv"
+
+	"demo-service/internal/reconcile"
+)
+
+const defaultReportLimit = 100
+
+// handleReconciliation проверяет суммы всех заказов в бд. ?limit= ограничивает
+// число заказов с расхождениями в ответе, 0 - без ограничения.
+func (s *Server) handleReconciliation(w http.ResponseWriter, r *http.Request) {
+	limit := defaultReportLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeProblem(w, r, http.StatusBadRequest, "limit должен быть неотрицательным целым")
+			return
+		}
+		limit = n
+	}
+
+	report, err := reconcile.Run(r.Context(), s.store, limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, report)
+}
@@ -0,0 +1,29 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReconciliation_BadLimit(t *testing.T) {
+	server := NewServer(nil, nil, WithAdminToken("secret"))
+	for _, q := range []string{"?limit=abc", "?limit=-1"} {
+		req := httptest.NewRequest("GET", "/reports/reconciliation"+q, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался код 400, получен %d", q, rr.Code)
+		}
+	}
+}
+
+func TestReconciliation_RequiresAdmin(t *testing.T) {
+	server := NewServer(nil, nil, WithAdminToken("secret"))
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/reports/reconciliation", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("ожидался код 401, получен %d", rr.Code)
+	}
+}
@@ -0,0 +1,63 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"demo-service/internal/audit"
+	"demo-service/internal/infrastructure/postgres"
+	"demo-service/internal/model"
+
+	"github.com/gorilla/mux"
+)
+
+type statusRequest struct {
+	Status model.Status `json:"status"`
+	Reason string       `json:"reason"`
+}
+
+// handleUpdateStatus меняет статус заказа. Недопустимый переход - 409,
+// неизвестный статус - 422, в ответе заказ после изменения.
+func (s *Server) handleUpdateStatus(w http.ResponseWriter, r *http.Request) {
+	uid := mux.Vars(r)["order_uid"]
+
+	var req statusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "некорректный JSON: "+err.Error())
+		return
+	}
+	if !req.Status.Valid() {
+		writeProblem(w, r, http.StatusUnprocessableEntity, model.ErrUnknownStatus.Error()+": "+string(req.Status))
+		return
+	}
+
+	order, err := s.store.UpdateStatus(r.Context(), uid, req.Status, req.Reason, postgres.SourceAPI)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	s.cache.Replace(order)
+	if s.hub != nil {
+		s.hub.Publish(order)
+	}
+	s.auditor.Record(r.Context(), audit.Event{
+		Actor:      s.caller(r),
+		Action:     audit.ActionStatusChange,
+		OrderUID:   order.OrderUID,
+		CustomerID: order.CustomerID,
+		Details:    fmt.Sprintf("статус %s: %s", req.Status, req.Reason),
+	})
+	if s.writeOrder(w, r, order) {
+		s.auditRead(r, order, orderFields)
+	}
+}
+
+func (s *Server) handleStatusHistory(w http.ResponseWriter, r *http.Request) {
+	history, err := s.store.StatusHistory(r.Context(), mux.Vars(r)["order_uid"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, history)
+}
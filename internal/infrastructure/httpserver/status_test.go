@@ -0,0 +1,39 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUpdateStatus_Validation(t *testing.T) {
+	cases := []struct {
+		name  string
+		token string
+		body  string
+		want  int
+	}{
+		{"без токена", "", `{"status":"paid"}`, http.StatusUnauthorized},
+		{"некорректный JSON", "secret", `{"status":`, http.StatusBadRequest},
+		{"неизвестный статус", "secret", `{"status":"lost"}`, http.StatusUnprocessableEntity},
+		{"без статуса", "secret", `{"reason":"test"}`, http.StatusUnprocessableEntity},
+	}
+
+	// до хранилища запрос не доходит, поэтому оно не нужно
+	server := NewServer(nil, nil, WithAdminToken("secret"))
+	for _, tc := range cases {
+		req := httptest.NewRequest("PATCH", "/order/b563feb7b2b84b6test/status", strings.NewReader(tc.body))
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Errorf("%s: ожидался код %d, получен %d", tc.name, tc.want, rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Errorf("%s: ожидался problem+json, получен %q", tc.name, ct)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"demo-service/internal/audit"
+	"demo-service/internal/hub"
+	"demo-service/internal/model"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultHeartbeat = 15 * time.Second
+	streamWriteWait  = 10 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+// сообщение о заказе в WebSocket-потоке
+type streamMessage struct {
+	ID    uint64       `json:"id"`
+	Type  string       `json:"type"`
+	Order *model.Order `json:"order,omitempty"`
+}
+
+// handleOrderStream - поток новых заказов: WebSocket при запросе апгрейда,
+// иначе Server-Sent Events. Фильтры: customer_id, delivery_service.
+// Возобновление - по заголовку Last-Event-ID или параметру last_event_id.
+func (s *Server) handleOrderStream(w http.ResponseWriter, r *http.Request) {
+	if s.hub == nil {
+		writeProblem(w, r, http.StatusServiceUnavailable, "поток заказов не настроен")
+		return
+	}
+
+	q := r.URL.Query()
+	filter := hub.Filter{
+		CustomerID:      q.Get("customer_id"),
+		DeliveryService: q.Get("delivery_service"),
+	}
+
+	lastID, err := lastEventID(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "некорректный Last-Event-ID")
+		return
+	}
+
+	// в журнал пишется подписка: заказы потока определяются её фильтром,
+	// данные доставки без токена администратора маскируются
+	mask, fields := s.orderMask(r)
+	s.auditor.Record(r.Context(), audit.Event{
+		Actor:      s.caller(r),
+		Action:     audit.ActionOrderStream,
+		CustomerID: filter.CustomerID,
+		Fields:     fields,
+		Details:    fmt.Sprintf("delivery_service=%q, last_event_id=%d", filter.DeliveryService, lastID),
+	})
+
+	if websocket.IsWebSocketUpgrade(r) {
+		s.streamWebSocket(w, r, filter, lastID, mask)
+		return
+	}
+	s.streamSSE(w, r, filter, lastID, mask)
+}
+
+func lastEventID(r *http.Request) (uint64, error) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("last_event_id")
+	}
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(v, 10, 64)
+}
+
+func (s *Server) streamSSE(w http.ResponseWriter, r *http.Request, filter hub.Filter, lastID uint64, mask func(*model.Order) *model.Order) {
+	rc := http.NewResponseController(w)
+
+	sub := s.hub.Subscribe(filter, lastID)
+	defer sub.Close()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// write ограничен по времени, чтобы зависший клиент не держал обработчик
+	write := func(format string, args ...any) bool {
+		_ = rc.SetWriteDeadline(time.Now().Add(streamWriteWait))
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	if !write("retry: 3000\n\n") {
+		return
+	}
+
+	ticker := time.NewTicker(s.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.stopping:
+			return
+		case <-ticker.C:
+			if !write(": ping\n\n") {
+				return
+			}
+		case ev, ok := <-sub.C:
+			if !ok {
+				if sub.Lagged() {
+					// клиент переподключится с Last-Event-ID и дочитает пропущенное
+					write("event: lagged\ndata: {}\n\n")
+				}
+				return
+			}
+			data, err := json.Marshal(mask(ev.Order))
+			if err != nil {
+				log.Println("Ошибка при записи JSON:", err)
+				continue
+			}
+			if !write("id: %d\nevent: order\ndata: %s\n\n", ev.ID, data) {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) streamWebSocket(w http.ResponseWriter, r *http.Request, filter hub.Filter, lastID uint64, mask func(*model.Order) *model.Order) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("Ошибка апгрейда WebSocket:", err)
+		return
+	}
+	defer conn.Close()
+
+	sub := s.hub.Subscribe(filter, lastID)
+	defer sub.Close()
+
+	// чтение нужно для обработки pong и close, данные от клиента не ожидаются
+	done := make(chan struct{})
+	conn.SetReadLimit(512)
+	conn.SetReadDeadline(time.Now().Add(2 * s.heartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * s.heartbeat))
+	})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(s.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-s.stopping:
+			msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "")
+			conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(streamWriteWait))
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteWait)); err != nil {
+				return
+			}
+		case ev, ok := <-sub.C:
+			if !ok {
+				if sub.Lagged() {
+					msg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "lagged")
+					conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(streamWriteWait))
+				}
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if err := conn.WriteJSON(streamMessage{ID: ev.ID, Type: "order", Order: mask(ev.Order)}); err != nil {
+				return
+			}
+		}
+	}
+}
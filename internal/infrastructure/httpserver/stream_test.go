@@ -0,0 +1,91 @@
+package httpserver
+
+import (
+	"bufio"
+	"demo-service/internal/hub"
+	"demo-service/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestOrderStream_SSE(t *testing.T) {
+	h := hub.New()
+	server := NewServer(nil, nil, WithHub(h))
+	server.heartbeat = 20 * time.Millisecond
+	ts := httptest.NewServer(server.router)
+	defer ts.Close()
+
+	h.Publish(&model.Order{OrderUID: "old", CustomerID: "c1"})
+
+	req, _ := http.NewRequest("GET", ts.URL+"/orders/stream?customer_id=c1", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Ошибка запроса: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("неожиданный Content-Type %q", ct)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		h.Publish(&model.Order{OrderUID: "other", CustomerID: "c2"})
+		h.Publish(&model.Order{OrderUID: "new", CustomerID: "c1"})
+	}()
+
+	var sawPing bool
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == ": ping" {
+			sawPing = true
+		}
+		if strings.HasPrefix(line, "data: ") {
+			if strings.Contains(line, `"other"`) {
+				t.Fatal("событие не прошло фильтр customer_id")
+			}
+			if strings.Contains(line, `"new"`) {
+				break
+			}
+		}
+	}
+	if !sawPing {
+		t.Error("ожидался heartbeat до появления заказа")
+	}
+}
+
+func TestOrderStream_WebSocketResume(t *testing.T) {
+	h := hub.New()
+	server := NewServer(nil, nil, WithHub(h))
+	ts := httptest.NewServer(server.router)
+	defer ts.Close()
+
+	h.Publish(&model.Order{OrderUID: "a", DeliveryService: "meest"})
+	h.Publish(&model.Order{OrderUID: "b", DeliveryService: "meest", Delivery: model.Delivery{Name: "Test Testov"}})
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/orders/stream?delivery_service=meest&last_event_id=1"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Ошибка подключения: %v", err)
+	}
+	defer conn.Close()
+
+	var msg streamMessage
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Ошибка чтения: %v", err)
+	}
+	if msg.ID != 2 || msg.Order.OrderUID != "b" {
+		t.Errorf("ожидался заказ b с id 2, получен %+v", msg)
+	}
+	// без токена администратора поток отдаёт маскированные данные доставки
+	if msg.Order != nil && msg.Order.Delivery.Name != "T*** T***" {
+		t.Errorf("данные доставки не замаскированы: %+v", msg.Order.Delivery)
+	}
+}
@@ -0,0 +1,292 @@
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"demo-service/internal/config"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+const dialTimeout = 10 * time.Second
+
+// NewDialer собирает Dialer с TLS и SASL из настроек, он нужен для Reader
+func NewDialer(cfg config.Kafka) (*kafka.Dialer, error) {
+	tlsCfg, mech, err := security(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &kafka.Dialer{
+		ClientID:      cfg.ClientID,
+		Timeout:       dialTimeout,
+		DualStack:     true,
+		TLS:           tlsCfg,
+		SASLMechanism: mech,
+	}, nil
+}
+
+// NewTransport собирает Transport с TLS и SASL из настроек, он нужен для Writer и Client
+func NewTransport(cfg config.Kafka) (*kafka.Transport, error) {
+	tlsCfg, mech, err := security(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &kafka.Transport{
+		ClientID:    cfg.ClientID,
+		DialTimeout: dialTimeout,
+		TLS:         tlsCfg,
+		SASL:        mech,
+	}, nil
+}
+
+// NewClient создаёт клиент для служебных запросов (метаданные, смещения)
+func NewClient(cfg config.Kafka) (*kafka.Client, error) {
+	transport, err := NewTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &kafka.Client{Addr: kafka.TCP(cfg.Brokers...), Timeout: dialTimeout, Transport: transport}, nil
+}
+
+// NewWriter создаёт писателя в топик с общими настройками клиента
+func NewWriter(cfg config.Kafka, topic string) (*kafka.Writer, error) {
+	transport, err := NewTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &kafka.Writer{
+		Addr:      kafka.TCP(cfg.Brokers...),
+		Topic:     topic,
+		Balancer:  &kafka.LeastBytes{},
+		Transport: transport,
+	}, nil
+}
+
+// readerConfig - настройки Reader для группы groupID. Для чтения
+// конкретного раздела без группы groupID пустой, а Partition задаёт вызывающий.
+func readerConfig(cfg config.Kafka, topic, groupID string) (kafka.ReaderConfig, error) {
+	dialer, err := NewDialer(cfg)
+	if err != nil {
+		return kafka.ReaderConfig{}, err
+	}
+	startOffset, err := parseStartOffset(cfg.StartOffset)
+	if err != nil {
+		return kafka.ReaderConfig{}, err
+	}
+	isolation, err := parseIsolationLevel(cfg.IsolationLevel)
+	if err != nil {
+		return kafka.ReaderConfig{}, err
+	}
+	return kafka.ReaderConfig{
+		Brokers:           cfg.Brokers,
+		Topic:             topic,
+		GroupID:           groupID,
+		Dialer:            dialer,
+		MinBytes:          cfg.MinBytes,
+		MaxBytes:          cfg.MaxBytes,
+		MaxWait:           cfg.MaxWait,
+		SessionTimeout:    cfg.SessionTimeout,
+		HeartbeatInterval: cfg.HeartbeatInterval,
+		StartOffset:       startOffset,
+		IsolationLevel:    isolation,
+	}, nil
+}
+
+func parseStartOffset(s string) (int64, error) {
+	switch strings.ToLower(s) {
+	case "", "earliest", "first":
+		return kafka.FirstOffset, nil
+	case "latest", "last":
+		return kafka.LastOffset, nil
+	}
+	return 0, fmt.Errorf("неизвестное начальное смещение %q, ожидалось earliest или latest", s)
+}
+
+func parseIsolationLevel(s string) (kafka.IsolationLevel, error) {
+	switch strings.ToLower(s) {
+	case "", "read_uncommitted":
+		return kafka.ReadUncommitted, nil
+	case "read_committed":
+		return kafka.ReadCommitted, nil
+	}
+	return 0, fmt.Errorf("неизвестный уровень изоляции %q, ожидалось read_uncommitted или read_committed", s)
+}
+
+func security(cfg config.Kafka) (*tls.Config, sasl.Mechanism, error) {
+	tlsCfg, err := tlsConfig(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	mech, err := saslMechanism(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tlsCfg, mech, nil
+}
+
+func tlsConfig(cfg config.Kafka) (*tls.Config, error) {
+	if !cfg.TLSEnabled {
+		return nil, nil
+	}
+	tc := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
+	}
+
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать CA Kafka: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("в %s нет сертификатов в формате PEM", cfg.TLSCAFile)
+		}
+		tc.RootCAs = pool
+	}
+
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, errors.New("для клиентского сертификата Kafka нужны и сертификат, и ключ")
+	}
+	if cfg.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось загрузить клиентский сертификат Kafka: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
+
+func saslMechanism(cfg config.Kafka) (sasl.Mechanism, error) {
+	switch strings.ToUpper(cfg.SASLMechanism) {
+	case "":
+		return nil, nil
+	case "PLAIN":
+		return plain.Mechanism{Username: cfg.SASLUsername, Password: cfg.SASLPassword}, nil
+	case "SCRAM-SHA-256":
+		return scram.Mechanism(scram.SHA256, cfg.SASLUsername, cfg.SASLPassword)
+	case "SCRAM-SHA-512":
+		return scram.Mechanism(scram.SHA512, cfg.SASLUsername, cfg.SASLPassword)
+	}
+	return nil, fmt.Errorf("неподдерживаемый механизм SASL %q", cfg.SASLMechanism)
+}
+
+// topicPartitions возвращает номера разделов топика
+func topicPartitions(ctx context.Context, client *kafka.Client, topic string) ([]int, error) {
+	meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить разделы топика %s: %w", topic, err)
+	}
+	var out []int
+	for _, t := range meta.Topics {
+		if t.Error != nil {
+			return nil, fmt.Errorf("топик %s: %w", topic, t.Error)
+		}
+		for _, p := range t.Partitions {
+			out = append(out, p.ID)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("у топика %s нет разделов", topic)
+	}
+	return out, nil
+}
+
+func timeOffsetOf(at time.Time) func(int) kafka.OffsetRequest {
+	return func(p int) kafka.OffsetRequest { return kafka.TimeOffsetOf(p, at) }
+}
+
+// listOffsets запрашивает у брокера смещения разделов: первое, последнее или по времени
+func listOffsets(ctx context.Context, client *kafka.Client, topic string, partitions []int, req func(int) kafka.OffsetRequest) (map[int]int64, error) {
+	reqs := make([]kafka.OffsetRequest, 0, len(partitions))
+	for _, p := range partitions {
+		reqs = append(reqs, req(p))
+	}
+	resp, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{topic: reqs},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить смещения топика %s: %w", topic, err)
+	}
+
+	out := make(map[int]int64, len(partitions))
+	for _, po := range resp.Topics[topic] {
+		if po.Error != nil {
+			return nil, fmt.Errorf("раздел %d: %w", po.Partition, po.Error)
+		}
+		switch {
+		case po.FirstOffset >= 0:
+			out[po.Partition] = po.FirstOffset
+		case po.LastOffset >= 0:
+			out[po.Partition] = po.LastOffset
+		default:
+			out[po.Partition] = -1
+			for off := range po.Offsets {
+				out[po.Partition] = off
+			}
+		}
+	}
+	return out, nil
+}
+
+// groupOffsets возвращает закоммиченные группой смещения разделов,
+// разделы без коммита пропускаются
+func groupOffsets(ctx context.Context, client *kafka.Client, group, topic string, partitions []int) (map[int]int64, error) {
+	resp, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: group,
+		Topics:  map[string][]int{topic: partitions},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить смещения группы %s: %w", group, err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("смещения группы %s: %w", group, resp.Error)
+	}
+	out := make(map[int]int64)
+	for _, p := range resp.Topics[topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("топик %s, раздел %d: %w", topic, p.Partition, p.Error)
+		}
+		if p.CommittedOffset >= 0 {
+			out[p.Partition] = p.CommittedOffset
+		}
+	}
+	return out, nil
+}
+
+// commitOffsets коммитит группе следующие смещения разделов. Группа без
+// участников, коммит идёт вне поколения.
+func commitOffsets(ctx context.Context, client *kafka.Client, group, topic string, offsets map[int]int64) error {
+	if len(offsets) == 0 {
+		return nil
+	}
+	commits := make([]kafka.OffsetCommit, 0, len(offsets))
+	for p, off := range offsets {
+		commits = append(commits, kafka.OffsetCommit{Partition: p, Offset: off})
+	}
+	resp, err := client.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      group,
+		GenerationID: -1,
+		Topics:       map[string][]kafka.OffsetCommit{topic: commits},
+	})
+	if err != nil {
+		return fmt.Errorf("не удалось закоммитить смещения группы %s: %w", group, err)
+	}
+	for _, p := range resp.Topics[topic] {
+		if p.Error != nil {
+			return fmt.Errorf("топик %s, раздел %d: %w", topic, p.Partition, p.Error)
+		}
+	}
+	return nil
+}
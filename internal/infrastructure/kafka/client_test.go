@@ -0,0 +1,75 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"demo-service/internal/config"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/plain"
+)
+
+func TestReaderConfig(t *testing.T) {
+	cfg := config.Kafka{
+		Brokers:           []string{"a:9092", "b:9092"},
+		ClientID:          "test",
+		MinBytes:          1,
+		MaxBytes:          1024,
+		MaxWait:           time.Second,
+		SessionTimeout:    20 * time.Second,
+		HeartbeatInterval: 2 * time.Second,
+		StartOffset:       "latest",
+		IsolationLevel:    "read_committed",
+		SASLMechanism:     "plain",
+		SASLUsername:      "user",
+		SASLPassword:      "secret",
+	}
+	rc, err := readerConfig(cfg, "orders", "group")
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if rc.Topic != "orders" || rc.GroupID != "group" || len(rc.Brokers) != 2 {
+		t.Errorf("неверные топик, группа или брокеры: %+v", rc)
+	}
+	if rc.MinBytes != 1 || rc.MaxBytes != 1024 || rc.MaxWait != time.Second ||
+		rc.SessionTimeout != 20*time.Second || rc.HeartbeatInterval != 2*time.Second {
+		t.Errorf("настройки чтения не перенесены: %+v", rc)
+	}
+	if rc.StartOffset != kafka.LastOffset || rc.IsolationLevel != kafka.ReadCommitted {
+		t.Errorf("ожидались latest и read_committed, получено %d и %d", rc.StartOffset, rc.IsolationLevel)
+	}
+	if rc.Dialer.ClientID != "test" || rc.Dialer.TLS != nil {
+		t.Errorf("неверный Dialer: %+v", rc.Dialer)
+	}
+	if m, ok := rc.Dialer.SASLMechanism.(plain.Mechanism); !ok || m.Username != "user" {
+		t.Errorf("ожидался PLAIN для user, получено %#v", rc.Dialer.SASLMechanism)
+	}
+}
+
+func TestClientConfigErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  config.Kafka
+	}{
+		{"начальное смещение", config.Kafka{StartOffset: "middle"}},
+		{"уровень изоляции", config.Kafka{IsolationLevel: "serializable"}},
+		{"механизм SASL", config.Kafka{SASLMechanism: "GSSAPI"}},
+		{"сертификат без ключа", config.Kafka{TLSEnabled: true, TLSCertFile: "client.pem"}},
+		{"нет файла CA", config.Kafka{TLSEnabled: true, TLSCAFile: "/nonexistent/ca.pem"}},
+	}
+	for _, tc := range cases {
+		if _, err := readerConfig(tc.cfg, "orders", ""); err == nil {
+			t.Errorf("%s: ожидалась ошибка", tc.name)
+		}
+	}
+}
+
+func TestSASLMechanism_SCRAM(t *testing.T) {
+	for _, name := range []string{"SCRAM-SHA-256", "scram-sha-512"} {
+		mech, err := saslMechanism(config.Kafka{SASLMechanism: name, SASLUsername: "u", SASLPassword: "p"})
+		if err != nil || mech == nil {
+			t.Fatalf("%s: механизм не создан: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+var (
+	// ErrUnknownTopic - топик не зарегистрирован в consumer
+	ErrUnknownTopic = errors.New("топик не читается consumer")
+	// ErrInvalidSeek - раздела нет в топике или смещение вне раздела
+	ErrInvalidSeek = errors.New("некорректное смещение")
+)
+
+// сколько ждать коммита нового смещения группой
+const seekTimeout = 30 * time.Second
+
+type seekRequest struct {
+	partition int
+	offset    int64
+	done      chan error
+}
+
+// ConsumerStatus - состояние consumer и его группы для администратора
+type ConsumerStatus struct {
+	GroupID    string        `json:"group_id"`
+	GroupState string        `json:"group_state,omitempty"`
+	Topics     []TopicStatus `json:"topics"`
+	// участники группы и назначенные им разделы, включая другие экземпляры сервиса
+	Members []GroupMember `json:"members"`
+	// смещения группы и отставание по разделам
+	Partitions []PartitionLag `json:"partitions"`
+}
+
+// TopicStatus - состояние чтения одного топика этим процессом
+type TopicStatus struct {
+	Topic  string `json:"topic"`
+	Paused bool   `json:"paused"`
+	// следующие смещения по разделам после последнего коммита этого процесса
+	Committed map[int]int64 `json:"committed"`
+}
+
+// GroupMember - участник группы и его разделы по топикам
+type GroupMember struct {
+	MemberID   string           `json:"member_id"`
+	ClientID   string           `json:"client_id"`
+	ClientHost string           `json:"client_host"`
+	Assignment map[string][]int `json:"assignment"`
+}
+
+// routesOf возвращает маршрут топика, а для пустого topic - все маршруты
+func (c *KafkaConsumer) routesOf(topic string) ([]*route, error) {
+	if topic == "" {
+		return c.routes, nil
+	}
+	for _, r := range c.routes {
+		if r.topic == topic {
+			return []*route{r}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownTopic, topic)
+}
+
+// Pause приостанавливает чтение топика, пустой topic - всех топиков.
+// Сообщение, которое уже обрабатывается, доводится до коммита.
+func (c *KafkaConsumer) Pause(topic string) error {
+	return c.setPaused(topic, true)
+}
+
+// Resume возобновляет чтение топика, пустой topic - всех топиков
+func (c *KafkaConsumer) Resume(topic string) error {
+	return c.setPaused(topic, false)
+}
+
+func (c *KafkaConsumer) setPaused(topic string, paused bool) error {
+	routes, err := c.routesOf(topic)
+	if err != nil {
+		return err
+	}
+	for _, r := range routes {
+		r.mu.Lock()
+		if r.paused != paused {
+			r.paused = paused
+			r.notify()
+			if paused {
+				log.Printf("Чтение топика %s приостановлено", r.topic)
+			} else {
+				log.Printf("Чтение топика %s возобновлено", r.topic)
+			}
+		}
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+// Seek переводит группу на смещение offset в разделе partition. Цикл чтения
+// топика коммитит смещение и перезапускает reader, чтобы получить разделы
+// заново уже с закоммиченных смещений. Если раздел сейчас читает другой
+// экземпляр сервиса, он перезапишет смещение своим коммитом, поэтому при
+// нескольких экземплярах их нужно сначала остановить.
+func (c *KafkaConsumer) Seek(ctx context.Context, topic string, partition int, offset int64) error {
+	if topic == "" {
+		return fmt.Errorf("%w: не указан топик", ErrInvalidSeek)
+	}
+	routes, err := c.routesOf(topic)
+	if err != nil {
+		return err
+	}
+	r := routes[0]
+	if r.newReader == nil {
+		return errors.New("смена смещения не поддерживается")
+	}
+	if offset < 0 {
+		return fmt.Errorf("%w: отрицательное смещение %d", ErrInvalidSeek, offset)
+	}
+	if c.client != nil {
+		if err := c.checkOffset(ctx, topic, partition, offset); err != nil {
+			return err
+		}
+	}
+
+	req := seekRequest{partition: partition, offset: offset, done: make(chan error, 1)}
+	r.mu.Lock()
+	r.seeks = append(r.seeks, req)
+	r.notify()
+	r.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-req.done:
+		return err
+	}
+}
+
+// checkOffset проверяет, что раздел есть и смещение не выходит за его границы
+func (c *KafkaConsumer) checkOffset(ctx context.Context, topic string, partition int, offset int64) error {
+	partitions, err := topicPartitions(ctx, c.client, topic)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(partitions, partition) {
+		return fmt.Errorf("%w: в топике %s нет раздела %d", ErrInvalidSeek, topic, partition)
+	}
+	first, err := listOffsets(ctx, c.client, topic, []int{partition}, kafka.FirstOffsetOf)
+	if err != nil {
+		return err
+	}
+	end, err := listOffsets(ctx, c.client, topic, []int{partition}, kafka.LastOffsetOf)
+	if err != nil {
+		return err
+	}
+	if offset < first[partition] || offset > end[partition] {
+		return fmt.Errorf("%w: %d вне раздела %s/%d [%d, %d]",
+			ErrInvalidSeek, offset, topic, partition, first[partition], end[partition])
+	}
+	return nil
+}
+
+// Status возвращает паузы и смещения этого процесса, а также участников
+// группы, назначенные им разделы и отставание группы
+func (c *KafkaConsumer) Status(ctx context.Context) (ConsumerStatus, error) {
+	st := ConsumerStatus{GroupID: c.cfg.GroupID}
+	topics := make([]string, 0, len(c.routes))
+	for _, r := range c.routes {
+		r.mu.Lock()
+		ts := TopicStatus{Topic: r.topic, Paused: r.paused, Committed: make(map[int]int64, len(r.committed))}
+		for p, off := range r.committed {
+			ts.Committed[p] = off
+		}
+		r.mu.Unlock()
+		st.Topics = append(st.Topics, ts)
+		topics = append(topics, r.topic)
+	}
+	if c.client == nil {
+		return st, nil
+	}
+
+	resp, err := c.client.DescribeGroups(ctx, &kafka.DescribeGroupsRequest{GroupIDs: []string{c.cfg.GroupID}})
+	if err != nil {
+		return st, fmt.Errorf("не удалось получить состав группы %s: %w", c.cfg.GroupID, err)
+	}
+	for _, g := range resp.Groups {
+		if g.Error != nil {
+			return st, fmt.Errorf("состав группы %s: %w", c.cfg.GroupID, g.Error)
+		}
+		st.GroupState = g.GroupState
+		for _, m := range g.Members {
+			member := GroupMember{
+				MemberID:   m.MemberID,
+				ClientID:   m.ClientID,
+				ClientHost: m.ClientHost,
+				Assignment: make(map[string][]int),
+			}
+			for _, t := range m.MemberAssignments.Topics {
+				member.Assignment[t.Topic] = append(member.Assignment[t.Topic], t.Partitions...)
+				sort.Ints(member.Assignment[t.Topic])
+			}
+			st.Members = append(st.Members, member)
+		}
+	}
+	sort.Slice(st.Members, func(a, b int) bool { return st.Members[a].MemberID < st.Members[b].MemberID })
+
+	if st.Partitions, err = groupLag(ctx, c.client, c.cfg.GroupID, topics...); err != nil {
+		return st, err
+	}
+	return st, nil
+}
+
+// notify будит цикл чтения: прерывает ожидание сообщения и паузу. Вызывается под r.mu.
+func (r *route) notify() {
+	if r.cancelFetch != nil {
+		r.cancelFetch()
+	}
+	if r.changed != nil {
+		close(r.changed)
+		r.changed = nil
+	}
+}
+
+// wait ждёт, пока топик на паузе и нет запросов смены смещения
+func (r *route) wait(ctx context.Context) error {
+	for {
+		r.mu.Lock()
+		if !r.paused || len(r.seeks) > 0 {
+			r.mu.Unlock()
+			return nil
+		}
+		if r.changed == nil {
+			r.changed = make(chan struct{})
+		}
+		changed := r.changed
+		r.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// fetchContext - контекст одного FetchMessage, его отменяют пауза и смена смещения
+func (r *route) fetchContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	fetchCtx, cancel := context.WithCancel(ctx)
+	r.mu.Lock()
+	if r.paused || len(r.seeks) > 0 {
+		// пауза или смена смещения пришли между wait и FetchMessage
+		cancel()
+	} else {
+		r.cancelFetch = cancel
+	}
+	r.mu.Unlock()
+	return fetchCtx, cancel
+}
+
+func (r *route) takeSeeks() []seekRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seeks := r.seeks
+	r.seeks = nil
+	return seeks
+}
+
+// applySeeks коммитит новые смещения и перезапускает reader топика
+func (c *KafkaConsumer) applySeeks(ctx context.Context, r *route, seeks []seekRequest) {
+	// по одному разделу действует последний запрос
+	offsets := make(map[int]int64, len(seeks))
+	for _, s := range seeks {
+		offsets[s.partition] = s.offset
+	}
+	msgs := make([]kafka.Message, 0, len(offsets))
+	for p, off := range offsets {
+		// reader коммитит смещение сообщения плюс один
+		msgs = append(msgs, kafka.Message{Topic: r.topic, Partition: p, Offset: off - 1})
+	}
+
+	commitCtx, cancel := context.WithTimeout(ctx, seekTimeout)
+	err := r.reader.CommitMessages(commitCtx, msgs...)
+	cancel()
+	if err != nil {
+		err = fmt.Errorf("не удалось закоммитить смещения топика %s: %w", r.topic, err)
+	} else {
+		// после перезапуска группа назначит разделы заново и reader начнёт с закоммиченных смещений
+		if cerr := r.reader.Close(); cerr != nil {
+			log.Printf("Ошибка закрытия Kafka reader топика %s: %v", r.topic, cerr)
+		}
+		r.mu.Lock()
+		r.reader = r.newReader()
+		for p, off := range offsets {
+			r.committed[p] = off
+		}
+		r.mu.Unlock()
+		log.Printf("Топик %s переведён на смещения %v", r.topic, offsets)
+	}
+	for _, s := range seeks {
+		s.done <- err
+	}
+}
@@ -0,0 +1,272 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"demo-service/internal/config"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// сколько ждать сообщения при чтении DLQ, прежде чем считать раздел дочитанным
+const inspectIdleTimeout = 5 * time.Second
+
+// PartitionLag - отставание группы в одном разделе
+type PartitionLag struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	// следующее смещение для чтения группой, -1 - группа ещё не коммитила
+	Committed int64 `json:"committed"`
+	End       int64 `json:"end"`
+	Lag       int64 `json:"lag"`
+}
+
+// DeadLetter - сообщение из DLQ вместе с причиной и исходным положением
+type DeadLetter struct {
+	Partition int       `json:"partition"`
+	Offset    int64     `json:"offset"`
+	Time      time.Time `json:"time"`
+	Key       string    `json:"key"`
+	Value     []byte    `json:"value"`
+	Error     string    `json:"error"`
+	// топик, раздел и смещение, где сообщение было прочитано consumer
+	SourceTopic     string `json:"source_topic"`
+	SourcePartition int    `json:"source_partition"`
+	SourceOffset    int64  `json:"source_offset"`
+	// заголовки исходного сообщения, без служебных x-dlq-*
+	Headers []kafka.Header `json:"headers,omitempty"`
+}
+
+// Inspector выполняет служебные операции для администратора: отставание
+// группы, чтение DLQ и возврат сообщений из DLQ в исходный топик
+type Inspector struct {
+	cfg    config.Kafka
+	client *kafka.Client
+}
+
+func NewInspector(cfg config.Kafka) (*Inspector, error) {
+	client, err := NewClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("настройки Kafka: %w", err)
+	}
+	return &Inspector{cfg: cfg, client: client}, nil
+}
+
+// GroupLag возвращает отставание группы по всем разделам топиков
+func (i *Inspector) GroupLag(ctx context.Context, group string, topics ...string) ([]PartitionLag, error) {
+	return groupLag(ctx, i.client, group, topics...)
+}
+
+func groupLag(ctx context.Context, client *kafka.Client, group string, topics ...string) ([]PartitionLag, error) {
+	var out []PartitionLag
+	for _, topic := range topics {
+		partitions, err := topicPartitions(ctx, client, topic)
+		if err != nil {
+			return nil, err
+		}
+		first, err := listOffsets(ctx, client, topic, partitions, kafka.FirstOffsetOf)
+		if err != nil {
+			return nil, err
+		}
+		end, err := listOffsets(ctx, client, topic, partitions, kafka.LastOffsetOf)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+			GroupID: group,
+			Topics:  map[string][]int{topic: partitions},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("не удалось получить смещения группы %s: %w", group, err)
+		}
+		if resp.Error != nil {
+			return nil, fmt.Errorf("смещения группы %s: %w", group, resp.Error)
+		}
+		for _, p := range resp.Topics[topic] {
+			if p.Error != nil {
+				return nil, fmt.Errorf("топик %s, раздел %d: %w", topic, p.Partition, p.Error)
+			}
+			pl := PartitionLag{Topic: topic, Partition: p.Partition, Committed: p.CommittedOffset, End: end[p.Partition]}
+			// без коммита группа начнёт с начала раздела (KAFKA_START_OFFSET=earliest)
+			from := p.CommittedOffset
+			if from < 0 {
+				from = first[p.Partition]
+			}
+			pl.Lag = max(pl.End-from, 0)
+			out = append(out, pl)
+		}
+	}
+	sort.Slice(out, func(a, b int) bool {
+		if out[a].Topic != out[b].Topic {
+			return out[a].Topic < out[b].Topic
+		}
+		return out[a].Partition < out[b].Partition
+	})
+	return out, nil
+}
+
+// ReadDLQ читает до limit сообщений топика DLQ, начиная со смещений from
+// (по умолчанию - с начала раздела). limit <= 0 - до конца разделов.
+func (i *Inspector) ReadDLQ(ctx context.Context, topic string, from map[int]int64, limit int) ([]DeadLetter, error) {
+	partitions, err := topicPartitions(ctx, i.client, topic)
+	if err != nil {
+		return nil, err
+	}
+	sort.Ints(partitions)
+	first, err := listOffsets(ctx, i.client, topic, partitions, kafka.FirstOffsetOf)
+	if err != nil {
+		return nil, err
+	}
+	end, err := listOffsets(ctx, i.client, topic, partitions, kafka.LastOffsetOf)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []DeadLetter
+	for _, p := range partitions {
+		start := first[p]
+		if off, ok := from[p]; ok {
+			start = max(off, start)
+		}
+		n := end[p] - start
+		if limit > 0 {
+			n = min(n, int64(limit-len(out)))
+		}
+		if n <= 0 {
+			continue
+		}
+		msgs, err := i.readPartition(ctx, topic, p, start, start+n)
+		if err != nil {
+			return out, err
+		}
+		for _, m := range msgs {
+			out = append(out, deadLetterOf(m))
+		}
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+// readPartition читает сообщения раздела из диапазона [from, to)
+func (i *Inspector) readPartition(ctx context.Context, topic string, partition int, from, to int64) ([]kafka.Message, error) {
+	rc, err := readerConfig(i.cfg, topic, "")
+	if err != nil {
+		return nil, err
+	}
+	rc.Partition = partition
+	rc.MinBytes = 1
+	reader := kafka.NewReader(rc)
+	defer reader.Close()
+	if err := reader.SetOffset(from); err != nil {
+		return nil, fmt.Errorf("раздел %d: %w", partition, err)
+	}
+
+	var out []kafka.Message
+	for {
+		readCtx, cancel := context.WithTimeout(ctx, inspectIdleTimeout)
+		msg, err := reader.ReadMessage(readCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				// в конце диапазона бывают служебные записи транзакций
+				return out, nil
+			}
+			return out, fmt.Errorf("раздел %d: %w", partition, err)
+		}
+		if msg.Offset >= to {
+			return out, nil
+		}
+		out = append(out, msg)
+		if msg.Offset+1 >= to {
+			return out, nil
+		}
+	}
+}
+
+func deadLetterOf(m kafka.Message) DeadLetter {
+	d := DeadLetter{
+		Partition:       m.Partition,
+		Offset:          m.Offset,
+		Time:            m.Time,
+		Key:             string(m.Key),
+		Value:           m.Value,
+		Error:           headerValue(m.Headers, HeaderDLQError),
+		SourceTopic:     headerValue(m.Headers, HeaderDLQTopic),
+		SourcePartition: -1,
+		SourceOffset:    -1,
+	}
+	if p, err := strconv.Atoi(headerValue(m.Headers, HeaderDLQPartition)); err == nil {
+		d.SourcePartition = p
+	}
+	if off, err := strconv.ParseInt(headerValue(m.Headers, HeaderDLQOffset), 10, 64); err == nil {
+		d.SourceOffset = off
+	}
+	for _, h := range m.Headers {
+		if !strings.HasPrefix(strings.ToLower(h.Key), "x-dlq-") {
+			d.Headers = append(d.Headers, h)
+		}
+	}
+	return d
+}
+
+// Requeue отправляет сообщения из DLQ обратно в исходные топики с исходными
+// ключом и заголовками. Из DLQ сообщения не удаляются. Возвращает число отправленных.
+func (i *Inspector) Requeue(ctx context.Context, letters []DeadLetter) (int, error) {
+	msgs := make([]kafka.Message, 0, len(letters))
+	for _, d := range letters {
+		if d.SourceTopic == "" {
+			return 0, fmt.Errorf("сообщение %d/%d: нет заголовка %s", d.Partition, d.Offset, HeaderDLQTopic)
+		}
+		var key []byte
+		if d.Key != "" {
+			key = []byte(d.Key)
+		}
+		msgs = append(msgs, kafka.Message{Topic: d.SourceTopic, Key: key, Value: d.Value, Headers: d.Headers})
+	}
+	if len(msgs) == 0 {
+		return 0, nil
+	}
+
+	w, err := NewWriter(i.cfg, "")
+	if err != nil {
+		return 0, fmt.Errorf("настройки Kafka: %w", err)
+	}
+	defer w.Close()
+	if err := w.WriteMessages(ctx, msgs...); err != nil {
+		return 0, fmt.Errorf("не удалось вернуть сообщения в топик: %w", err)
+	}
+	return len(msgs), nil
+}
+
+// GroupOffsets возвращает закоммиченные группой смещения разделов топика,
+// разделы без коммита пропускаются
+func (i *Inspector) GroupOffsets(ctx context.Context, group, topic string) (map[int]int64, error) {
+	partitions, err := topicPartitions(ctx, i.client, topic)
+	if err != nil {
+		return nil, err
+	}
+	return groupOffsets(ctx, i.client, group, topic, partitions)
+}
+
+// CommitDLQ коммитит группе смещения после прочитанных сообщений DLQ, чтобы
+// следующий ReadDLQ с GroupOffsets начал с непрочитанных
+func (i *Inspector) CommitDLQ(ctx context.Context, group, topic string, letters []DeadLetter) error {
+	return commitOffsets(ctx, i.client, group, topic, nextOffsets(letters))
+}
+
+// nextOffsets возвращает по разделам смещение, следующее за последним сообщением
+func nextOffsets(letters []DeadLetter) map[int]int64 {
+	next := make(map[int]int64)
+	for _, d := range letters {
+		next[d.Partition] = max(next[d.Partition], d.Offset+1)
+	}
+	return next
+}
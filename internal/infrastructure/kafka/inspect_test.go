@@ -0,0 +1,46 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestDeadLetterOf(t *testing.T) {
+	msg := kafka.Message{
+		Partition: 2,
+		Offset:    41,
+		Key:       []byte("uid"),
+		Value:     []byte(`{"order_uid":`),
+		Headers: []kafka.Header{
+			{Key: "content-type", Value: []byte("application/json")},
+			{Key: HeaderDLQError, Value: []byte("ошибка разбора")},
+			{Key: HeaderDLQTopic, Value: []byte("orders")},
+			{Key: HeaderDLQPartition, Value: []byte("0")},
+			{Key: HeaderDLQOffset, Value: []byte("17")},
+		},
+	}
+	d := deadLetterOf(msg)
+	if d.SourceTopic != "orders" || d.SourcePartition != 0 || d.SourceOffset != 17 || d.Error != "ошибка разбора" {
+		t.Errorf("исходное положение или причина разобраны неверно: %+v", d)
+	}
+	// при возврате в топик служебные заголовки не нужны
+	if len(d.Headers) != 1 || d.Headers[0].Key != "content-type" {
+		t.Errorf("ожидался только заголовок content-type, получено %v", d.Headers)
+	}
+	if d.Partition != 2 || d.Offset != 41 || d.Key != "uid" {
+		t.Errorf("положение в DLQ разобрано неверно: %+v", d)
+	}
+}
+
+func TestNextOffsets(t *testing.T) {
+	letters := []DeadLetter{
+		{Partition: 0, Offset: 4},
+		{Partition: 1, Offset: 10},
+		{Partition: 0, Offset: 7},
+	}
+	next := nextOffsets(letters)
+	if len(next) != 2 || next[0] != 8 || next[1] != 11 {
+		t.Errorf("ожидалось {0:8 1:11}, получено %v", next)
+	}
+}
@@ -2,72 +2,364 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-	"demo-service/internal/infrastructure/cache"
-	"demo-service/internal/infrastructure/postgres"
-	"demo-service/internal/model"
+	"demo-service/internal/config"
 
 	"github.com/segmentio/kafka-go"
+	"golang.org/x/sync/errgroup"
 )
 
+// Заголовки, которые добавляются к сообщению при отправке в DLQ
+const (
+	HeaderDLQError     = "x-dlq-error"
+	HeaderDLQTopic     = "x-dlq-topic"
+	HeaderDLQPartition = "x-dlq-partition"
+	HeaderDLQOffset    = "x-dlq-offset"
+)
+
+// наибольшая пауза между попытками отправки в DLQ
+const maxDLQBackoff = time.Minute
+
+// Handler обрабатывает сообщения одного топика: разбирает, проверяет и
+// применяет их. Ошибку, которую бессмысленно повторять, нужно обернуть в Permanent.
+type Handler interface {
+	Handle(ctx context.Context, msg kafka.Message) error
+}
+
+// HandlerFunc позволяет использовать функцию как Handler
+type HandlerFunc func(ctx context.Context, msg kafka.Message) error
+
+func (f HandlerFunc) Handle(ctx context.Context, msg kafka.Message) error {
+	return f(ctx, msg)
+}
+
+// Policy - что делать с сообщением, которое не удалось обработать
+type Policy struct {
+	// сколько раз повторить обработку при временной ошибке
+	Retries int
+	// пауза перед первым повтором, дальше удваивается
+	Backoff time.Duration
+	// топик для необработанных сообщений, пусто - сообщение пропускается
+	DLQTopic string
+}
+
+// DefaultPolicy собирает политику из общих настроек для топика topic
+func DefaultPolicy(cfg config.Kafka, topic string) Policy {
+	p := Policy{Retries: cfg.Retries, Backoff: cfg.RetryBackoff}
+	if cfg.DLQSuffix != "" {
+		p.DLQTopic = topic + cfg.DLQSuffix
+	}
+	return p
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent помечает ошибку как постоянную: сообщение не повторяется и сразу уходит в DLQ
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent сообщает, помечена ли ошибка как постоянная
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// reader и writer - то, что consumer использует от kafka.Reader и kafka.Writer
+type reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Stats() kafka.ReaderStats
+	Close() error
+}
+
+type writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+type route struct {
+	topic   string
+	handler Handler
+	policy  Policy
+	reader  reader
+	// создаёт reader заново после смены смещения, nil - смена не поддерживается
+	newReader func() reader
+	metrics   *topicMetrics
+
+	mu sync.Mutex
+	// следующее смещение по разделам после последнего коммита
+	committed map[int]int64
+	// пауза и смены смещения ждут, пока их применит цикл чтения (control.go)
+	paused  bool
+	seeks   []seekRequest
+	changed chan struct{}
+	// прерывает ожидание сообщения в FetchMessage
+	cancelFetch context.CancelFunc
+}
+
+// KafkaConsumer читает несколько топиков одной группой: у каждого топика свой
+// обработчик и своя политика ошибок, а запуск, остановка и метрики общие.
 type KafkaConsumer struct {
-	reader  *kafka.Reader
-	storage *postgres.Postgres
+	cfg     config.Kafka
+	dlq     writer
+	routes  []*route
+	metrics *Metrics
+	// служебные запросы: назначение разделов и смещения группы
+	client *kafka.Client
 }
 
-func NewKafkaConsumer(brokers []string, topic, groupID string, storage *postgres.Postgres) *KafkaConsumer {
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  brokers,
-		Topic:    topic,
-		GroupID:  groupID,
-		MinBytes: 1e4,
-		MaxBytes: 1e7,
-	})
-	return &KafkaConsumer{reader: reader, storage: storage}
+func NewKafkaConsumer(cfg config.Kafka) (*KafkaConsumer, error) {
+	w, err := NewWriter(cfg, "")
+	if err != nil {
+		return nil, fmt.Errorf("настройки Kafka: %w", err)
+	}
+	w.AllowAutoTopicCreation = true
+	client, err := NewClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("настройки Kafka: %w", err)
+	}
+	return &KafkaConsumer{cfg: cfg, dlq: w, metrics: NewMetrics(), client: client}, nil
+}
+
+// Register подписывает обработчик на топик. Вызывать до Consume.
+func (c *KafkaConsumer) Register(topic string, h Handler, policy Policy) error {
+	for _, r := range c.routes {
+		if r.topic == topic {
+			return fmt.Errorf("обработчик топика %s уже зарегистрирован", topic)
+		}
+	}
+	rc, err := readerConfig(c.cfg, topic, c.cfg.GroupID)
+	if err != nil {
+		return fmt.Errorf("настройки Kafka: %w", err)
+	}
+	r := c.register(topic, h, policy, kafka.NewReader(rc))
+	r.newReader = func() reader { return kafka.NewReader(rc) }
+	return nil
+}
+
+func (c *KafkaConsumer) register(topic string, h Handler, policy Policy, rd reader) *route {
+	r := &route{
+		topic:     topic,
+		handler:   h,
+		policy:    policy,
+		reader:    rd,
+		metrics:   c.metrics.topic(topic),
+		committed: make(map[int]int64),
+	}
+	c.routes = append(c.routes, r)
+	return r
+}
+
+// Metrics возвращает общие метрики всех топиков
+func (c *KafkaConsumer) Metrics() *Metrics {
+	return c.metrics
 }
 
-func (c *KafkaConsumer) Consume(ctx context.Context, cacheStore *cache.Cache) error {
+// Offsets возвращает закоммиченные этим процессом смещения: топик, раздел,
+// следующее смещение для чтения
+func (c *KafkaConsumer) Offsets() map[string]map[int]int64 {
+	out := make(map[string]map[int]int64, len(c.routes))
+	for _, r := range c.routes {
+		r.mu.Lock()
+		parts := make(map[int]int64, len(r.committed))
+		for p, off := range r.committed {
+			parts[p] = off
+		}
+		r.mu.Unlock()
+		out[r.topic] = parts
+	}
+	return out
+}
+
+// Consume читает все зарегистрированные топики до отмены ctx. Ошибка чтения
+// любого топика останавливает остальные и возвращается.
+func (c *KafkaConsumer) Consume(ctx context.Context) error {
+	if len(c.routes) == 0 {
+		return errors.New("не зарегистрировано ни одного топика")
+	}
+	g, gctx := errgroup.WithContext(ctx)
+	for _, r := range c.routes {
+		g.Go(func() error { return c.consumeTopic(gctx, r) })
+	}
+	err := g.Wait()
+	if ctx.Err() != nil {
+		log.Println("Контекст завершён, останавливаем consumer")
+		return nil
+	}
+	return err
+}
+
+func (c *KafkaConsumer) consumeTopic(ctx context.Context, r *route) error {
+	log.Printf("Чтение топика %s, группа %s", r.topic, c.cfg.GroupID)
 	for {
-		select {
-		case <-ctx.Done():
-			log.Println("Контекст завершён, останавливаем consumer")
-			return ctx.Err()
-		default:
+		if err := r.wait(ctx); err != nil {
+			return err
+		}
+		if seeks := r.takeSeeks(); len(seeks) > 0 {
+			c.applySeeks(ctx, r, seeks)
+			continue
 		}
 
-		msg, err := c.reader.ReadMessage(ctx)
+		fetchCtx, cancel := r.fetchContext(ctx)
+		msg, err := r.reader.FetchMessage(fetchCtx)
+		cancel()
 		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if fetchCtx.Err() != nil {
+				// ожидание прервано паузой или сменой смещения
+				continue
+			}
 			log.Printf("Ошибка чтения из Kafka: %v", err)
-			return fmt.Errorf("read message: %w", err)
+			return fmt.Errorf("чтение топика %s: %w", r.topic, err)
 		}
-		var order model.Order
-		if err := json.Unmarshal(msg.Value, &order); err != nil {
-			log.Printf("Ошибка парсинга JSON: %v, данные: %s", err, string(msg.Value))
-			continue
+		r.metrics.received.Add(1)
+		r.metrics.lag.Store(r.reader.Stats().Lag)
+
+		if err := c.process(ctx, r, msg); err != nil {
+			if ctx.Err() != nil {
+				// без коммита сообщение прочитается заново после перезапуска
+				return ctx.Err()
+			}
+			r.metrics.failed.Add(1)
+			log.Printf("Топик %s, раздел %d, смещение %d: %v", r.topic, msg.Partition, msg.Offset, err)
+			if err := c.deadLetterRetry(ctx, r, msg, err); err != nil {
+				// без коммита сообщение прочитается заново после перезапуска
+				return err
+			}
+		} else {
+			r.metrics.processed.Add(1)
 		}
 
-		if err := c.storage.SaveOrder(&order, cacheStore); err != nil {
-			log.Printf("Ошибка сохранения заказа %s: %v", order.OrderUID, err)
+		if err := r.reader.CommitMessages(ctx, msg); err != nil {
+			log.Printf("Ошибка коммита сообщения %s/%d/%d: %v", r.topic, msg.Partition, msg.Offset, err)
 			continue
 		}
+		r.mu.Lock()
+		r.committed[msg.Partition] = msg.Offset + 1
+		r.mu.Unlock()
+	}
+}
 
-		if cacheStore.Set(&order) {
-			log.Printf("Заказ %s добавлен в кэш", order.OrderUID)
+// process вызывает обработчик, повторяя временные ошибки по политике топика
+func (c *KafkaConsumer) process(ctx context.Context, r *route, msg kafka.Message) error {
+	backoff := r.policy.Backoff
+	for attempt := 0; ; attempt++ {
+		err := r.handler.Handle(ctx, msg)
+		if err == nil || IsPermanent(err) || attempt >= r.policy.Retries {
+			return err
 		}
+		r.metrics.retried.Add(1)
+		log.Printf("Топик %s, смещение %d: повтор %d из %d через %s: %v",
+			r.topic, msg.Offset, attempt+1, r.policy.Retries, backoff, err)
 
-		log.Printf("Заказ обработан: %s", order.OrderUID)
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		backoff *= 2
+	}
+}
+
+// deadLetterRetry повторяет отправку в DLQ, пока она не удастся или не отменят ctx.
+// Коммитить сообщение до этого нельзя: коммит следующего сдвинет смещение группы
+// и это сообщение потеряется.
+func (c *KafkaConsumer) deadLetterRetry(ctx context.Context, r *route, msg kafka.Message, cause error) error {
+	backoff := r.policy.Backoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	for {
+		err := c.deadLetter(ctx, r, msg, cause)
+		if err == nil {
+			return nil
+		}
+		log.Printf("Не удалось отправить сообщение %s/%d/%d в %s, повтор через %s: %v",
+			r.topic, msg.Partition, msg.Offset, r.policy.DLQTopic, backoff, err)
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		backoff = min(backoff*2, maxDLQBackoff)
+	}
+}
+
+// deadLetter отправляет сообщение в DLQ с причиной и исходным положением в заголовках
+func (c *KafkaConsumer) deadLetter(ctx context.Context, r *route, msg kafka.Message, cause error) error {
+	if r.policy.DLQTopic == "" {
+		return nil
+	}
+	headers := append(slices.Clone(msg.Headers),
+		kafka.Header{Key: HeaderDLQError, Value: []byte(cause.Error())},
+		kafka.Header{Key: HeaderDLQTopic, Value: []byte(msg.Topic)},
+		kafka.Header{Key: HeaderDLQPartition, Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: HeaderDLQOffset, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+	)
+	err := c.dlq.WriteMessages(ctx, kafka.Message{
+		Topic:   r.policy.DLQTopic,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	})
+	if err != nil {
+		return fmt.Errorf("отправка в %s: %w", r.policy.DLQTopic, err)
+	}
+	r.metrics.deadLettered.Add(1)
+	log.Printf("Сообщение %s/%d/%d отправлено в %s", r.topic, msg.Partition, msg.Offset, r.policy.DLQTopic)
+	return nil
+}
 
-		if err := c.reader.CommitMessages(ctx, msg); err != nil {
-			log.Printf("Ошибка коммита сообщения %s: %v", order.OrderUID, err)
+// значение заголовка сообщения, регистр ключа не важен
+func headerValue(headers []kafka.Header, key string) string {
+	for _, h := range headers {
+		if strings.EqualFold(h.Key, key) {
+			return string(h.Value)
 		}
 	}
+	return ""
 }
 
 func (c *KafkaConsumer) Close() {
-	if err := c.reader.Close(); err != nil {
-		log.Printf("Ошибка закрытия Kafka reader: %v", err)
+	var wg sync.WaitGroup
+	for _, r := range c.routes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.mu.Lock()
+			rd := r.reader
+			r.mu.Unlock()
+			if err := rd.Close(); err != nil {
+				log.Printf("Ошибка закрытия Kafka reader топика %s: %v", r.topic, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := c.dlq.Close(); err != nil {
+		log.Printf("Ошибка закрытия Kafka writer DLQ: %v", err)
 	}
 }
@@ -0,0 +1,260 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeReader отдаёт заранее заданные сообщения, потом ждёт отмены контекста
+type fakeReader struct {
+	mu        sync.Mutex
+	msgs      []kafka.Message
+	committed []int64
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	r.mu.Lock()
+	if len(r.msgs) > 0 {
+		msg := r.msgs[0]
+		r.msgs = r.msgs[1:]
+		r.mu.Unlock()
+		return msg, nil
+	}
+	r.mu.Unlock()
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (r *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range msgs {
+		r.committed = append(r.committed, m.Offset)
+	}
+	return nil
+}
+
+func (r *fakeReader) Stats() kafka.ReaderStats { return kafka.ReaderStats{} }
+func (r *fakeReader) Close() error             { return nil }
+
+type fakeWriter struct {
+	mu   sync.Mutex
+	msgs []kafka.Message
+	// сколько первых записей завершить ошибкой
+	fail int
+}
+
+func (w *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fail > 0 {
+		w.fail--
+		return errors.New("брокер недоступен")
+	}
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error { return nil }
+
+func TestConsume_RetryAndDLQ(t *testing.T) {
+	dlq := &fakeWriter{}
+	c := &KafkaConsumer{dlq: dlq, metrics: NewMetrics()}
+
+	ordersReader := &fakeReader{msgs: []kafka.Message{
+		{Topic: "orders", Offset: 0, Value: []byte("ok")},
+		{Topic: "orders", Offset: 1, Value: []byte("flaky")},
+		{Topic: "orders", Offset: 2, Value: []byte("broken")},
+		{Topic: "orders", Offset: 3, Value: []byte("down")},
+	}}
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	c.register("orders", HandlerFunc(func(_ context.Context, msg kafka.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		v := string(msg.Value)
+		attempts[v]++
+		switch {
+		case v == "flaky" && attempts[v] < 2:
+			return errors.New("временная ошибка")
+		case v == "broken":
+			return Permanent(errors.New("некорректное сообщение"))
+		case v == "down":
+			return errors.New("бд недоступна")
+		}
+		return nil
+	}), Policy{Retries: 2, DLQTopic: "orders.dlq"}, ordersReader)
+
+	// у второго топика нет DLQ: ошибка только учитывается
+	statusReader := &fakeReader{msgs: []kafka.Message{{Topic: "statuses", Offset: 7}}}
+	c.register("statuses", HandlerFunc(func(context.Context, kafka.Message) error {
+		return Permanent(errors.New("неизвестный статус"))
+	}), Policy{}, statusReader)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Consume(ctx) }()
+
+	waitCommitted(t, ordersReader, 4)
+	waitCommitted(t, statusReader, 1)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Consume вернул ошибку после отмены: %v", err)
+	}
+
+	if attempts["flaky"] != 2 || attempts["broken"] != 1 || attempts["down"] != 3 {
+		t.Errorf("неожиданное число попыток: %v", attempts)
+	}
+	if len(dlq.msgs) != 2 {
+		t.Fatalf("в DLQ ожидалось 2 сообщения, получено %d", len(dlq.msgs))
+	}
+	first := dlq.msgs[0]
+	if first.Topic != "orders.dlq" || string(first.Value) != "broken" ||
+		headerValue(first.Headers, HeaderDLQOffset) != "2" ||
+		headerValue(first.Headers, HeaderDLQError) != "некорректное сообщение" {
+		t.Errorf("неверное сообщение DLQ: %+v", first)
+	}
+
+	m := c.Metrics().Snapshot()
+	want := TopicMetrics{Received: 4, Processed: 2, Failed: 2, Retried: 3, DeadLettered: 2}
+	if m["orders"] != want {
+		t.Errorf("метрики orders: получено %+v, ожидалось %+v", m["orders"], want)
+	}
+	if m["statuses"].Failed != 1 || m["statuses"].DeadLettered != 0 {
+		t.Errorf("метрики statuses: %+v", m["statuses"])
+	}
+	if off := c.Offsets(); off["orders"][0] != 4 || off["statuses"][0] != 8 {
+		t.Errorf("неверные закоммиченные смещения: %v", off)
+	}
+}
+
+func TestConsume_DLQFailureBlocksCommit(t *testing.T) {
+	dlq := &fakeWriter{fail: 2}
+	c := &KafkaConsumer{dlq: dlq, metrics: NewMetrics()}
+	rd := &fakeReader{msgs: []kafka.Message{{Topic: "orders", Offset: 0}, {Topic: "orders", Offset: 1}}}
+	c.register("orders", HandlerFunc(func(_ context.Context, msg kafka.Message) error {
+		if msg.Offset == 0 {
+			return Permanent(errors.New("некорректное сообщение"))
+		}
+		return nil
+	}), Policy{Backoff: time.Millisecond, DLQTopic: "orders.dlq"}, rd)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Consume(ctx) }()
+	waitCommitted(t, rd, 2)
+	cancel()
+	<-done
+
+	if len(dlq.msgs) != 1 || dlq.fail != 0 {
+		t.Fatalf("отправка в DLQ не повторена: сообщений %d, осталось ошибок %d", len(dlq.msgs), dlq.fail)
+	}
+	if !slices.Equal(rd.committed, []int64{0, 1}) {
+		t.Errorf("неверный порядок коммитов: %v", rd.committed)
+	}
+
+	// DLQ недоступна до остановки: сообщение не коммитится
+	dlq = &fakeWriter{fail: 1 << 30}
+	c = &KafkaConsumer{dlq: dlq, metrics: NewMetrics()}
+	rd = &fakeReader{msgs: []kafka.Message{{Topic: "orders", Offset: 0}}}
+	c.register("orders", HandlerFunc(func(context.Context, kafka.Message) error {
+		return Permanent(errors.New("некорректное сообщение"))
+	}), Policy{Backoff: time.Millisecond, DLQTopic: "orders.dlq"}, rd)
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() { done <- c.Consume(ctx) }()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-done
+	if len(rd.committed) != 0 {
+		t.Errorf("сообщение закоммичено без записи в DLQ: %v", rd.committed)
+	}
+}
+
+func waitCommitted(t *testing.T, r *fakeReader, n int) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		r.mu.Lock()
+		got := len(r.committed)
+		r.mu.Unlock()
+		if got >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("не дождались коммита %d сообщений", n)
+}
+
+func TestConsume_PauseAndSeek(t *testing.T) {
+	c := &KafkaConsumer{dlq: &fakeWriter{}, metrics: NewMetrics()}
+	first := &fakeReader{msgs: []kafka.Message{{Topic: "orders", Offset: 0}}}
+	second := &fakeReader{msgs: []kafka.Message{{Topic: "orders", Offset: 5}}}
+	handled := make(chan int64, 10)
+	r := c.register("orders", HandlerFunc(func(_ context.Context, msg kafka.Message) error {
+		handled <- msg.Offset
+		return nil
+	}), Policy{}, first)
+	r.newReader = func() reader { return second }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Consume(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	if off := <-handled; off != 0 {
+		t.Fatalf("первым обработано смещение %d", off)
+	}
+	if err := c.Pause("statuses"); !errors.Is(err, ErrUnknownTopic) {
+		t.Errorf("пауза неизвестного топика: %v", err)
+	}
+	if err := c.Pause(""); err != nil {
+		t.Fatal(err)
+	}
+	// на паузе новые сообщения не читаются
+	first.mu.Lock()
+	first.msgs = append(first.msgs, kafka.Message{Topic: "orders", Offset: 1})
+	first.mu.Unlock()
+	select {
+	case off := <-handled:
+		t.Fatalf("на паузе обработано смещение %d", off)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// смена смещения применяется и на паузе
+	if err := c.Seek(ctx, "orders", 0, 5); err != nil {
+		t.Fatal(err)
+	}
+	first.mu.Lock()
+	committed := slices.Clone(first.committed)
+	first.mu.Unlock()
+	if !slices.Equal(committed, []int64{0, 4}) {
+		t.Errorf("ожидался коммит смещения 4 через прежний reader, закоммичено %v", committed)
+	}
+	if st, _ := c.Status(ctx); !st.Topics[0].Paused || st.Topics[0].Committed[0] != 5 {
+		t.Errorf("неверное состояние после смены смещения: %+v", st.Topics)
+	}
+
+	if err := c.Resume("orders"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case off := <-handled:
+		if off != 5 {
+			t.Errorf("после смены смещения обработано %d, ожидалось 5", off)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("после снятия паузы сообщение не обработано")
+	}
+	waitCommitted(t, second, 1)
+	if off := c.Offsets()["orders"][0]; off != 6 {
+		t.Errorf("следующее смещение %d, ожидалось 6", off)
+	}
+}
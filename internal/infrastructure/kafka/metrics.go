@@ -0,0 +1,60 @@
+package kafka
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// TopicMetrics - счётчики одного топика с момента запуска
+type TopicMetrics struct {
+	Received     int64 `json:"received"`
+	Processed    int64 `json:"processed"`
+	Failed       int64 `json:"failed"`
+	Retried      int64 `json:"retried"`
+	DeadLettered int64 `json:"dead_lettered"`
+	// отставание группы по последнему прочитанному сообщению
+	Lag int64 `json:"lag"`
+}
+
+type topicMetrics struct {
+	received, processed, failed, retried, deadLettered, lag atomic.Int64
+}
+
+// Metrics собирает счётчики всех топиков consumer
+type Metrics struct {
+	mu     sync.Mutex
+	topics map[string]*topicMetrics
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{topics: make(map[string]*topicMetrics)}
+}
+
+func (m *Metrics) topic(name string) *topicMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.topics[name]
+	if !ok {
+		t = &topicMetrics{}
+		m.topics[name] = t
+	}
+	return t
+}
+
+// Snapshot возвращает текущие значения счётчиков по топикам
+func (m *Metrics) Snapshot() map[string]TopicMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]TopicMetrics, len(m.topics))
+	for name, t := range m.topics {
+		out[name] = TopicMetrics{
+			Received:     t.received.Load(),
+			Processed:    t.processed.Load(),
+			Failed:       t.failed.Load(),
+			Retried:      t.retried.Load(),
+			DeadLettered: t.deadLettered.Load(),
+			Lag:          t.lag.Load(),
+		}
+	}
+	return out
+}
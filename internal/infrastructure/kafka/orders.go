@@ -0,0 +1,132 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"demo-service/internal/audit"
+	"demo-service/internal/codec"
+	"demo-service/internal/infrastructure/cache"
+	"demo-service/internal/infrastructure/postgres"
+	"demo-service/internal/model"
+	"demo-service/internal/reconcile"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Publisher получает заказы после успешного сохранения
+type Publisher interface {
+	Publish(order *model.Order)
+}
+
+// OrderHandler - обработчик топика заказов: разбор, проверка, сохранение в бд,
+// кеш и рассылка
+type OrderHandler struct {
+	storage   *postgres.Postgres
+	cache     *cache.Cache
+	publisher Publisher
+	decoder   *codec.Decoder
+	reconcile bool
+	auditor   *audit.Auditor
+}
+
+// Option настраивает OrderHandler
+type Option func(*OrderHandler)
+
+// WithDecoder задаёт декодер сообщений, по умолчанию - без реестра схем
+func WithDecoder(d *codec.Decoder) Option {
+	return func(h *OrderHandler) { h.decoder = d }
+}
+
+// WithPublisher подключает рассылку сохранённых заказов
+func WithPublisher(p Publisher) Option {
+	return func(h *OrderHandler) { h.publisher = p }
+}
+
+// WithReconcile включает сверку сумм при приёме: расхождения пишутся
+// в журнал, заказ всё равно сохраняется
+func WithReconcile(enabled bool) Option {
+	return func(h *OrderHandler) { h.reconcile = enabled }
+}
+
+// WithAuditor пишет сохранённые заказы в журнал аудита
+func WithAuditor(a *audit.Auditor) Option {
+	return func(h *OrderHandler) { h.auditor = a }
+}
+
+func NewOrderHandler(storage *postgres.Postgres, cacheStore *cache.Cache, opts ...Option) *OrderHandler {
+	h := &OrderHandler{storage: storage, cache: cacheStore, decoder: codec.NewDecoder(nil)}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *OrderHandler) Handle(ctx context.Context, msg kafka.Message) error {
+	order, err := h.handle(ctx, msg, false)
+	if err != nil {
+		return err
+	}
+	log.Printf("Заказ обработан: %s", order.OrderUID)
+	return nil
+}
+
+// decode разбирает значение сообщения в формате из заголовка content-type
+// и проверяет обязательные поля
+func (h *OrderHandler) decode(ctx context.Context, msg kafka.Message) (*model.Order, error) {
+	order, err := h.decoder.Decode(ctx, headerValue(msg.Headers, codec.HeaderContentType), msg.Value)
+	if err != nil {
+		return nil, Permanent(fmt.Errorf("ошибка разбора сообщения: %w, данные: %q", err, msg.Value))
+	}
+	if err := order.Validate(); err != nil {
+		return nil, Permanent(err)
+	}
+	return order, nil
+}
+
+// handle проводит сообщение через обработку. overwrite помечает
+// переобработку сообщений, в журнале аудита она записывается отдельно.
+func (h *OrderHandler) handle(ctx context.Context, msg kafka.Message, overwrite bool) (*model.Order, error) {
+	order, err := h.decode(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.reconcile {
+		for _, issue := range reconcile.Check(order) {
+			log.Printf("Заказ %s не сходится (%s): %s", order.OrderUID, issue.Rule, issue.Detail)
+		}
+	}
+
+	if err := h.storage.SaveOrder(order, h.cache); err != nil {
+		err = fmt.Errorf("ошибка сохранения заказа %s: %w", order.OrderUID, err)
+		// такие данные не сохранятся и при повторе
+		if errors.Is(err, postgres.ErrInvalid) || errors.Is(err, postgres.ErrConflict) {
+			err = Permanent(err)
+		}
+		return nil, err
+	}
+
+	actor := "kafka:" + msg.Topic
+	if overwrite {
+		actor = "replay:" + msg.Topic
+	}
+	h.auditor.Record(ctx, audit.Event{
+		Actor:      actor,
+		Action:     audit.ActionOrderWrite,
+		OrderUID:   order.OrderUID,
+		CustomerID: order.CustomerID,
+		Details:    fmt.Sprintf("partition %d, offset %d", msg.Partition, msg.Offset),
+	})
+
+	// сохранённая версия заменяет закешированную, иначе GET отдаст старое
+	// тело и ETag
+	h.cache.Replace(order)
+
+	if h.publisher != nil {
+		h.publisher.Publish(order)
+	}
+	return order, nil
+}
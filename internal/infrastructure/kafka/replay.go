@@ -0,0 +1,523 @@
+package kafka
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"demo-service/internal/config"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// сколько ждать следующего сообщения, прежде чем считать раздел дочитанным
+// (в конце диапазона могут быть служебные записи транзакций без данных)
+const replayIdleTimeout = 10 * time.Second
+
+// ход переобработки коммитится группе задачи через столько сообщений раздела
+const replayCommitEvery = 500
+
+const (
+	// сколько хранить завершённую задачу
+	replayJobTTL = 24 * time.Hour
+	// сколько завершённых задач хранить не больше, старые удаляются первыми
+	maxFinishedReplayJobs = 100
+)
+
+// Состояния задачи переобработки
+const (
+	ReplayRunning   = "running"
+	ReplayDone      = "done"
+	ReplayFailed    = "failed"
+	ReplayCancelled = "cancelled"
+)
+
+var (
+	// ErrReplayNotFound - задачи с таким id нет
+	ErrReplayNotFound = errors.New("задача переобработки не найдена")
+	// ErrInvalidReplay - запрос переобработки задан неверно
+	ErrInvalidReplay = errors.New("некорректный запрос переобработки")
+)
+
+// ReplayRequest - диапазон сообщений для переобработки. Начало задаётся
+// смещениями по разделам, временем или прерванной задачей, конец
+// (не включительно) - смещениями или временем, а по умолчанию это конец
+// раздела на момент запуска.
+type ReplayRequest struct {
+	// пусто - все разделы топика
+	Partitions  []int         `json:"partitions,omitempty"`
+	FromOffsets map[int]int64 `json:"from_offsets,omitempty"`
+	FromTime    time.Time     `json:"from_time,omitempty"`
+	ToOffsets   map[int]int64 `json:"to_offsets,omitempty"`
+	ToTime      time.Time     `json:"to_time,omitempty"`
+	// id прерванной задачи: продолжить с закоммиченных смещений её группы
+	Resume string `json:"resume,omitempty"`
+	// только разобрать сообщения, ничего не сохраняя
+	DryRun bool `json:"dry_run"`
+}
+
+func (r ReplayRequest) validate() error {
+	if r.Resume != "" {
+		if len(r.FromOffsets) > 0 || !r.FromTime.IsZero() {
+			return fmt.Errorf("%w: resume и from_offsets, from_time взаимоисключающие", ErrInvalidReplay)
+		}
+		if r.DryRun {
+			return fmt.Errorf("%w: dry_run не продолжается, у него нет группы", ErrInvalidReplay)
+		}
+	} else if len(r.FromOffsets) == 0 && r.FromTime.IsZero() {
+		return fmt.Errorf("%w: нужно задать from_offsets, from_time или resume", ErrInvalidReplay)
+	}
+	if len(r.FromOffsets) > 0 && !r.FromTime.IsZero() {
+		return fmt.Errorf("%w: from_offsets и from_time взаимоисключающие", ErrInvalidReplay)
+	}
+	if len(r.ToOffsets) > 0 && !r.ToTime.IsZero() {
+		return fmt.Errorf("%w: to_offsets и to_time взаимоисключающие", ErrInvalidReplay)
+	}
+	if !r.FromTime.IsZero() && !r.ToTime.IsZero() && !r.ToTime.After(r.FromTime) {
+		return fmt.Errorf("%w: to_time должен быть позже from_time", ErrInvalidReplay)
+	}
+	return nil
+}
+
+// PartitionProgress - ход переобработки одного раздела
+type PartitionProgress struct {
+	Partition int   `json:"partition"`
+	From      int64 `json:"from"`
+	To        int64 `json:"to"`
+	// следующее смещение для чтения
+	Current   int64 `json:"current"`
+	Processed int64 `json:"processed"`
+	Failed    int64 `json:"failed"`
+}
+
+// ReplayProgress - снимок состояния задачи
+type ReplayProgress struct {
+	ID    string `json:"id"`
+	Topic string `json:"topic"`
+	// группа, которой коммитится ход задачи; видна в отставании групп,
+	// по ней задачу можно продолжить. У dry_run группы нет.
+	Group      string              `json:"group,omitempty"`
+	State      string              `json:"state"`
+	DryRun     bool                `json:"dry_run"`
+	StartedAt  time.Time           `json:"started_at"`
+	FinishedAt *time.Time          `json:"finished_at,omitempty"`
+	Total      int64               `json:"total"`
+	Processed  int64               `json:"processed"`
+	Failed     int64               `json:"failed"`
+	Error      string              `json:"error,omitempty"`
+	Partitions []PartitionProgress `json:"partitions"`
+}
+
+type replayJob struct {
+	mu       sync.Mutex
+	progress ReplayProgress
+	cancel   context.CancelFunc
+}
+
+func (j *replayJob) snapshot() ReplayProgress {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	p := j.progress
+	p.Partitions = append([]PartitionProgress(nil), j.progress.Partitions...)
+	return p
+}
+
+// Replayer переобрабатывает диапазоны топика заказов через тот же OrderHandler,
+// что и KafkaConsumer: разбор, сохранение в бд, кеш и рассылка. Разделы читаются
+// напрямую, вне группы основного consumer, поэтому его смещения не меняются.
+// Ход задачи коммитится её собственной группе <KAFKA_GROUP_ID>-replay-<id>.
+type Replayer struct {
+	cfg     config.Kafka
+	topic   string
+	handler *OrderHandler
+	client  *kafka.Client
+
+	mu   sync.Mutex
+	jobs map[string]*replayJob
+	// выполняющиеся задачи, их ждёт Close
+	running sync.WaitGroup
+}
+
+func NewReplayer(cfg config.Kafka, handler *OrderHandler) (*Replayer, error) {
+	client, err := NewClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("настройки Kafka: %w", err)
+	}
+	return &Replayer{
+		cfg:     cfg,
+		topic:   cfg.Topic,
+		handler: handler,
+		client:  client,
+		jobs:    make(map[string]*replayJob),
+	}, nil
+}
+
+// Start проверяет запрос, вычисляет диапазоны смещений и запускает задачу в фоне
+func (r *Replayer) Start(ctx context.Context, req ReplayRequest) (ReplayProgress, error) {
+	if err := req.validate(); err != nil {
+		return ReplayProgress{}, err
+	}
+	id := newJobID()
+	var group string
+	switch {
+	case req.Resume != "":
+		var err error
+		if group, err = r.resumeGroup(req.Resume); err != nil {
+			return ReplayProgress{}, err
+		}
+	case !req.DryRun:
+		group = r.group(id)
+	}
+	ranges, err := r.resolve(ctx, req, group)
+	if err != nil {
+		return ReplayProgress{}, err
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	job := &replayJob{cancel: cancel, progress: ReplayProgress{
+		ID:         id,
+		Topic:      r.topic,
+		Group:      group,
+		State:      ReplayRunning,
+		DryRun:     req.DryRun,
+		StartedAt:  time.Now().UTC(),
+		Partitions: ranges,
+	}}
+	for _, p := range ranges {
+		job.progress.Total += p.To - p.From
+	}
+
+	r.mu.Lock()
+	r.pruneLocked(time.Now())
+	r.jobs[job.progress.ID] = job
+	r.mu.Unlock()
+
+	log.Printf("Запущена переобработка %s топика %s: %d сообщений, dry_run=%v",
+		job.progress.ID, r.topic, job.progress.Total, req.DryRun)
+	r.running.Add(1)
+	go func() {
+		defer r.running.Done()
+		r.run(runCtx, job)
+	}()
+	return job.snapshot(), nil
+}
+
+func (r *Replayer) group(id string) string {
+	return r.cfg.GroupID + "-replay-" + id
+}
+
+// resumeGroup - группа задачи id: продолженная задача коммитит в группу
+// исходной, поэтому продолжить можно и её. Задачи, выполняющейся в этой
+// группе, быть не должно - иначе смещения будут перетирать друг друга.
+func (r *Replayer) resumeGroup(id string) (string, error) {
+	group := r.group(id)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if job, ok := r.jobs[id]; ok {
+		if group = job.snapshot().Group; group == "" {
+			return "", fmt.Errorf("%w: у задачи %s (dry_run) нет группы", ErrInvalidReplay, id)
+		}
+	}
+	for _, job := range r.jobs {
+		if p := job.snapshot(); p.Group == group && p.State == ReplayRunning {
+			return "", fmt.Errorf("%w: задача %s ещё выполняется", ErrInvalidReplay, p.ID)
+		}
+	}
+	return group, nil
+}
+
+// commit коммитит группе задачи следующие смещения. Ошибка только
+// логируется: переобработка от неё не зависит, теряется лишь точка продолжения.
+func (r *Replayer) commit(ctx context.Context, job *replayJob, offsets map[int]int64) {
+	if job.progress.Group == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if err := commitOffsets(ctx, r.client, job.progress.Group, r.topic, offsets); err != nil {
+		log.Printf("Переобработка %s: %v", job.progress.ID, err)
+	}
+}
+
+// Close отменяет выполняющиеся задачи и ждёт их завершения
+func (r *Replayer) Close() {
+	r.mu.Lock()
+	for _, job := range r.jobs {
+		job.cancel()
+	}
+	r.mu.Unlock()
+	r.running.Wait()
+}
+
+// Get возвращает состояние задачи
+func (r *Replayer) Get(id string) (ReplayProgress, error) {
+	r.mu.Lock()
+	job, ok := r.jobs[id]
+	r.mu.Unlock()
+	if !ok {
+		return ReplayProgress{}, ErrReplayNotFound
+	}
+	return job.snapshot(), nil
+}
+
+// List возвращает все задачи, начиная с последней
+func (r *Replayer) List() []ReplayProgress {
+	r.mu.Lock()
+	r.pruneLocked(time.Now())
+	out := make([]ReplayProgress, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		out = append(out, job.snapshot())
+	}
+	r.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.After(out[j].StartedAt) })
+	return out
+}
+
+// Cancel останавливает задачу
+func (r *Replayer) Cancel(id string) error {
+	r.mu.Lock()
+	job, ok := r.jobs[id]
+	r.mu.Unlock()
+	if !ok {
+		return ErrReplayNotFound
+	}
+	job.cancel()
+	return nil
+}
+
+// pruneLocked удаляет завершённые задачи старше replayJobTTL и самые старые
+// сверх maxFinishedReplayJobs. Выполняющиеся задачи не трогает.
+func (r *Replayer) pruneLocked(now time.Time) {
+	type finished struct {
+		id string
+		at time.Time
+	}
+	var done []finished
+	for id, job := range r.jobs {
+		job.mu.Lock()
+		at := job.progress.FinishedAt
+		job.mu.Unlock()
+		if at == nil {
+			continue
+		}
+		if now.Sub(*at) > replayJobTTL {
+			delete(r.jobs, id)
+			continue
+		}
+		done = append(done, finished{id, *at})
+	}
+	if len(done) <= maxFinishedReplayJobs {
+		return
+	}
+	sort.Slice(done, func(i, j int) bool { return done[i].at.Before(done[j].at) })
+	for _, f := range done[:len(done)-maxFinishedReplayJobs] {
+		delete(r.jobs, f.id)
+	}
+}
+
+func (r *Replayer) run(ctx context.Context, job *replayJob) {
+	defer job.cancel()
+
+	// начало каждого раздела коммитится сразу: продолжение найдёт все разделы задачи
+	start := make(map[int]int64, len(job.progress.Partitions))
+	for _, p := range job.progress.Partitions {
+		start[p.Partition] = p.From
+	}
+	r.commit(ctx, job, start)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(job.progress.Partitions))
+	for i := range job.progress.Partitions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := r.replayPartition(ctx, job, i); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	now := time.Now().UTC()
+	job.progress.FinishedAt = &now
+	switch err := <-errs; {
+	case ctx.Err() != nil:
+		job.progress.State = ReplayCancelled
+	case err != nil:
+		job.progress.State = ReplayFailed
+		job.progress.Error = err.Error()
+	default:
+		job.progress.State = ReplayDone
+	}
+	log.Printf("Переобработка %s завершена: %s, обработано %d, ошибок %d",
+		job.progress.ID, job.progress.State, job.progress.Processed, job.progress.Failed)
+}
+
+func (r *Replayer) replayPartition(ctx context.Context, job *replayJob, i int) error {
+	job.mu.Lock()
+	part := job.progress.Partitions[i]
+	dryRun := job.progress.DryRun
+	job.mu.Unlock()
+	if part.From >= part.To {
+		return nil
+	}
+
+	rc, err := readerConfig(r.cfg, r.topic, "")
+	if err != nil {
+		return err
+	}
+	rc.Partition = part.Partition
+	rc.MinBytes = 1
+	reader := kafka.NewReader(rc)
+	defer reader.Close()
+	if err := reader.SetOffset(part.From); err != nil {
+		return fmt.Errorf("раздел %d: %w", part.Partition, err)
+	}
+
+	current := part.From
+	defer func() { r.commit(ctx, job, map[int]int64{part.Partition: current}) }()
+	for n := 1; ; n++ {
+		readCtx, cancel := context.WithTimeout(ctx, replayIdleTimeout)
+		msg, err := reader.ReadMessage(readCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				log.Printf("Переобработка %s: раздел %d без новых сообщений, остановлен на %d",
+					job.progress.ID, part.Partition, reader.Offset())
+				return nil
+			}
+			return fmt.Errorf("раздел %d: %w", part.Partition, err)
+		}
+		if msg.Offset >= part.To {
+			return nil
+		}
+
+		if dryRun {
+			_, err = r.handler.decode(ctx, msg)
+		} else {
+			_, err = r.handler.handle(ctx, msg, true)
+		}
+		if err != nil {
+			log.Printf("Переобработка %s, раздел %d, смещение %d: %v", job.progress.ID, part.Partition, msg.Offset, err)
+		}
+
+		current = msg.Offset + 1
+		if n%replayCommitEvery == 0 {
+			r.commit(ctx, job, map[int]int64{part.Partition: current})
+		}
+
+		job.mu.Lock()
+		p := &job.progress.Partitions[i]
+		p.Current = current
+		if err != nil {
+			p.Failed++
+			job.progress.Failed++
+		} else {
+			p.Processed++
+			job.progress.Processed++
+		}
+		job.mu.Unlock()
+
+		if msg.Offset+1 >= part.To {
+			return nil
+		}
+	}
+}
+
+// resolve переводит запрос в диапазоны смещений [From, To) по разделам.
+// При продолжении начало - смещения группы, разделы без них пропускаются.
+func (r *Replayer) resolve(ctx context.Context, req ReplayRequest, group string) ([]PartitionProgress, error) {
+	partitions := req.Partitions
+	if len(partitions) == 0 {
+		var err error
+		if partitions, err = topicPartitions(ctx, r.client, r.topic); err != nil {
+			return nil, err
+		}
+	}
+	if req.Resume != "" {
+		committed, err := groupOffsets(ctx, r.client, group, r.topic, partitions)
+		if err != nil {
+			return nil, err
+		}
+		if len(committed) == 0 {
+			return nil, fmt.Errorf("%w: у задачи %s нет закоммиченных смещений", ErrInvalidReplay, req.Resume)
+		}
+		partitions = partitions[:0:0]
+		for p := range committed {
+			partitions = append(partitions, p)
+		}
+		req.FromOffsets = committed
+	}
+
+	first, err := listOffsets(ctx, r.client, r.topic, partitions, kafka.FirstOffsetOf)
+	if err != nil {
+		return nil, err
+	}
+	last, err := listOffsets(ctx, r.client, r.topic, partitions, kafka.LastOffsetOf)
+	if err != nil {
+		return nil, err
+	}
+	var fromTime, toTime map[int]int64
+	if !req.FromTime.IsZero() {
+		if fromTime, err = listOffsets(ctx, r.client, r.topic, partitions, timeOffsetOf(req.FromTime)); err != nil {
+			return nil, err
+		}
+	}
+	if !req.ToTime.IsZero() {
+		if toTime, err = listOffsets(ctx, r.client, r.topic, partitions, timeOffsetOf(req.ToTime)); err != nil {
+			return nil, err
+		}
+	}
+
+	return buildRanges(req, partitions, first, last, fromTime, toTime), nil
+}
+
+// buildRanges собирает диапазоны из смещений, полученных от брокера.
+// Смещение -1 по времени означает, что после этого момента сообщений нет.
+func buildRanges(req ReplayRequest, partitions []int, first, last, fromTime, toTime map[int]int64) []PartitionProgress {
+	clamp := func(v, lo, hi int64) int64 {
+		return max(lo, min(v, hi))
+	}
+
+	ranges := make([]PartitionProgress, 0, len(partitions))
+	for _, p := range partitions {
+		lo, hi := first[p], last[p]
+
+		from := hi
+		if off, ok := req.FromOffsets[p]; ok {
+			from = off
+		} else if off, ok := fromTime[p]; ok && off >= 0 {
+			from = off
+		}
+		to := hi
+		if off, ok := req.ToOffsets[p]; ok {
+			to = off
+		} else if off, ok := toTime[p]; ok && off >= 0 {
+			to = off
+		}
+
+		from = clamp(from, lo, hi)
+		to = clamp(to, from, hi)
+		ranges = append(ranges, PartitionProgress{Partition: p, From: from, To: to, Current: from})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Partition < ranges[j].Partition })
+	return ranges
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
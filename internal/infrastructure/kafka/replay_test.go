@@ -0,0 +1,96 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestReplayRequest_Validate(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name string
+		req  ReplayRequest
+		ok   bool
+	}{
+		{"без начала", ReplayRequest{}, false},
+		{"смещения", ReplayRequest{FromOffsets: map[int]int64{0: 10}}, true},
+		{"время", ReplayRequest{FromTime: now}, true},
+		{"смещения и время", ReplayRequest{FromOffsets: map[int]int64{0: 10}, FromTime: now}, false},
+		{"конец раньше начала", ReplayRequest{FromTime: now, ToTime: now.Add(-time.Hour)}, false},
+		{"продолжение", ReplayRequest{Resume: "abc", ToTime: now}, true},
+		{"продолжение и начало", ReplayRequest{Resume: "abc", FromTime: now}, false},
+		{"продолжение dry_run", ReplayRequest{Resume: "abc", DryRun: true}, false},
+	}
+	for _, tc := range cases {
+		err := tc.req.validate()
+		if tc.ok && err != nil {
+			t.Errorf("%s: неожиданная ошибка %v", tc.name, err)
+		}
+		if !tc.ok && !errors.Is(err, ErrInvalidReplay) {
+			t.Errorf("%s: ожидалась ErrInvalidReplay, получено %v", tc.name, err)
+		}
+	}
+}
+
+func TestBuildRanges(t *testing.T) {
+	first := map[int]int64{0: 100, 1: 0}
+	last := map[int]int64{0: 500, 1: 50}
+
+	// смещения обрезаются по границам раздела, конец по умолчанию - последнее смещение
+	req := ReplayRequest{FromOffsets: map[int]int64{0: 50, 1: 20}, ToOffsets: map[int]int64{1: 40}}
+	got := buildRanges(req, []int{1, 0}, first, last, nil, nil)
+	want := []PartitionProgress{
+		{Partition: 0, From: 100, To: 500, Current: 100},
+		{Partition: 1, From: 20, To: 40, Current: 20},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("получено %+v, ожидалось %+v", got, want)
+	}
+
+	// -1 по времени: после этого момента сообщений в разделе нет
+	req = ReplayRequest{FromTime: time.Now()}
+	got = buildRanges(req, []int{0, 1}, first, last, map[int]int64{0: 300, 1: -1}, nil)
+	if got[0].From != 300 || got[0].To != 500 || got[1].From != 50 || got[1].To != 50 {
+		t.Errorf("неожиданные диапазоны по времени: %+v", got)
+	}
+}
+
+func TestReplayer_Prune(t *testing.T) {
+	now := time.Now()
+	job := func(finished time.Duration) *replayJob {
+		j := &replayJob{progress: ReplayProgress{State: ReplayRunning}}
+		if finished > 0 {
+			at := now.Add(-finished)
+			j.progress.FinishedAt = &at
+			j.progress.State = ReplayDone
+		}
+		return j
+	}
+	r := &Replayer{jobs: map[string]*replayJob{
+		"running": job(0),
+		"fresh":   job(time.Minute),
+		"expired": job(replayJobTTL + time.Minute),
+	}}
+	for i := range maxFinishedReplayJobs {
+		r.jobs[fmt.Sprint("old", i)] = job(time.Hour + time.Duration(i)*time.Second)
+	}
+
+	r.pruneLocked(now)
+	if _, ok := r.jobs["running"]; !ok {
+		t.Error("выполняющаяся задача удалена")
+	}
+	if _, ok := r.jobs["fresh"]; !ok {
+		t.Error("свежая задача удалена")
+	}
+	if _, ok := r.jobs["expired"]; ok {
+		t.Error("задача старше TTL не удалена")
+	}
+	if _, ok := r.jobs[fmt.Sprint("old", maxFinishedReplayJobs-1)]; ok {
+		t.Error("самая старая задача сверх лимита не удалена")
+	}
+	if got := len(r.jobs); got != maxFinishedReplayJobs+1 {
+		t.Errorf("осталось %d задач, ожидалось %d", got, maxFinishedReplayJobs+1)
+	}
+}
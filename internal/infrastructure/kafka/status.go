@@ -0,0 +1,120 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"demo-service/internal/audit"
+	"demo-service/internal/infrastructure/cache"
+	"demo-service/internal/infrastructure/postgres"
+	"demo-service/internal/model"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// StatusUpdate - сообщение топика смены статусов
+type StatusUpdate struct {
+	OrderUID string       `json:"order_uid"`
+	Status   model.Status `json:"status"`
+	Reason   string       `json:"reason,omitempty"`
+}
+
+// StatusHandler - обработчик топика смены статусов: переход проверяется
+// по жизненному циклу заказа, записывается в историю, кеш и рассылку
+type StatusHandler struct {
+	storage   *postgres.Postgres
+	cache     *cache.Cache
+	publisher Publisher
+	auditor   *audit.Auditor
+}
+
+// NewStatusHandler создаёт обработчик, publisher и auditor могут быть nil
+func NewStatusHandler(storage *postgres.Postgres, cacheStore *cache.Cache, publisher Publisher, auditor *audit.Auditor) *StatusHandler {
+	return &StatusHandler{storage: storage, cache: cacheStore, publisher: publisher, auditor: auditor}
+}
+
+func (h *StatusHandler) Handle(ctx context.Context, msg kafka.Message) error {
+	u, err := decodeStatusUpdate(msg.Value)
+	if err != nil {
+		return Permanent(err)
+	}
+	return h.apply(ctx, msg, u)
+}
+
+func (h *StatusHandler) apply(ctx context.Context, msg kafka.Message, u StatusUpdate) error {
+	order, err := h.storage.UpdateStatus(ctx, u.OrderUID, u.Status, u.Reason, postgres.SourceKafka)
+	if err != nil {
+		err = fmt.Errorf("ошибка смены статуса заказа %s: %w", u.OrderUID, err)
+		// ErrNotFound не постоянная: заказ мог ещё не дойти из своего топика
+		if errors.Is(err, postgres.ErrConflict) || errors.Is(err, postgres.ErrInvalid) {
+			err = Permanent(err)
+		}
+		return err
+	}
+
+	h.auditor.Record(ctx, audit.Event{
+		Actor:      "kafka:" + msg.Topic,
+		Action:     audit.ActionStatusChange,
+		OrderUID:   order.OrderUID,
+		CustomerID: order.CustomerID,
+		Details:    fmt.Sprintf("статус %s: %s", u.Status, u.Reason),
+	})
+	h.cache.Replace(order)
+	if h.publisher != nil {
+		h.publisher.Publish(order)
+	}
+	log.Printf("Статус заказа %s: %s", order.OrderUID, order.Status)
+	return nil
+}
+
+func decodeStatusUpdate(data []byte) (StatusUpdate, error) {
+	var u StatusUpdate
+	if err := json.Unmarshal(data, &u); err != nil {
+		return u, fmt.Errorf("ошибка разбора смены статуса: %w, данные: %q", err, data)
+	}
+	if u.OrderUID == "" {
+		return u, errors.New("в смене статуса нет order_uid")
+	}
+	if !u.Status.Valid() {
+		return u, fmt.Errorf("%w: %q", model.ErrUnknownStatus, u.Status)
+	}
+	return u, nil
+}
+
+// Cancellation - сообщение топика отмен
+type Cancellation struct {
+	OrderUID string `json:"order_uid"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// CancellationHandler - обработчик топика отмен: отмена проводится как смена
+// статуса на cancelled, с теми же проверками перехода, историей и рассылкой
+type CancellationHandler struct {
+	status *StatusHandler
+}
+
+func NewCancellationHandler(status *StatusHandler) *CancellationHandler {
+	return &CancellationHandler{status: status}
+}
+
+func (h *CancellationHandler) Handle(ctx context.Context, msg kafka.Message) error {
+	c, err := decodeCancellation(msg.Value)
+	if err != nil {
+		return Permanent(err)
+	}
+	return h.status.apply(ctx, msg, StatusUpdate{OrderUID: c.OrderUID, Status: model.StatusCancelled, Reason: c.Reason})
+}
+
+func decodeCancellation(data []byte) (Cancellation, error) {
+	var c Cancellation
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("ошибка разбора отмены: %w, данные: %q", err, data)
+	}
+	if c.OrderUID == "" {
+		return c, errors.New("в отмене нет order_uid")
+	}
+	return c, nil
+}
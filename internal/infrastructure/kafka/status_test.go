@@ -0,0 +1,29 @@
+package kafka
+
+import "testing"
+
+func TestDecodeStatusUpdate(t *testing.T) {
+	u, err := decodeStatusUpdate([]byte(`{"order_uid":"b563feb7b2b84b6test","status":"paid","reason":"оплачен"}`))
+	if err != nil || u.OrderUID != "b563feb7b2b84b6test" || u.Status != "paid" || u.Reason != "оплачен" {
+		t.Errorf("неверный разбор: %+v, %v", u, err)
+	}
+
+	for _, data := range []string{`{`, `{"status":"paid"}`, `{"order_uid":"x","status":"lost"}`} {
+		if _, err := decodeStatusUpdate([]byte(data)); err == nil {
+			t.Errorf("%s: ожидалась ошибка", data)
+		}
+	}
+}
+
+func TestDecodeCancellation(t *testing.T) {
+	c, err := decodeCancellation([]byte(`{"order_uid":"b563feb7b2b84b6test","reason":"передумал"}`))
+	if err != nil || c.OrderUID != "b563feb7b2b84b6test" || c.Reason != "передумал" {
+		t.Errorf("неверный разбор: %+v, %v", c, err)
+	}
+
+	for _, data := range []string{`{`, `{"reason":"передумал"}`} {
+		if _, err := decodeCancellation([]byte(data)); err == nil {
+			t.Errorf("%s: ожидалась ошибка", data)
+		}
+	}
+}
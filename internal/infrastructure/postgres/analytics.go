@@ -0,0 +1,268 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Bucket - шаг временного ряда аналитики
+type Bucket string
+
+const (
+	BucketDay   Bucket = "day"
+	BucketWeek  Bucket = "week"
+	BucketMonth Bucket = "month"
+)
+
+// ParseBucket проверяет шаг ряда, пустой - BucketDay
+func ParseBucket(s string) (Bucket, error) {
+	switch b := Bucket(s); b {
+	case "":
+		return BucketDay, nil
+	case BucketDay, BucketWeek, BucketMonth:
+		return b, nil
+	}
+	return "", fmt.Errorf("%w: неизвестный шаг %q, ожидалось day, week или month", ErrInvalid, s)
+}
+
+// Dimension - разрез аналитики заказов
+type Dimension string
+
+const (
+	DimDeliveryService Dimension = "delivery_service"
+	DimRegion          Dimension = "region"
+	DimCity            Dimension = "city"
+	DimBrand           Dimension = "brand"
+	DimNmID            Dimension = "nm_id"
+)
+
+// AnalyticsFilter - период и источник отчёта. Учитываются неудалённые
+// заказы, созданные в [From, To).
+type AnalyticsFilter struct {
+	From, To time.Time
+	// читать дневные сводки analytics_*_daily вместо заказов: быстрее, но данные
+	// на момент последнего обновления, а период расширяется до целых суток UTC
+	Rollup bool
+}
+
+// Period возвращает период, который на самом деле попадёт в отчёт: по сводкам
+// это целые сутки UTC, начало округляется вниз, конец - вверх
+func (f AnalyticsFilter) Period() (from, to time.Time) {
+	if !f.Rollup {
+		return f.From, f.To
+	}
+	from, to = f.From.Truncate(24*time.Hour), f.To.Truncate(24*time.Hour)
+	if to.Before(f.To) {
+		to = to.Add(24 * time.Hour)
+	}
+	return from, to
+}
+
+// SalesPoint - продажи в одной валюте за шаг ряда или за весь период.
+// Суммы - в единицах валюты, как в заказе.
+type SalesPoint struct {
+	Start    time.Time `json:"start,omitzero"`
+	Currency string    `json:"currency"`
+	Orders   int64     `json:"orders"`
+	Revenue  int64     `json:"revenue"`
+	Items    int64     `json:"items"`
+	// средняя сумма заказа
+	AvgBasket float64 `json:"avg_basket"`
+	// среднее число товаров в заказе
+	AvgItems float64 `json:"avg_items"`
+}
+
+func (s *SalesPoint) average() {
+	if s.Orders > 0 {
+		s.AvgBasket = float64(s.Revenue) / float64(s.Orders)
+		s.AvgItems = float64(s.Items) / float64(s.Orders)
+	}
+}
+
+// Breakdown - заказы с одним значением разреза. Выручка - по валютам.
+type Breakdown struct {
+	Key string `json:"key"`
+	// регион города в разрезе DimCity
+	Region string `json:"region,omitempty"`
+	Orders int64  `json:"orders"`
+	Items  int64  `json:"items"`
+	// доля заказов периода
+	Share   float64          `json:"share"`
+	Revenue map[string]int64 `json:"revenue"`
+}
+
+// TopItem - товары одного бренда или артикула: число проданных штук,
+// доля от всех проданных за период и выручка по валютам
+type TopItem struct {
+	Key      string           `json:"key"`
+	Quantity int64            `json:"quantity"`
+	Share    float64          `json:"share"`
+	Revenue  map[string]int64 `json:"revenue"`
+}
+
+// источники аналитики: заказы и товары напрямую или дневные сводки
+// с теми же столбцами, по одной строке на заказ или товар
+const (
+	liveOrders = `SELECT o.date_created AS at, p.currency, o.delivery_service, d.region, d.city,
+		1 AS orders, p.amount AS revenue, (SELECT count(*) FROM items i WHERE i.order_uid = o.order_uid) AS items
+		FROM orders o
+		LEFT JOIN payments p ON p.order_uid = o.order_uid
+		LEFT JOIN deliveries d ON d.order_uid = o.order_uid
+		WHERE o.deleted_at IS NULL`
+	rollupOrders = `SELECT day AS at, currency, delivery_service, region, city, orders, revenue, items
+		FROM analytics_orders_daily`
+	liveItems = `SELECT o.date_created AS at, p.currency, i.brand, i.nm_id, 1 AS quantity, i.total_price AS revenue
+		FROM items i
+		JOIN orders o ON o.order_uid = i.order_uid
+		LEFT JOIN payments p ON p.order_uid = i.order_uid
+		WHERE o.deleted_at IS NULL`
+	rollupItems = `SELECT day AS at, currency, brand, nm_id, quantity, revenue FROM analytics_items_daily`
+)
+
+// выражения значения разреза и его родителя
+var dimensions = map[Dimension][2]string{
+	DimDeliveryService: {`COALESCE(delivery_service, '')`, `''`},
+	DimRegion:          {`COALESCE(region, '')`, `''`},
+	DimCity:            {`COALESCE(city, '')`, `COALESCE(region, '')`},
+	DimBrand:           {`COALESCE(brand, '')`, `''`},
+	DimNmID:            {`COALESCE(nm_id, 0)::text`, `''`},
+}
+
+// source возвращает источник и границы периода
+func (f AnalyticsFilter) source(live, rollup string) (string, time.Time, time.Time) {
+	from, to := f.Period()
+	if f.Rollup {
+		return rollup, from, to
+	}
+	return live, from, to
+}
+
+// Sales возвращает продажи по шагам ряда и валютам и итоги периода по валютам
+func (p *Postgres) Sales(ctx context.Context, f AnalyticsFilter, bucket Bucket) (series, totals []SalesPoint, err error) {
+	src, from, to := f.source(liveOrders, rollupOrders)
+	rows, err := p.pool.Query(ctx, `
+		SELECT date_trunc($3, at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', COALESCE(currency, ''),
+		       sum(orders)::bigint, COALESCE(sum(revenue), 0)::bigint, sum(items)::bigint
+		FROM (`+src+`) s
+		WHERE at >= $1 AND at < $2
+		GROUP BY 1, 2
+		ORDER BY 1, 2`, from, to, string(bucket))
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка отчёта о продажах: %w", err)
+	}
+	series, totals = []SalesPoint{}, []SalesPoint{}
+	var pt SalesPoint
+	byCurrency := make(map[string]int)
+	_, err = pgx.ForEachRow(rows, []any{&pt.Start, &pt.Currency, &pt.Orders, &pt.Revenue, &pt.Items}, func() error {
+		pt.Start = pt.Start.UTC()
+		pt.average()
+		series = append(series, pt)
+
+		i, ok := byCurrency[pt.Currency]
+		if !ok {
+			i = len(totals)
+			byCurrency[pt.Currency] = i
+			totals = append(totals, SalesPoint{Currency: pt.Currency})
+		}
+		totals[i].Orders += pt.Orders
+		totals[i].Revenue += pt.Revenue
+		totals[i].Items += pt.Items
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка отчёта о продажах: %w", err)
+	}
+	for i := range totals {
+		totals[i].average()
+	}
+	return series, totals, nil
+}
+
+// breakdownQuery группирует источник по разрезу: сначала по значению и валюте,
+// затем собирает выручку по валютам в объект. Доля считается до LIMIT,
+// то есть от всего периода.
+func breakdownQuery(src string, dim Dimension, count string, withItems bool) string {
+	d := dimensions[dim]
+	items := `0`
+	if withItems {
+		items = `sum(items)`
+	}
+	return `
+		SELECT key, parent, sum(n)::bigint, sum(items)::bigint,
+		       (sum(n) / sum(sum(n)) OVER ())::float8,
+		       jsonb_object_agg(currency, revenue)
+		FROM (
+			SELECT ` + d[0] + ` AS key, ` + d[1] + ` AS parent, COALESCE(currency, '') AS currency,
+			       sum(` + count + `) AS n, ` + items + ` AS items, COALESCE(sum(revenue), 0) AS revenue
+			FROM (` + src + `) s
+			WHERE at >= $1 AND at < $2
+			GROUP BY 1, 2, 3
+		) g
+		GROUP BY key, parent
+		ORDER BY 3 DESC, key, parent
+		LIMIT $3`
+}
+
+// Breakdown возвращает limit значений разреза с наибольшим числом заказов.
+// Разрезы - DimDeliveryService, DimRegion или DimCity.
+func (p *Postgres) Breakdown(ctx context.Context, f AnalyticsFilter, dim Dimension, limit int) ([]Breakdown, error) {
+	if dim != DimDeliveryService && dim != DimRegion && dim != DimCity {
+		return nil, fmt.Errorf("%w: неизвестный разрез заказов %q", ErrInvalid, dim)
+	}
+	src, from, to := f.source(liveOrders, rollupOrders)
+	rows, err := p.pool.Query(ctx, breakdownQuery(src, dim, "orders", true), from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка отчёта по %s: %w", dim, err)
+	}
+	out := []Breakdown{}
+	var b Breakdown
+	_, err = pgx.ForEachRow(rows, []any{&b.Key, &b.Region, &b.Orders, &b.Items, &b.Share, &b.Revenue}, func() error {
+		out = append(out, b)
+		b.Revenue = nil
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка отчёта по %s: %w", dim, err)
+	}
+	return out, nil
+}
+
+// TopItems возвращает limit брендов (DimBrand) или артикулов (DimNmID)
+// с наибольшим числом проданных товаров
+func (p *Postgres) TopItems(ctx context.Context, f AnalyticsFilter, dim Dimension, limit int) ([]TopItem, error) {
+	if dim != DimBrand && dim != DimNmID {
+		return nil, fmt.Errorf("%w: неизвестный разрез товаров %q", ErrInvalid, dim)
+	}
+	src, from, to := f.source(liveItems, rollupItems)
+	rows, err := p.pool.Query(ctx, breakdownQuery(src, dim, "quantity", false), from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка отчёта по %s: %w", dim, err)
+	}
+	out := []TopItem{}
+	var t TopItem
+	var parent string
+	var items int64
+	_, err = pgx.ForEachRow(rows, []any{&t.Key, &parent, &t.Quantity, &items, &t.Share, &t.Revenue}, func() error {
+		out = append(out, t)
+		t.Revenue = nil
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка отчёта по %s: %w", dim, err)
+	}
+	return out, nil
+}
+
+// RefreshAnalytics пересчитывает дневные сводки. Отчёты из сводок при этом
+// не блокируются.
+func (p *Postgres) RefreshAnalytics(ctx context.Context) error {
+	for _, view := range []string{"analytics_orders_daily", "analytics_items_daily"} {
+		if _, err := p.pool.Exec(ctx, `REFRESH MATERIALIZED VIEW CONCURRENTLY `+view); err != nil {
+			return fmt.Errorf("ошибка обновления сводки %s: %w", view, err)
+		}
+	}
+	return nil
+}
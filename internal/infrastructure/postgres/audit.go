@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"demo-service/internal/audit"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ключ advisory-блокировки, под которой дописывается цепочка аудита
+const auditLockKey = 0x61756469
+
+// AppendAudit дописывает события в журнал аудита одной транзакцией, продолжая
+// цепочку хешей. Блокировка нужна, чтобы параллельные экземпляры сервиса
+// не ветвили цепочку.
+func (p *Postgres) AppendAudit(ctx context.Context, events []audit.Event) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, auditLockKey); err != nil {
+		return fmt.Errorf("ошибка блокировки журнала аудита: %w", err)
+	}
+	var prev string
+	err = tx.QueryRow(ctx, `SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&prev)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("ошибка чтения журнала аудита: %w", err)
+	}
+
+	batch := &pgx.Batch{}
+	for i := range events {
+		e := &events[i]
+		e.Seal(prev)
+		prev = e.Hash
+		batch.Queue(`INSERT INTO audit_log (at, actor, action, order_uid, customer_id, fields, details, prev_hash, hash)
+			VALUES ($1,$2,$3,NULLIF($4,''),NULLIF($5,''),$6,NULLIF($7,''),$8,$9)`,
+			e.Time, e.Actor, e.Action, e.OrderUID, e.CustomerID, e.Fields, e.Details, e.PrevHash, e.Hash)
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("ошибка записи аудита: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка коммита транзакции: %w", err)
+	}
+	return nil
+}
+
+// QueryAudit читает журнал аудита по возрастанию id
+func (p *Postgres) QueryAudit(ctx context.Context, q audit.Query) ([]audit.Event, error) {
+	if q.Limit <= 0 {
+		return nil, fmt.Errorf("%w: limit должен быть положительным", ErrInvalid)
+	}
+	var from, to *time.Time
+	if !q.From.IsZero() {
+		from = &q.From
+	}
+	if !q.To.IsZero() {
+		to = &q.To
+	}
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, at, actor, action, COALESCE(order_uid, ''), COALESCE(customer_id, ''),
+		       fields, COALESCE(details, ''), prev_hash, hash
+		FROM audit_log
+		WHERE id > $1
+		  AND ($2 = '' OR order_uid = $2)
+		  AND ($3 = '' OR customer_id = $3)
+		  AND ($4 = '' OR actor = $4)
+		  AND ($5 = '' OR action = $5)
+		  AND ($6::timestamptz IS NULL OR at >= $6)
+		  AND ($7::timestamptz IS NULL OR at < $7)
+		ORDER BY id
+		LIMIT $8`, q.AfterID, q.OrderUID, q.CustomerID, q.Actor, q.Action, from, to, q.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения журнала аудита: %w", err)
+	}
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (audit.Event, error) {
+		var e audit.Event
+		err := row.Scan(&e.ID, &e.Time, &e.Actor, &e.Action, &e.OrderUID, &e.CustomerID,
+			&e.Fields, &e.Details, &e.PrevHash, &e.Hash)
+		return e, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения журнала аудита: %w", err)
+	}
+	return events, nil
+}
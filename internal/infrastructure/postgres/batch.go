@@ -0,0 +1,219 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"demo-service/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ConflictPolicy - что делать с заказом, который уже есть в бд
+type ConflictPolicy string
+
+const (
+	// оставить заказ в бд как есть
+	ConflictSkip ConflictPolicy = "skip"
+	// заменить данные заказа, статус и история сохраняются
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// отменить всю пачку с ErrConflict
+	ConflictFail ConflictPolicy = "fail"
+)
+
+// ParseConflictPolicy проверяет имя политики
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(s); p {
+	case ConflictSkip, ConflictOverwrite, ConflictFail:
+		return p, nil
+	}
+	return "", fmt.Errorf("%w: неизвестная политика конфликтов %q, ожидалось skip, overwrite или fail", ErrInvalid, s)
+}
+
+// BatchResult - итог записи пачки заказов
+type BatchResult struct {
+	Inserted int
+	Updated  int
+	Skipped  int
+	// заказы, которые нельзя записать, с причиной: удалённые при ConflictOverwrite
+	Rejected map[string]error
+}
+
+var (
+	orderColumns    = []string{"order_uid", "track_number", "entry", "locale", "internal_signature", "customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard", "updated_at", "status"}
+	deliveryColumns = []string{"order_uid", "name", "phone", "zip", "city", "address", "region", "email"}
+	paymentColumns  = []string{"order_uid", "transaction", "request_id", "currency", "provider", "amount", "payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee"}
+	itemColumns     = []string{"order_uid", "chrt_id", "track_number", "price", "rid", "name", "sale", "size", "total_price", "nm_id", "brand", "status"}
+	historyColumns  = []string{"order_uid", "to_status", "source", "changed_at"}
+)
+
+// SaveOrders записывает пачку заказов одной транзакцией: новые заказы и их
+// части - через COPY, существующие обновляются по политике policy. Статус нового
+// заказа берётся из данных (пустой - created) и открывает историю с источником
+// import, у существующего статус не меняется. order_uid в пачке не должны повторяться.
+// Кеш не обновляется.
+func (p *Postgres) SaveOrders(ctx context.Context, orders []*model.Order, policy ConflictPolicy) (BatchResult, error) {
+	res := BatchResult{Rejected: make(map[string]error)}
+	if len(orders) == 0 {
+		return res, nil
+	}
+	uids := make([]string, len(orders))
+	seen := make(map[string]bool, len(orders))
+	for i, o := range orders {
+		if o.OrderUID == "" {
+			return res, fmt.Errorf("%w: пустой order_uid", ErrInvalid)
+		}
+		if seen[o.OrderUID] {
+			return res, fmt.Errorf("%w: заказ %s повторяется в пачке", ErrInvalid, o.OrderUID)
+		}
+		if o.Status != "" && !o.Status.Valid() {
+			return res, fmt.Errorf("%w: заказ %s: %w: %q", ErrInvalid, o.OrderUID, model.ErrUnknownStatus, o.Status)
+		}
+		seen[o.OrderUID] = true
+		uids[i] = o.OrderUID
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return res, fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// существующие заказы блокируются до конца транзакции
+	rows, err := tx.Query(ctx, `SELECT order_uid, deleted_at IS NOT NULL FROM orders WHERE order_uid = ANY($1) FOR UPDATE`, uids)
+	if err != nil {
+		return res, fmt.Errorf("ошибка поиска существующих заказов: %w", err)
+	}
+	deleted := make(map[string]bool)
+	var uid string
+	var isDeleted bool
+	if _, err := pgx.ForEachRow(rows, []any{&uid, &isDeleted}, func() error {
+		deleted[uid] = isDeleted
+		return nil
+	}); err != nil {
+		return res, fmt.Errorf("ошибка поиска существующих заказов: %w", err)
+	}
+
+	var fresh, update []*model.Order
+	var conflicts []string
+	for _, o := range orders {
+		isDeleted, exists := deleted[o.OrderUID]
+		switch {
+		case !exists:
+			fresh = append(fresh, o)
+		case policy == ConflictFail:
+			conflicts = append(conflicts, o.OrderUID)
+		case policy == ConflictSkip:
+			res.Skipped++
+		case isDeleted:
+			// удалённый заказ не должен воскреснуть
+			res.Rejected[o.OrderUID] = fmt.Errorf("%w: заказ %s удалён", ErrConflict, o.OrderUID)
+		default:
+			update = append(update, o)
+		}
+	}
+	if len(conflicts) > 0 {
+		return res, fmt.Errorf("%w: заказы уже есть в бд: %s", ErrConflict, strings.Join(conflicts, ", "))
+	}
+	if err := maskErased(ctx, tx, slices.Concat(fresh, update)); err != nil {
+		return res, err
+	}
+
+	now := time.Now().UTC()
+	for _, o := range fresh {
+		o.UpdatedAt = now
+		if o.Status == "" {
+			o.Status = model.StatusCreated
+		}
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"orders"}, orderColumns, pgx.CopyFromSlice(len(fresh), func(i int) ([]any, error) {
+		o := fresh[i]
+		return []any{o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature, o.CustomerID,
+			o.DeliveryService, o.Shardkey, o.SmID, o.DateCreated, o.OofShard, o.UpdatedAt, string(o.Status)}, nil
+	}))
+	if err != nil {
+		return res, fmt.Errorf("ошибка добавления заказов: %w", classify(err))
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"order_status_history"}, historyColumns, pgx.CopyFromSlice(len(fresh), func(i int) ([]any, error) {
+		return []any{fresh[i].OrderUID, string(fresh[i].Status), SourceImport, now}, nil
+	}))
+	if err != nil {
+		return res, fmt.Errorf("ошибка записи истории статусов: %w", classify(err))
+	}
+
+	if len(update) > 0 {
+		if err := updateOrders(ctx, tx, update, now); err != nil {
+			return res, err
+		}
+	}
+
+	// доставка, платёж и товары пишутся заново для всех записываемых заказов
+	if err := copyOrderParts(ctx, tx, slices.Concat(fresh, update)); err != nil {
+		return res, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return res, fmt.Errorf("ошибка коммита транзакции: %w", err)
+	}
+	res.Inserted, res.Updated = len(fresh), len(update)
+	return res, nil
+}
+
+// updateOrders обновляет заказы одним обменом с сервером и удаляет их старые части
+func updateOrders(ctx context.Context, tx pgx.Tx, orders []*model.Order, now time.Time) error {
+	uids := make([]string, len(orders))
+	batch := &pgx.Batch{}
+	for i, o := range orders {
+		uids[i] = o.OrderUID
+		o.UpdatedAt = now
+		batch.Queue(`UPDATE orders SET track_number=$2, entry=$3, locale=$4, internal_signature=$5, customer_id=$6,
+			delivery_service=$7, shardkey=$8, sm_id=$9, date_created=$10, oof_shard=$11, updated_at=$12
+			WHERE order_uid=$1 RETURNING status`,
+			o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature, o.CustomerID,
+			o.DeliveryService, o.Shardkey, o.SmID, o.DateCreated, o.OofShard, o.UpdatedAt).QueryRow(func(row pgx.Row) error {
+			return row.Scan(&o.Status)
+		})
+	}
+	for _, table := range []string{"deliveries", "payments", "items"} {
+		batch.Queue(`DELETE FROM `+table+` WHERE order_uid = ANY($1)`, uids)
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("ошибка обновления заказов: %w", classify(err))
+	}
+	return nil
+}
+
+// copyOrderParts пишет доставку, платёж и товары заказов через COPY
+func copyOrderParts(ctx context.Context, tx pgx.Tx, orders []*model.Order) error {
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{"deliveries"}, deliveryColumns, pgx.CopyFromSlice(len(orders), func(i int) ([]any, error) {
+		o := orders[i]
+		d := o.Delivery
+		return []any{o.OrderUID, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email}, nil
+	}))
+	if err != nil {
+		return fmt.Errorf("ошибка добавления доставки: %w", classify(err))
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"payments"}, paymentColumns, pgx.CopyFromSlice(len(orders), func(i int) ([]any, error) {
+		o := orders[i]
+		pm := o.Payment
+		return []any{o.OrderUID, pm.Transaction, pm.RequestID, pm.Currency, pm.Provider, pm.Amount,
+			pm.PaymentDt, pm.Bank, pm.DeliveryCost, pm.GoodsTotal, pm.CustomFee}, nil
+	}))
+	if err != nil {
+		return fmt.Errorf("ошибка добавления платежа: %w", classify(err))
+	}
+
+	var items [][]any
+	for _, o := range orders {
+		for _, it := range o.Items {
+			items = append(items, []any{o.OrderUID, it.ChrtID, it.TrackNumber, it.Price, it.Rid, it.Name,
+				it.Sale, it.Size, it.TotalPrice, it.NmID, it.Brand, it.Status})
+		}
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"items"}, itemColumns, pgx.CopyFromRows(items)); err != nil {
+		return fmt.Errorf("ошибка добавления элементов: %w", classify(err))
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Ошибки хранилища, по которым вызывающий код различает причины отказа
+var (
+	ErrNotFound = errors.New("заказ не найден")
+	ErrConflict = errors.New("конфликт данных")
+	ErrInvalid  = errors.New("некорректные данные")
+)
+
+// коды ошибок postgres, см. https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	codeUniqueViolation     = "23505"
+	codeForeignKeyViolation = "23503"
+	codeNotNullViolation    = "23502"
+	codeCheckViolation      = "23514"
+	codeStringTooLong       = "22001"
+	codeNumericOutOfRange   = "22003"
+	codeInvalidText         = "22P02"
+)
+
+// classify оборачивает ошибку postgres в подходящую ошибку хранилища
+func classify(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case codeUniqueViolation:
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	case codeForeignKeyViolation, codeNotNullViolation, codeCheckViolation,
+		codeStringTooLong, codeNumericOutOfRange, codeInvalidText:
+		return fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	return err
+}
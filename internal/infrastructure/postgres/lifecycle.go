@@ -0,0 +1,187 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"demo-service/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DeleteOrder помечает заказ удалённым. Строки остаются в бд до задания
+// хранения, но заказ больше не читается и не перезаписывается из Kafka.
+func (p *Postgres) DeleteOrder(ctx context.Context, orderUID string) error {
+	tag, err := p.pool.Exec(ctx, `UPDATE orders SET deleted_at=$2, updated_at=$2 WHERE order_uid=$1 AND deleted_at IS NULL`,
+		orderUID, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("ошибка удаления заказа: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// EraseCustomer стирает персональные данные доставки во всех заказах
+// покупателя, включая удалённые и архивные, и запоминает покупателя, чтобы
+// повторная запись его заказов не вернула данные. Возвращает затронутые
+// заказы основных таблиц.
+func (p *Postgres) EraseCustomer(ctx context.Context, customerID string) ([]string, error) {
+	if customerID == "" {
+		return nil, fmt.Errorf("%w: пустой customer_id", ErrInvalid)
+	}
+	erased, err := json.Marshal(model.Delivery{})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сериализации доставки: %w", err)
+	}
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now().UTC()
+	if _, err := tx.Exec(ctx, `INSERT INTO erased_customers (customer_id, erased_at) VALUES ($1, $2)
+		ON CONFLICT (customer_id) DO UPDATE SET erased_at=EXCLUDED.erased_at`, customerID, now); err != nil {
+		return nil, fmt.Errorf("ошибка записи стёртого покупателя: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `UPDATE deliveries d
+		SET name='', phone='', zip='', city='', address='', region='', email=''
+		FROM orders o
+		WHERE d.order_uid=o.order_uid AND o.customer_id=$1
+		RETURNING d.order_uid`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка стирания данных доставки: %w", err)
+	}
+	uids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("ошибка стирания данных доставки: %w", err)
+	}
+	// updated_at меняется, чтобы клиенты не получили старую версию по ETag
+	if _, err := tx.Exec(ctx, `UPDATE orders SET updated_at=$2 WHERE order_uid = ANY($1)`, uids, now); err != nil {
+		return nil, fmt.Errorf("ошибка обновления заказов: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE orders_archive SET data = jsonb_set(data, '{delivery}', $2::jsonb)
+		WHERE data->>'customer_id' = $1`, customerID, erased); err != nil {
+		return nil, fmt.Errorf("ошибка стирания данных доставки в архиве: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("ошибка коммита транзакции: %w", err)
+	}
+	return uids, nil
+}
+
+// maskErased стирает данные доставки у заказов покупателей из erased_customers
+func maskErased(ctx context.Context, tx pgx.Tx, orders []*model.Order) error {
+	ids := make([]string, 0, len(orders))
+	for _, o := range orders {
+		if o.CustomerID != "" {
+			ids = append(ids, o.CustomerID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	rows, err := tx.Query(ctx, `SELECT customer_id FROM erased_customers WHERE customer_id = ANY($1)`, ids)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения стёртых покупателей: %w", err)
+	}
+	erased, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("ошибка чтения стёртых покупателей: %w", err)
+	}
+	for _, o := range orders {
+		if slices.Contains(erased, o.CustomerID) {
+			o.Delivery = model.Delivery{}
+		}
+	}
+	return nil
+}
+
+// ExpireOrders удаляет до limit заказов, созданных или помеченных удалёнными
+// раньше before, вместе с доставкой, платежом и товарами (ON DELETE CASCADE). При archive заказ
+// перед удалением целиком сохраняется в orders_archive. Возвращает удалённые заказы.
+func (p *Postgres) ExpireOrders(ctx context.Context, before time.Time, limit int, archive bool) ([]string, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: limit должен быть положительным", ErrInvalid)
+	}
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `SELECT order_uid FROM orders WHERE date_created < $1 OR deleted_at < $1
+		ORDER BY date_created LIMIT $2 FOR UPDATE SKIP LOCKED`, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выборки устаревших заказов: %w", err)
+	}
+	uids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выборки устаревших заказов: %w", err)
+	}
+	if len(uids) == 0 {
+		return nil, nil
+	}
+
+	if archive {
+		if err := p.archiveOrders(ctx, tx, uids); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM orders WHERE order_uid = ANY($1)`, uids); err != nil {
+		return nil, fmt.Errorf("ошибка удаления устаревших заказов: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("ошибка коммита транзакции: %w", err)
+	}
+	return uids, nil
+}
+
+func (p *Postgres) archiveOrders(ctx context.Context, tx pgx.Tx, uids []string) error {
+	// удалённые заказы тоже архивируются, поэтому без фильтра по deleted_at
+	rows, err := tx.Query(ctx, orderSelect+` WHERE o.order_uid = ANY($1)`, uids)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения заказов для архива: %w", err)
+	}
+	orders, err := p.collectOrders(ctx, rows)
+	if err != nil {
+		return err
+	}
+
+	deleted := make(map[string]*time.Time)
+	rows, err = tx.Query(ctx, `SELECT order_uid, deleted_at FROM orders WHERE order_uid = ANY($1)`, uids)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения заказов для архива: %w", err)
+	}
+	for rows.Next() {
+		var uid string
+		var at *time.Time
+		if err := rows.Scan(&uid, &at); err != nil {
+			rows.Close()
+			return fmt.Errorf("ошибка чтения заказов для архива: %w", err)
+		}
+		deleted[uid] = at
+	}
+	rows.Close()
+
+	batch := &pgx.Batch{}
+	for _, o := range orders {
+		data, err := json.Marshal(o)
+		if err != nil {
+			return fmt.Errorf("ошибка сериализации заказа %s: %w", o.OrderUID, err)
+		}
+		batch.Queue(`INSERT INTO orders_archive (order_uid, data, deleted_at) VALUES ($1,$2,$3)
+			ON CONFLICT (order_uid) DO UPDATE SET data=EXCLUDED.data, deleted_at=EXCLUDED.deleted_at, archived_at=now()`,
+			o.OrderUID, data, deleted[o.OrderUID])
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("ошибка записи архива: %w", err)
+	}
+	return nil
+}
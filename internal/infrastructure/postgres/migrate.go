@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Migration - изменение схемы бд, применяется один раз и по порядку версий
+type Migration struct {
+	Version string
+	SQL     string
+}
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version VARCHAR(100) PRIMARY KEY,
+    applied_at TIMESTAMP WITH TIME ZONE NOT NULL
+)`
+
+// PendingMigrations возвращает ещё не применённые миграции
+func (p *Postgres) PendingMigrations(ctx context.Context, migrations []Migration) ([]Migration, error) {
+	if _, err := p.pool.Exec(ctx, createMigrationsTable); err != nil {
+		return nil, fmt.Errorf("ошибка создания schema_migrations: %w", err)
+	}
+	if err := p.baseline(ctx, migrations); err != nil {
+		return nil, err
+	}
+	rows, err := p.pool.Query(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения schema_migrations: %w", err)
+	}
+	versions, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения schema_migrations: %w", err)
+	}
+	applied := make(map[string]bool, len(versions))
+	for _, v := range versions {
+		applied[v] = true
+	}
+	var pending []Migration
+	for _, m := range migrations {
+		if !applied[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// baseline отмечает первую миграцию применённой, если схема создана
+// без учёта миграций, например psql -f db/init.sql. Остальные миграции
+// применяются как обычно.
+func (p *Postgres) baseline(ctx context.Context, migrations []Migration) error {
+	if len(migrations) == 0 {
+		return nil
+	}
+	_, err := p.pool.Exec(ctx, `INSERT INTO schema_migrations (version, applied_at)
+		SELECT $1, $2 WHERE to_regclass('orders') IS NOT NULL AND NOT EXISTS (SELECT 1 FROM schema_migrations)`,
+		migrations[0].Version, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("ошибка записи schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// Migrate применяет неприменённые миграции, каждую в своей транзакции,
+// и возвращает их версии. При ошибке уже применённые остаются.
+func (p *Postgres) Migrate(ctx context.Context, migrations []Migration) ([]string, error) {
+	pending, err := p.PendingMigrations(ctx, migrations)
+	if err != nil {
+		return nil, err
+	}
+	var applied []string
+	for _, m := range pending {
+		tx, err := p.pool.Begin(ctx)
+		if err != nil {
+			return applied, fmt.Errorf("не удалось начать транзакцию: %w", err)
+		}
+		if _, err := tx.Exec(ctx, m.SQL); err != nil {
+			tx.Rollback(ctx)
+			return applied, fmt.Errorf("миграция %s: %w", m.Version, err)
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES ($1, $2)`,
+			m.Version, time.Now().UTC()); err != nil {
+			tx.Rollback(ctx)
+			return applied, fmt.Errorf("миграция %s: %w", m.Version, err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return applied, fmt.Errorf("миграция %s: ошибка коммита: %w", m.Version, err)
+		}
+		applied = append(applied, m.Version)
+	}
+	return applied, nil
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"demo-service/internal/infrastructure/cache"
 	"demo-service/internal/model"
+	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -30,24 +32,53 @@ func New(ctx context.Context, dsn string) (*Postgres, error) {
 
 // сохранение заказ в бд с использованием транзакции
 func (p *Postgres) SaveOrder(o *model.Order, c *cache.Cache) error {
+	if o.OrderUID == "" {
+		return fmt.Errorf("%w: пустой order_uid", ErrInvalid)
+	}
+
 	ctx := context.Background()
+	o.UpdatedAt = time.Now().UTC()
 	tx, err := p.pool.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("не удалось начать транзакцию: %w", err)
 	}
 
-	// вставка или обновления заказа
-	_, err = tx.Exec(ctx, `INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+	// данные стёртого покупателя не должны вернуться с повторной записью
+	if err := maskErased(ctx, tx, []*model.Order{o}); err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	// вставка или обновления заказа, статус при повторном сохранении не меняется
+	var inserted bool
+	err = tx.QueryRow(ctx, `INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, updated_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
 		ON CONFLICT (order_uid) DO UPDATE SET track_number=EXCLUDED.track_number, entry=EXCLUDED.entry, locale=EXCLUDED.locale,
 		internal_signature=EXCLUDED.internal_signature, customer_id=EXCLUDED.customer_id,
 		delivery_service=EXCLUDED.delivery_service, shardkey=EXCLUDED.shardkey, sm_id=EXCLUDED.sm_id,
-		date_created=EXCLUDED.date_created, oof_shard=EXCLUDED.oof_shard`,
+		date_created=EXCLUDED.date_created, oof_shard=EXCLUDED.oof_shard, updated_at=EXCLUDED.updated_at
+		WHERE orders.deleted_at IS NULL
+		RETURNING status, xmax = 0`,
 		o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature, o.CustomerID,
-		o.DeliveryService, o.Shardkey, o.SmID, o.DateCreated, o.OofShard)
+		o.DeliveryService, o.Shardkey, o.SmID, o.DateCreated, o.OofShard, o.UpdatedAt).Scan(&o.Status, &inserted)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// строка есть, но не обновилась: заказ удалён и не должен воскреснуть
+		tx.Rollback(ctx)
+		return fmt.Errorf("%w: заказ %s удалён", ErrConflict, o.OrderUID)
+	}
 	if err != nil {
 		tx.Rollback(ctx)
-		return fmt.Errorf("ошибка добавления заказа: %w", err)
+		return fmt.Errorf("ошибка добавления заказа: %w", classify(err))
+	}
+
+	// новый заказ открывает историю статусов
+	if inserted {
+		_, err = tx.Exec(ctx, `INSERT INTO order_status_history (order_uid, to_status, source, changed_at) VALUES ($1,$2,$3,$4)`,
+			o.OrderUID, o.Status, SourceIngest, o.UpdatedAt)
+		if err != nil {
+			tx.Rollback(ctx)
+			return fmt.Errorf("ошибка записи истории статусов: %w", classify(err))
+		}
 	}
 
 	// вставка и обвноление данных доставки
@@ -59,7 +90,7 @@ func (p *Postgres) SaveOrder(o *model.Order, c *cache.Cache) error {
 		o.Delivery.Address, o.Delivery.Region, o.Delivery.Email)
 	if err != nil {
 		tx.Rollback(ctx)
-		return fmt.Errorf("ошибка добавления доставки: %w", err)
+		return fmt.Errorf("ошибка добавления доставки: %w", classify(err))
 	}
 
 	// вставка или обновление данных платежа
@@ -73,7 +104,7 @@ func (p *Postgres) SaveOrder(o *model.Order, c *cache.Cache) error {
 		o.Payment.GoodsTotal, o.Payment.CustomFee)
 	if err != nil {
 		tx.Rollback(ctx)
-		return fmt.Errorf("ошибка добавления платежа: %w", err)
+		return fmt.Errorf("ошибка добавления платежа: %w", classify(err))
 	}
 
 	// удаление старых элементов заказа
@@ -91,7 +122,7 @@ func (p *Postgres) SaveOrder(o *model.Order, c *cache.Cache) error {
 			it.Sale, it.Size, it.TotalPrice, it.NmID, it.Brand, it.Status)
 		if err != nil {
 			tx.Rollback(ctx)
-			return fmt.Errorf("ошибка добавления элемента: %w", err)
+			return fmt.Errorf("ошибка добавления элемента: %w", classify(err))
 		}
 	}
 
@@ -107,13 +138,14 @@ func (p *Postgres) SaveOrder(o *model.Order, c *cache.Cache) error {
 func (p *Postgres) LoadCache(ctx context.Context, c *cache.Cache) error {
 	rows, err := p.pool.Query(ctx, `
 		SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, o.customer_id,
-		       o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
+		       o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.updated_at, o.status,
 		       d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
 		       p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt,
 		       p.bank, p.delivery_cost, p.goods_total, p.custom_fee
 		FROM orders o
 		LEFT JOIN deliveries d ON o.order_uid=d.order_uid
-		LEFT JOIN payments p ON o.order_uid=p.order_uid`)
+		LEFT JOIN payments p ON o.order_uid=p.order_uid
+		WHERE o.deleted_at IS NULL`)
 	if err != nil {
 		return fmt.Errorf("ошибка загрузки кеша: %w", err)
 	}
@@ -125,7 +157,7 @@ func (p *Postgres) LoadCache(ctx context.Context, c *cache.Cache) error {
 		err := rows.Scan(
 			&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature,
 			&o.CustomerID, &o.DeliveryService, &o.Shardkey, &o.SmID, &o.DateCreated,
-			&o.OofShard, &o.Delivery.Name, &o.Delivery.Phone, &o.Delivery.Zip,
+			&o.OofShard, &o.UpdatedAt, &o.Status, &o.Delivery.Name, &o.Delivery.Phone, &o.Delivery.Zip,
 			&o.Delivery.City, &o.Delivery.Address, &o.Delivery.Region, &o.Delivery.Email,
 			&o.Payment.Transaction, &o.Payment.RequestID, &o.Payment.Currency,
 			&o.Payment.Provider, &o.Payment.Amount, &o.Payment.PaymentDt, &o.Payment.Bank,
@@ -160,11 +192,11 @@ func (p *Postgres) LoadCache(ctx context.Context, c *cache.Cache) error {
 func (p *Postgres) GetOrder(ctx context.Context, orderUID string) (*model.Order, error) {
 	o := &model.Order{}
 
-	row := p.pool.QueryRow(ctx, "SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard FROM orders WHERE order_uid=$1", orderUID)
-	err := row.Scan(&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature, &o.CustomerID, &o.DeliveryService, &o.Shardkey, &o.SmID, &o.DateCreated, &o.OofShard)
+	row := p.pool.QueryRow(ctx, "SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, updated_at, status FROM orders WHERE order_uid=$1 AND deleted_at IS NULL", orderUID)
+	err := row.Scan(&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature, &o.CustomerID, &o.DeliveryService, &o.Shardkey, &o.SmID, &o.DateCreated, &o.OofShard, &o.UpdatedAt, &o.Status)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("заказ не найден")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
 		}
 		return nil, fmt.Errorf("ошибка чтения заказа: %w", err)
 	}
@@ -154,3 +154,76 @@ func TestGetOrder_NotFound(t *testing.T) {
 		t.Errorf("ожидался nil заказ, получен %+v", got)
 	}
 }
+
+func TestEraseCustomer_ArchiveAndRewrite(t *testing.T) {
+	p, cache, ctx, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	newOrder := func(uid string) *model.Order {
+		return &model.Order{
+			OrderUID:    uid,
+			CustomerID:  "cust-erase",
+			DateCreated: time.Now(),
+			Delivery:    model.Delivery{Name: "John Doe", Phone: "+123456789", City: "TestCity", Email: "john@example.com"},
+			Payment:     model.Payment{Transaction: uid, Currency: "USD"},
+		}
+	}
+	live, archived := newOrder("test-erase-live"), newOrder("test-erase-archived")
+	for _, o := range []*model.Order{live, archived} {
+		if err := p.SaveOrder(o, cache); err != nil {
+			t.Fatalf("Ошибка SaveOrder: %v", err)
+		}
+	}
+	if _, err := p.pool.Exec(ctx, `UPDATE orders SET date_created=$2 WHERE order_uid=$1`,
+		archived.OrderUID, time.Now().Add(-48*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ExpireOrders(ctx, time.Now().Add(-24*time.Hour), 1000, true); err != nil {
+		t.Fatalf("Ошибка ExpireOrders: %v", err)
+	}
+
+	if _, err := p.EraseCustomer(ctx, "cust-erase"); err != nil {
+		t.Fatalf("Ошибка EraseCustomer: %v", err)
+	}
+	var name string
+	err := p.pool.QueryRow(ctx, `SELECT data->'delivery'->>'name' FROM orders_archive WHERE order_uid=$1`,
+		archived.OrderUID).Scan(&name)
+	if err != nil || name != "" {
+		t.Errorf("данные доставки в архиве не стёрты: %q, %v", name, err)
+	}
+
+	// повторная запись заказа, например при переобработке, не возвращает данные
+	if err := p.SaveOrder(newOrder(live.OrderUID), cache); err != nil {
+		t.Fatalf("Ошибка SaveOrder: %v", err)
+	}
+	got, err := p.GetOrder(ctx, live.OrderUID)
+	if err != nil {
+		t.Fatalf("Ошибка GetOrder: %v", err)
+	}
+	if got.Delivery != (model.Delivery{}) {
+		t.Errorf("данные доставки вернулись после повторной записи: %+v", got.Delivery)
+	}
+}
+
+func TestAnalyticsFilter_Period(t *testing.T) {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	f := AnalyticsFilter{From: day.Add(10 * time.Hour), To: day.Add(48*time.Hour + time.Minute)}
+
+	from, to := f.Period()
+	if !from.Equal(f.From) || !to.Equal(f.To) {
+		t.Errorf("по заказам период не меняется, получено %v - %v", from, to)
+	}
+
+	// по сводкам период расширяется до целых суток с обеих сторон
+	f.Rollup = true
+	from, to = f.Period()
+	if !from.Equal(day) || !to.Equal(day.Add(72*time.Hour)) {
+		t.Errorf("по сводкам получено %v - %v", from, to)
+	}
+
+	// полночь в конце уже граница суток
+	f.To = day.Add(48 * time.Hour)
+	if _, to = f.Period(); !to.Equal(f.To) {
+		t.Errorf("конец в полночь сдвинут: %v", to)
+	}
+}
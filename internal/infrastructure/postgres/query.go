@@ -0,0 +1,136 @@
+package postgres
+
+import (
+	"context"
+	"demo-service/internal/model"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// заказ вместе с доставкой и платежом одной строкой
+const orderSelect = `
+	SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, o.customer_id,
+	       o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.updated_at, o.status,
+	       COALESCE(d.name, ''), COALESCE(d.phone, ''), COALESCE(d.zip, ''), COALESCE(d.city, ''),
+	       COALESCE(d.address, ''), COALESCE(d.region, ''), COALESCE(d.email, ''),
+	       COALESCE(p.transaction, ''), COALESCE(p.request_id, ''), COALESCE(p.currency, ''),
+	       COALESCE(p.provider, ''), COALESCE(p.amount, 0), COALESCE(p.payment_dt, 0),
+	       COALESCE(p.bank, ''), COALESCE(p.delivery_cost, 0), COALESCE(p.goods_total, 0), COALESCE(p.custom_fee, 0)
+	FROM orders o
+	LEFT JOIN deliveries d ON o.order_uid=d.order_uid
+	LEFT JOIN payments p ON o.order_uid=p.order_uid`
+
+// ListFilter - условия выборки списка заказов
+type ListFilter struct {
+	CustomerID      string
+	DeliveryService string
+	Status          model.Status
+	// созданные в [From, To), нулевое время не ограничивает
+	From, To time.Time
+	// курсор: заказы с order_uid строго больше After
+	After string
+	Limit int
+}
+
+// ListOrders возвращает страницу заказов, упорядоченных по order_uid
+func (p *Postgres) ListOrders(ctx context.Context, f ListFilter) ([]*model.Order, error) {
+	if f.Limit <= 0 {
+		return nil, fmt.Errorf("%w: limit должен быть положительным", ErrInvalid)
+	}
+	var from, to *time.Time
+	if !f.From.IsZero() {
+		from = &f.From
+	}
+	if !f.To.IsZero() {
+		to = &f.To
+	}
+	rows, err := p.pool.Query(ctx, orderSelect+`
+		WHERE o.deleted_at IS NULL
+		  AND ($1 = '' OR o.order_uid > $1)
+		  AND ($2 = '' OR o.customer_id = $2)
+		  AND ($3 = '' OR o.delivery_service = $3)
+		  AND ($5 = '' OR o.status = $5)
+		  AND ($6::timestamptz IS NULL OR o.date_created >= $6)
+		  AND ($7::timestamptz IS NULL OR o.date_created < $7)
+		ORDER BY o.order_uid
+		LIMIT $4`, f.After, f.CustomerID, f.DeliveryService, f.Limit, string(f.Status), from, to)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения списка заказов: %w", err)
+	}
+	return p.collectOrders(ctx, rows)
+}
+
+// GetOrders загружает заказы по списку uid одним набором запросов.
+// Отсутствующие заказы просто не попадают в результат.
+func (p *Postgres) GetOrders(ctx context.Context, orderUIDs []string) ([]*model.Order, error) {
+	if len(orderUIDs) == 0 {
+		return nil, nil
+	}
+	rows, err := p.pool.Query(ctx, orderSelect+`
+		WHERE o.order_uid = ANY($1) AND o.deleted_at IS NULL
+		ORDER BY o.order_uid`, orderUIDs)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения заказов: %w", err)
+	}
+	return p.collectOrders(ctx, rows)
+}
+
+// collectOrders сканирует строки orderSelect и дозагружает элементы заказов
+func (p *Postgres) collectOrders(ctx context.Context, rows pgx.Rows) ([]*model.Order, error) {
+	var orders []*model.Order
+	byUID := make(map[string]*model.Order)
+	for rows.Next() {
+		o := &model.Order{}
+		if err := scanOrder(rows, o); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("ошибка чтения заказа: %w", err)
+		}
+		orders = append(orders, o)
+		byUID[o.OrderUID] = o
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения заказов: %w", err)
+	}
+	if len(orders) == 0 {
+		return orders, nil
+	}
+
+	uids := make([]string, 0, len(orders))
+	for _, o := range orders {
+		uids = append(uids, o.OrderUID)
+	}
+	itemRows, err := p.pool.Query(ctx, `SELECT order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
+		FROM items WHERE order_uid = ANY($1) ORDER BY id`, uids)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения элементов заказов: %w", err)
+	}
+	defer itemRows.Close()
+	for itemRows.Next() {
+		var uid string
+		var it model.Item
+		if err := itemRows.Scan(&uid, &it.ChrtID, &it.TrackNumber, &it.Price, &it.Rid, &it.Name, &it.Sale, &it.Size, &it.TotalPrice, &it.NmID, &it.Brand, &it.Status); err != nil {
+			return nil, fmt.Errorf("ошибка чтения элемента заказа: %w", err)
+		}
+		if o, ok := byUID[uid]; ok {
+			o.Items = append(o.Items, it)
+		}
+	}
+	if err := itemRows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения элементов заказов: %w", err)
+	}
+	return orders, nil
+}
+
+func scanOrder(row pgx.Row, o *model.Order) error {
+	return row.Scan(
+		&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature,
+		&o.CustomerID, &o.DeliveryService, &o.Shardkey, &o.SmID, &o.DateCreated,
+		&o.OofShard, &o.UpdatedAt, &o.Status, &o.Delivery.Name, &o.Delivery.Phone, &o.Delivery.Zip,
+		&o.Delivery.City, &o.Delivery.Address, &o.Delivery.Region, &o.Delivery.Email,
+		&o.Payment.Transaction, &o.Payment.RequestID, &o.Payment.Currency,
+		&o.Payment.Provider, &o.Payment.Amount, &o.Payment.PaymentDt, &o.Payment.Bank,
+		&o.Payment.DeliveryCost, &o.Payment.GoodsTotal, &o.Payment.CustomFee)
+}
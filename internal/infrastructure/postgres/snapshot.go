@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"demo-service/internal/infrastructure/cache"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// запас на транзакции, начатые до снимка и закоммиченные после него,
+// и на расхождение часов между экземплярами сервиса
+const catchUpSkew = time.Minute
+
+// CatchUpCache догружает в кеш, восстановленный из снимка, изменения с момента
+// since: изменённые заказы заменяются, удалённые и стёртые заданием хранения
+// убираются. Возвращает число заменённых и убранных заказов.
+func (p *Postgres) CatchUpCache(ctx context.Context, c *cache.Cache, since time.Time) (updated, removed int, err error) {
+	since = since.Add(-catchUpSkew)
+
+	rows, err := p.pool.Query(ctx, orderSelect+`
+		WHERE o.updated_at >= $1 AND o.deleted_at IS NULL`, since)
+	if err != nil {
+		return 0, 0, fmt.Errorf("ошибка чтения изменённых заказов: %w", err)
+	}
+	orders, err := p.collectOrders(ctx, rows)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, o := range orders {
+		c.Replace(o)
+	}
+
+	// заказы, удалённые после снимка, как и удалённые заданием хранения,
+	// отсутствуют в списке живых uid
+	rows, err = p.pool.Query(ctx, `SELECT order_uid FROM orders WHERE deleted_at IS NULL`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("ошибка чтения списка заказов: %w", err)
+	}
+	uids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, 0, fmt.Errorf("ошибка чтения списка заказов: %w", err)
+	}
+	keep := make(map[string]bool, len(uids))
+	for _, uid := range uids {
+		keep[uid] = true
+	}
+	return len(orders), c.Retain(keep), nil
+}
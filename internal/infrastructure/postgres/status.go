@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"demo-service/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Источники изменений статуса для истории
+const (
+	SourceIngest = "ingest"
+	SourceKafka  = "kafka"
+	SourceAPI    = "api"
+	SourceImport = "import"
+)
+
+// UpdateStatus переводит заказ в статус to и пишет переход в историю.
+// Недопустимый переход - ErrConflict, неизвестный статус - ErrInvalid.
+// Повторная установка текущего статуса ничего не меняет, так повторно
+// доставленное сообщение не считается ошибкой. Возвращает заказ после изменения.
+func (p *Postgres) UpdateStatus(ctx context.Context, orderUID string, to model.Status, reason, source string) (*model.Order, error) {
+	if !to.Valid() {
+		return nil, fmt.Errorf("%w: %w: %q", ErrInvalid, model.ErrUnknownStatus, to)
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// блокировка строки, чтобы параллельные изменения проверялись по очереди
+	var from model.Status
+	err = tx.QueryRow(ctx, `SELECT status FROM orders WHERE order_uid=$1 AND deleted_at IS NULL FOR UPDATE`, orderUID).Scan(&from)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения статуса: %w", err)
+	}
+	if from == to {
+		tx.Rollback(ctx)
+		return p.GetOrder(ctx, orderUID)
+	}
+	if err := model.CheckTransition(from, to); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConflict, err)
+	}
+
+	now := time.Now().UTC()
+	if _, err := tx.Exec(ctx, `UPDATE orders SET status=$2, updated_at=$3 WHERE order_uid=$1`, orderUID, to, now); err != nil {
+		return nil, fmt.Errorf("ошибка смены статуса: %w", classify(err))
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO order_status_history (order_uid, from_status, to_status, reason, source, changed_at)
+		VALUES ($1,$2,$3,$4,$5,$6)`, orderUID, from, to, reason, source, now)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка записи истории статусов: %w", classify(err))
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("ошибка коммита транзакции: %w", err)
+	}
+
+	return p.GetOrder(ctx, orderUID)
+}
+
+// StatusHistory возвращает переходы статусов заказа от старых к новым
+func (p *Postgres) StatusHistory(ctx context.Context, orderUID string) ([]model.StatusChange, error) {
+	rows, err := p.pool.Query(ctx, `SELECT COALESCE(from_status, ''), to_status, COALESCE(reason, ''), source, changed_at
+		FROM order_status_history WHERE order_uid=$1 ORDER BY id`, orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения истории статусов: %w", err)
+	}
+	history, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.StatusChange, error) {
+		var c model.StatusChange
+		err := row.Scan(&c.From, &c.To, &c.Reason, &c.Source, &c.ChangedAt)
+		return c, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения истории статусов: %w", err)
+	}
+	if len(history) > 0 {
+		return history, nil
+	}
+
+	// пустая история - либо заказа нет, либо он сохранён до появления истории
+	var exists bool
+	if err := p.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM orders WHERE order_uid=$1 AND deleted_at IS NULL)`, orderUID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("ошибка чтения заказа: %w", err)
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return history, nil
+}
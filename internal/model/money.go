@@ -0,0 +1,177 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrUnknownCurrency  = errors.New("неизвестная валюта")
+	ErrCurrencyMismatch = errors.New("суммы в разных валютах")
+)
+
+// Currency - код валюты ISO 4217
+type Currency string
+
+// число знаков дробной части действующих валют ISO 4217 (без драгметаллов
+// и расчётных единиц, у которых знаков нет); остальные коды не принимаются
+var exponents = map[Currency]int{
+	"AED": 2, "AFN": 2, "ALL": 2, "AMD": 2, "ANG": 2, "AOA": 2, "ARS": 2, "AUD": 2, "AWG": 2, "AZN": 2,
+	"BAM": 2, "BBD": 2, "BDT": 2, "BGN": 2, "BMD": 2, "BND": 2, "BOB": 2, "BOV": 2, "BRL": 2, "BSD": 2,
+	"BTN": 2, "BWP": 2, "BYN": 2, "BZD": 2, "CAD": 2, "CDF": 2, "CHE": 2, "CHF": 2, "CHW": 2, "CNY": 2,
+	"COP": 2, "COU": 2, "CRC": 2, "CUC": 2, "CUP": 2, "CVE": 2, "CZK": 2, "DKK": 2, "DOP": 2, "DZD": 2,
+	"EGP": 2, "ERN": 2, "ETB": 2, "EUR": 2, "FJD": 2, "FKP": 2, "GBP": 2, "GEL": 2, "GHS": 2, "GIP": 2,
+	"GMD": 2, "GTQ": 2, "GYD": 2, "HKD": 2, "HNL": 2, "HTG": 2, "HUF": 2, "IDR": 2, "ILS": 2, "INR": 2,
+	"IRR": 2, "JMD": 2, "KES": 2, "KGS": 2, "KHR": 2, "KPW": 2, "KYD": 2, "KZT": 2, "LAK": 2, "LBP": 2,
+	"LKR": 2, "LRD": 2, "LSL": 2, "MAD": 2, "MDL": 2, "MGA": 2, "MKD": 2, "MMK": 2, "MNT": 2, "MOP": 2,
+	"MRU": 2, "MUR": 2, "MVR": 2, "MWK": 2, "MXN": 2, "MXV": 2, "MYR": 2, "MZN": 2, "NAD": 2, "NGN": 2,
+	"NIO": 2, "NOK": 2, "NPR": 2, "NZD": 2, "PAB": 2, "PEN": 2, "PGK": 2, "PHP": 2, "PKR": 2, "PLN": 2,
+	"QAR": 2, "RON": 2, "RSD": 2, "RUB": 2, "SAR": 2, "SBD": 2, "SCR": 2, "SDG": 2, "SEK": 2, "SGD": 2,
+	"SHP": 2, "SLE": 2, "SLL": 2, "SOS": 2, "SRD": 2, "SSP": 2, "STN": 2, "SVC": 2, "SYP": 2, "SZL": 2,
+	"THB": 2, "TJS": 2, "TMT": 2, "TOP": 2, "TRY": 2, "TTD": 2, "TWD": 2, "TZS": 2, "UAH": 2, "USD": 2,
+	"USN": 2, "UYU": 2, "UZS": 2, "VED": 2, "VES": 2, "WST": 2, "XCD": 2, "XCG": 2, "YER": 2, "ZAR": 2,
+	"ZMW": 2, "ZWG": 2, "ZWL": 2,
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0, "RWF": 0,
+	"UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// ParseCurrency проверяет код валюты, регистр не важен
+func ParseCurrency(code string) (Currency, error) {
+	c := Currency(strings.ToUpper(strings.TrimSpace(code)))
+	if _, ok := exponents[c]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownCurrency, code)
+	}
+	return c, nil
+}
+
+// Exponent - число знаков дробной части, у неизвестной валюты -1
+func (c Currency) Exponent() int {
+	if e, ok := exponents[c]; ok {
+		return e
+	}
+	return -1
+}
+
+// Money - сумма в минимальных единицах валюты (копейках, центах)
+type Money struct {
+	Amount   int64    `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+// FromMajor переводит сумму в целых единицах валюты, в таких суммы приходят в заказах
+func FromMajor(major int64, currency string) (Money, error) {
+	c, err := ParseCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: major * pow10(c.Exponent()), Currency: c}, nil
+}
+
+// Add складывает суммы одной валюты
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s и %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+// Sub вычитает сумму той же валюты
+func (m Money) Sub(o Money) (Money, error) {
+	return m.Add(Money{Amount: -o.Amount, Currency: o.Currency})
+}
+
+// Mul умножает сумму на целое, например на количество товара
+func (m Money) Mul(n int64) Money {
+	return Money{Amount: m.Amount * n, Currency: m.Currency}
+}
+
+// Sum складывает суммы одной валюты, пустой список - ошибка, так как валюта неизвестна
+func Sum(ms ...Money) (Money, error) {
+	if len(ms) == 0 {
+		return Money{}, errors.New("нет сумм для сложения")
+	}
+	total := ms[0]
+	for _, m := range ms[1:] {
+		var err error
+		if total, err = total.Add(m); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// String печатает сумму в целых единицах: 18.17 USD
+func (m Money) String() string {
+	exp := m.Currency.Exponent()
+	if exp <= 0 {
+		return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+	}
+	sign, a := "", m.Amount
+	if a < 0 {
+		sign, a = "-", -a
+	}
+	p := pow10(exp)
+	return fmt.Sprintf("%s%d.%0*d %s", sign, a/p, exp, a%p, m.Currency)
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for range n {
+		p *= 10
+	}
+	return p
+}
+
+// AmountMoney - итог платежа. Суммы платежа в JSON заказа остаются целыми
+// числами в единицах валюты Currency, в Money они переводятся по запросу.
+func (p Payment) AmountMoney() (Money, error) {
+	return FromMajor(int64(p.Amount), p.Currency)
+}
+
+// DeliveryCostMoney - стоимость доставки
+func (p Payment) DeliveryCostMoney() (Money, error) {
+	return FromMajor(int64(p.DeliveryCost), p.Currency)
+}
+
+// GoodsTotalMoney - стоимость товаров
+func (p Payment) GoodsTotalMoney() (Money, error) {
+	return FromMajor(int64(p.GoodsTotal), p.Currency)
+}
+
+// CustomFeeMoney - таможенный сбор
+func (p Payment) CustomFeeMoney() (Money, error) {
+	return FromMajor(int64(p.CustomFee), p.Currency)
+}
+
+// PriceMoney - цена товара. У товара нет своей валюты, currency - валюта
+// платежа заказа.
+func (it Item) PriceMoney(currency string) (Money, error) {
+	return FromMajor(int64(it.Price), currency)
+}
+
+// TotalPriceMoney - цена товара со скидкой в валюте платежа currency
+func (it Item) TotalPriceMoney(currency string) (Money, error) {
+	return FromMajor(int64(it.TotalPrice), currency)
+}
+
+// ItemsTotal - сумма total_price товаров в валюте платежа
+func (o *Order) ItemsTotal() (Money, error) {
+	c, err := ParseCurrency(o.Payment.Currency)
+	if err != nil {
+		return Money{}, err
+	}
+	total := Money{Currency: c}
+	for _, it := range o.Items {
+		m, err := it.TotalPriceMoney(o.Payment.Currency)
+		if err != nil {
+			return Money{}, err
+		}
+		if total, err = total.Add(m); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
@@ -1,6 +1,10 @@
 package model
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 type Delivery struct {
 	Name    string `json:"name"`
@@ -54,4 +58,24 @@ type Order struct {
 	SmID              int       `json:"sm_id"`
 	DateCreated       time.Time `json:"date_created"`
 	OofShard          string    `json:"oof_shard"`
+	// статус ведёт сервис, из входящих сообщений не берётся
+	Status Status `json:"status,omitempty"`
+	// время последнего сохранения в бд, в JSON заказа не передаётся
+	UpdatedAt time.Time `json:"-"`
+}
+
+// Validate проверяет обязательные поля заказа и валюту платежа
+func (o *Order) Validate() error {
+	switch {
+	case o.OrderUID == "":
+		return errors.New("в заказе нет order_uid")
+	case o.TrackNumber == "":
+		return fmt.Errorf("в заказе %s нет track_number", o.OrderUID)
+	case len(o.Items) == 0:
+		return fmt.Errorf("в заказе %s нет товаров", o.OrderUID)
+	}
+	if _, err := ParseCurrency(o.Payment.Currency); err != nil {
+		return fmt.Errorf("заказ %s: %w", o.OrderUID, err)
+	}
+	return nil
 }
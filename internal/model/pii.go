@@ -0,0 +1,78 @@
+package model
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maskedValue = "***"
+
+// Masked возвращает данные доставки со скрытыми персональными данными: от имени
+// остаются первые буквы слов, от телефона - две последние цифры, от email -
+// первая буква и домен, индекс и адрес скрываются целиком. Город и регион
+// остаются, они нужны для отчётов по регионам.
+func (d Delivery) Masked() Delivery {
+	return Delivery{
+		Name:    maskName(d.Name),
+		Phone:   maskPhone(d.Phone),
+		Zip:     maskAll(d.Zip),
+		City:    d.City,
+		Address: maskAll(d.Address),
+		Region:  d.Region,
+		Email:   maskEmail(d.Email),
+	}
+}
+
+// Masked возвращает копию заказа с маскированными данными доставки,
+// товары у копии общие с исходным заказом
+func (o *Order) Masked() *Order {
+	m := *o
+	m.Delivery = o.Delivery.Masked()
+	return &m
+}
+
+func maskAll(s string) string {
+	if s == "" {
+		return ""
+	}
+	return maskedValue
+}
+
+func maskName(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		r, _ := utf8.DecodeRuneInString(w)
+		words[i] = string(r) + maskedValue
+	}
+	return strings.Join(words, " ")
+}
+
+func maskPhone(s string) string {
+	digits := 0
+	for _, r := range s {
+		if unicode.IsDigit(r) {
+			digits++
+		}
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsDigit(r) {
+			digits--
+			if digits >= 2 {
+				r = '*'
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func maskEmail(s string) string {
+	local, domain, ok := strings.Cut(s, "@")
+	if !ok || local == "" {
+		return maskAll(s)
+	}
+	r, _ := utf8.DecodeRuneInString(local)
+	return string(r) + maskedValue + "@" + domain
+}
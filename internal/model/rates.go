@@ -0,0 +1,86 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var ErrNoRate = errors.New("нет курса валюты")
+
+// ExchangeRates переводит суммы между валютами, нужен для отчётов в одной валюте
+type ExchangeRates interface {
+	Convert(m Money, to Currency) (Money, error)
+}
+
+// RateTable - фиксированные курсы к базовой валюте
+type RateTable struct {
+	base Currency
+	// сколько единиц базовой валюты стоит единица валюты
+	rates map[Currency]*big.Rat
+}
+
+// NewRateTable создаёт таблицу курсов. Курсы задаются десятичными строками,
+// чтобы не терять точность: {"USD": "92.35"} при базовой RUB.
+func NewRateTable(base string, rates map[string]string) (*RateTable, error) {
+	b, err := ParseCurrency(base)
+	if err != nil {
+		return nil, err
+	}
+	t := &RateTable{base: b, rates: map[Currency]*big.Rat{b: big.NewRat(1, 1)}}
+	for code, s := range rates {
+		c, err := ParseCurrency(code)
+		if err != nil {
+			return nil, err
+		}
+		r, ok := new(big.Rat).SetString(s)
+		if !ok || r.Sign() <= 0 {
+			return nil, fmt.Errorf("некорректный курс %s: %q", c, s)
+		}
+		if c == b && r.Cmp(big.NewRat(1, 1)) != 0 {
+			return nil, fmt.Errorf("курс базовой валюты %s должен быть 1", b)
+		}
+		t.rates[c] = r
+	}
+	return t, nil
+}
+
+// Base - базовая валюта таблицы
+func (t *RateTable) Base() Currency {
+	return t.base
+}
+
+// Convert переводит сумму в валюту to через базовую валюту,
+// округляя до минимальной единицы половиной от нуля
+func (t *RateTable) Convert(m Money, to Currency) (Money, error) {
+	if m.Currency == to {
+		return m, nil
+	}
+	from, ok := t.rates[m.Currency]
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %s", ErrNoRate, m.Currency)
+	}
+	target, ok := t.rates[to]
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %s", ErrNoRate, to)
+	}
+
+	// minor_to = minor_from / 10^exp_from * rate_from / rate_to * 10^exp_to
+	v := new(big.Rat).SetInt64(m.Amount)
+	v.Mul(v, from)
+	v.Quo(v, target)
+	v.Mul(v, new(big.Rat).SetFrac64(pow10(to.Exponent()), pow10(m.Currency.Exponent())))
+	return Money{Amount: roundHalfAway(v), Currency: to}, nil
+}
+
+func roundHalfAway(v *big.Rat) int64 {
+	num, den := new(big.Int).Abs(v.Num()), v.Denom()
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Lsh(r, 1).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if v.Sign() < 0 {
+		q.Neg(q)
+	}
+	return q.Int64()
+}
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Status - этап жизненного цикла заказа
+type Status string
+
+const (
+	StatusCreated   Status = "created"
+	StatusPaid      Status = "paid"
+	StatusAssembled Status = "assembled"
+	StatusShipped   Status = "shipped"
+	StatusDelivered Status = "delivered"
+	StatusCancelled Status = "cancelled"
+	StatusReturned  Status = "returned"
+)
+
+var (
+	ErrUnknownStatus     = errors.New("неизвестный статус заказа")
+	ErrInvalidTransition = errors.New("недопустимая смена статуса")
+)
+
+// допустимые переходы, из cancelled и returned перейти нельзя
+var transitions = map[Status][]Status{
+	StatusCreated:   {StatusPaid, StatusCancelled},
+	StatusPaid:      {StatusAssembled, StatusCancelled},
+	StatusAssembled: {StatusShipped, StatusCancelled},
+	StatusShipped:   {StatusDelivered, StatusReturned},
+	StatusDelivered: {StatusReturned},
+	StatusCancelled: nil,
+	StatusReturned:  nil,
+}
+
+// Valid сообщает, известен ли статус
+func (s Status) Valid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+// Next возвращает статусы, в которые можно перейти из s
+func (s Status) Next() []Status {
+	return transitions[s]
+}
+
+// CheckTransition проверяет, можно ли перевести заказ из from в to
+func CheckTransition(from, to Status) error {
+	if !to.Valid() {
+		return fmt.Errorf("%w: %q", ErrUnknownStatus, to)
+	}
+	for _, s := range transitions[from] {
+		if s == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+}
+
+// StatusChange - запись истории статусов заказа
+type StatusChange struct {
+	// пусто у записи о создании заказа
+	From   Status `json:"from,omitempty"`
+	To     Status `json:"to"`
+	Reason string `json:"reason,omitempty"`
+	// откуда пришло изменение: kafka, api и т.п.
+	Source    string    `json:"source"`
+	ChangedAt time.Time `json:"changed_at"`
+}
@@ -0,0 +1,142 @@
+// Package reconcile проверяет, что суммы заказа сходятся между собой:
+// цены товаров со скидкой, сумма товаров и итог платежа.
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"demo-service/internal/infrastructure/postgres"
+	"demo-service/internal/model"
+)
+
+// Правила проверки
+const (
+	RuleSale       = "item_sale"
+	RuleItemTotal  = "item_total_price"
+	RuleGoodsTotal = "goods_total"
+	RuleAmount     = "amount"
+	RuleCurrency   = "currency"
+)
+
+const defaultPageSize = 500
+
+// Issue - нарушенное правило. Суммы - в единицах валюты, как в заказе.
+type Issue struct {
+	Rule     string `json:"rule"`
+	Item     *int   `json:"item,omitempty"`
+	Expected int64  `json:"expected"`
+	Actual   int64  `json:"actual"`
+	Detail   string `json:"detail"`
+}
+
+// Check проверяет заказ и возвращает нарушения, nil - всё сходится:
+//   - total_price товара равен price минус sale процентов (с точностью до округления);
+//   - goods_total равен сумме total_price товаров;
+//   - amount равен goods_total + delivery_cost + custom_fee.
+func Check(o *model.Order) []Issue {
+	var issues []Issue
+	for i, it := range o.Items {
+		if it.Sale < 0 || it.Sale > 100 {
+			issues = append(issues, Issue{
+				Rule: RuleSale, Item: &i, Expected: 0, Actual: int64(it.Sale),
+				Detail: fmt.Sprintf("скидка товара %d вне диапазона 0-100%%", it.ChrtID),
+			})
+			continue
+		}
+		// точное значение price*(100-sale)/100, допустимо любое из двух соседних целых
+		exact := int64(it.Price) * int64(100-it.Sale)
+		lo, hi := exact/100, (exact+99)/100
+		if total := int64(it.TotalPrice); total != lo && total != hi {
+			issues = append(issues, Issue{
+				Rule: RuleItemTotal, Item: &i, Expected: lo, Actual: total,
+				Detail: fmt.Sprintf("total_price товара %d не равен price %d минус %d%%", it.ChrtID, it.Price, it.Sale),
+			})
+		}
+	}
+
+	items, err := o.ItemsTotal()
+	if err != nil {
+		return append(issues, Issue{Rule: RuleCurrency, Detail: err.Error()})
+	}
+	goods, _ := o.Payment.GoodsTotalMoney()
+	if goods != items {
+		issues = append(issues, Issue{
+			Rule: RuleGoodsTotal, Expected: major(items), Actual: major(goods),
+			Detail: fmt.Sprintf("goods_total %s не равен сумме товаров %s", goods, items),
+		})
+	}
+
+	delivery, _ := o.Payment.DeliveryCostMoney()
+	fee, _ := o.Payment.CustomFeeMoney()
+	amount, _ := o.Payment.AmountMoney()
+	// все суммы в валюте платежа, поэтому ошибки сложения быть не может
+	want, _ := model.Sum(goods, delivery, fee)
+	if amount != want {
+		issues = append(issues, Issue{
+			Rule: RuleAmount, Expected: major(want), Actual: major(amount),
+			Detail: fmt.Sprintf("amount %s не равен goods_total + delivery_cost + custom_fee = %s", amount, want),
+		})
+	}
+	return issues
+}
+
+func major(m model.Money) int64 {
+	e := m.Currency.Exponent()
+	for range e {
+		m.Amount /= 10
+	}
+	return m.Amount
+}
+
+// OrderIssues - нарушения одного заказа
+type OrderIssues struct {
+	OrderUID string  `json:"order_uid"`
+	Issues   []Issue `json:"issues"`
+}
+
+// Report - итог проверки заказов
+type Report struct {
+	GeneratedAt  time.Time `json:"generated_at"`
+	Checked      int       `json:"checked"`
+	Inconsistent int       `json:"inconsistent"`
+	// заказы с нарушениями по возрастанию order_uid, не больше limit
+	Orders    []OrderIssues `json:"orders"`
+	Truncated bool          `json:"truncated,omitempty"`
+}
+
+// Lister - постраничное чтение заказов из хранилища
+type Lister interface {
+	ListOrders(ctx context.Context, f postgres.ListFilter) ([]*model.Order, error)
+}
+
+// Run проверяет все заказы хранилища. limit ограничивает число заказов
+// в отчёте (0 - без ограничения), считаются при этом все.
+func Run(ctx context.Context, store Lister, limit int) (Report, error) {
+	report := Report{GeneratedAt: time.Now().UTC(), Orders: []OrderIssues{}}
+	f := postgres.ListFilter{Limit: defaultPageSize}
+	for {
+		page, err := store.ListOrders(ctx, f)
+		if err != nil {
+			return Report{}, err
+		}
+		for _, o := range page {
+			report.Checked++
+			issues := Check(o)
+			if len(issues) == 0 {
+				continue
+			}
+			report.Inconsistent++
+			if limit > 0 && len(report.Orders) >= limit {
+				report.Truncated = true
+				continue
+			}
+			report.Orders = append(report.Orders, OrderIssues{OrderUID: o.OrderUID, Issues: issues})
+		}
+		if len(page) < f.Limit {
+			return report, nil
+		}
+		f.After = page[len(page)-1].OrderUID
+	}
+}
@@ -0,0 +1,100 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"demo-service/internal/infrastructure/postgres"
+	"demo-service/internal/model"
+)
+
+func sampleOrder(uid string) *model.Order {
+	return &model.Order{
+		OrderUID: uid,
+		Payment: model.Payment{
+			Currency: "USD", Amount: 1817, DeliveryCost: 1500, GoodsTotal: 317, CustomFee: 0,
+		},
+		Items: []model.Item{{ChrtID: 9934930, Price: 453, Sale: 30, TotalPrice: 317}},
+	}
+}
+
+func TestCheck(t *testing.T) {
+	if issues := Check(sampleOrder("ok")); issues != nil {
+		t.Fatalf("эталонный заказ не должен иметь расхождений: %+v", issues)
+	}
+
+	cases := []struct {
+		name   string
+		modify func(o *model.Order)
+		rules  []string
+	}{
+		{"цена со скидкой", func(o *model.Order) { o.Items[0].TotalPrice = 300 }, []string{RuleItemTotal, RuleGoodsTotal}},
+		{"скидка больше 100", func(o *model.Order) { o.Items[0].Sale = 130 }, []string{RuleSale}},
+		{"сумма товаров", func(o *model.Order) { o.Payment.GoodsTotal = 300; o.Payment.Amount = 1800 }, []string{RuleGoodsTotal}},
+		{"итог платежа", func(o *model.Order) { o.Payment.CustomFee = 10 }, []string{RuleAmount}},
+		{"валюта", func(o *model.Order) { o.Payment.Currency = "" }, []string{RuleCurrency}},
+	}
+	for _, tc := range cases {
+		o := sampleOrder("x")
+		tc.modify(o)
+		issues := Check(o)
+		if len(issues) != len(tc.rules) {
+			t.Errorf("%s: ожидались %v, получено %+v", tc.name, tc.rules, issues)
+			continue
+		}
+		for i, rule := range tc.rules {
+			if issues[i].Rule != rule {
+				t.Errorf("%s: ожидалось правило %s, получено %s", tc.name, rule, issues[i].Rule)
+			}
+		}
+	}
+
+	// 453 - 30% = 317.1, допустимо и 318
+	o := sampleOrder("round")
+	o.Items[0].TotalPrice, o.Payment.GoodsTotal, o.Payment.Amount = 318, 318, 1818
+	if issues := Check(o); issues != nil {
+		t.Errorf("округление вверх не должно быть расхождением: %+v", issues)
+	}
+}
+
+type fakeLister struct {
+	orders []*model.Order
+	calls  int
+}
+
+func (f *fakeLister) ListOrders(_ context.Context, flt postgres.ListFilter) ([]*model.Order, error) {
+	f.calls++
+	var page []*model.Order
+	for _, o := range f.orders {
+		if o.OrderUID > flt.After && len(page) < flt.Limit {
+			page = append(page, o)
+		}
+	}
+	return page, nil
+}
+
+func TestRun(t *testing.T) {
+	store := &fakeLister{}
+	for i := range defaultPageSize + 10 {
+		o := sampleOrder(fmt.Sprintf("order-%04d", i))
+		if i%100 == 0 {
+			o.Payment.Amount++
+		}
+		store.orders = append(store.orders, o)
+	}
+
+	report, err := Run(context.Background(), store, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != defaultPageSize+10 || report.Inconsistent != 6 {
+		t.Errorf("проверено %d, с расхождениями %d", report.Checked, report.Inconsistent)
+	}
+	if len(report.Orders) != 3 || !report.Truncated || report.Orders[0].OrderUID != "order-0000" {
+		t.Errorf("неверный список заказов: %+v", report.Orders)
+	}
+	if store.calls != 2 {
+		t.Errorf("ожидалось 2 страницы, запрошено %d", store.calls)
+	}
+}
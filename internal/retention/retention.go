@@ -0,0 +1,89 @@
+// Package retention периодически удаляет или архивирует устаревшие заказы.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"demo-service/internal/audit"
+	"demo-service/internal/infrastructure/cache"
+)
+
+const (
+	ActorRetention = "retention"
+	batchSize      = 500
+)
+
+// Store - операция хранилища, нужная заданию
+type Store interface {
+	ExpireOrders(ctx context.Context, before time.Time, limit int, archive bool) ([]string, error)
+}
+
+// Job удаляет заказы старше maxAge. При archive они сначала
+// сохраняются в архив, иначе удаляются безвозвратно.
+type Job struct {
+	store    Store
+	cache    *cache.Cache
+	auditor  *audit.Auditor
+	maxAge   time.Duration
+	interval time.Duration
+	archive  bool
+}
+
+func NewJob(store Store, cacheStore *cache.Cache, auditor *audit.Auditor, maxAge, interval time.Duration, archive bool) *Job {
+	return &Job{
+		store:    store,
+		cache:    cacheStore,
+		auditor:  auditor,
+		maxAge:   maxAge,
+		interval: interval,
+		archive:  archive,
+	}
+}
+
+// Run запускает задание сразу и затем каждые interval до отмены ctx
+func (j *Job) Run(ctx context.Context) {
+	log.Printf("Задание хранения: заказы старше %s, раз в %s, archive=%v", j.maxAge, j.interval, j.archive)
+	t := time.NewTicker(j.interval)
+	defer t.Stop()
+	for {
+		if n, err := j.RunOnce(ctx); err != nil {
+			log.Printf("Ошибка задания хранения: %v", err)
+		} else if n > 0 {
+			log.Printf("Задание хранения: удалено заказов %d", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// RunOnce удаляет все устаревшие на текущий момент заказы пачками
+// и возвращает их число
+func (j *Job) RunOnce(ctx context.Context) (int, error) {
+	before := time.Now().Add(-j.maxAge)
+	total := 0
+	for {
+		uids, err := j.store.ExpireOrders(ctx, before, batchSize, j.archive)
+		if err != nil {
+			return total, err
+		}
+		for _, uid := range uids {
+			j.cache.Delete(uid)
+			j.auditor.Record(ctx, audit.Event{
+				Actor:    ActorRetention,
+				Action:   audit.ActionRetention,
+				OrderUID: uid,
+				Details:  fmt.Sprintf("старше %s, archive=%v", before.UTC().Format(time.RFC3339), j.archive),
+			})
+		}
+		total += len(uids)
+		if len(uids) < batchSize {
+			return total, nil
+		}
+	}
+}
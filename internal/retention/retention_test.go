@@ -0,0 +1,66 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"demo-service/internal/audit"
+	"demo-service/internal/infrastructure/cache"
+	"demo-service/internal/model"
+)
+
+type fakeStore struct {
+	left    int
+	archive []bool
+}
+
+func (f *fakeStore) ExpireOrders(_ context.Context, _ time.Time, limit int, archive bool) ([]string, error) {
+	f.archive = append(f.archive, archive)
+	n := min(limit, f.left)
+	uids := make([]string, n)
+	for i := range uids {
+		uids[i] = fmt.Sprintf("order-%d", f.left-i)
+	}
+	f.left -= n
+	return uids, nil
+}
+
+type fakeSink struct {
+	events []audit.Event
+}
+
+func (f *fakeSink) AppendAudit(_ context.Context, events []audit.Event) error {
+	f.events = append(f.events, events...)
+	return nil
+}
+
+func (f *fakeSink) QueryAudit(context.Context, audit.Query) ([]audit.Event, error) {
+	return f.events, nil
+}
+
+func TestRunOnce(t *testing.T) {
+	store := &fakeStore{left: batchSize + 3}
+	sink := &fakeSink{}
+	c := cache.NewCache()
+	c.Set(&model.Order{OrderUID: "order-1"})
+
+	auditor := audit.New(sink)
+	job := NewJob(store, c, auditor, 24*time.Hour, time.Hour, true)
+	n, err := job.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// запись аудита асинхронная, Close дожидается очереди
+	auditor.Close()
+	if n != batchSize+3 || len(store.archive) != 2 || !store.archive[0] {
+		t.Errorf("удалено %d заказов за %d пачек", n, len(store.archive))
+	}
+	if _, ok := c.Get("order-1"); ok {
+		t.Error("удалённый заказ должен уйти из кеша")
+	}
+	if len(sink.events) != n || sink.events[0].Action != audit.ActionRetention || sink.events[0].Actor != ActorRetention {
+		t.Errorf("ожидалось %d событий аудита, получено %d: %+v", n, len(sink.events), sink.events[:1])
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"demo-service/internal/codec"
+	"demo-service/internal/model"
+)
+
+// Виды ошибочных сообщений
+const (
+	FaultMalformed     = "malformed"
+	FaultMissingUID    = "missing_uid"
+	FaultOversized     = "oversized"
+	FaultDuplicate     = "duplicate"
+	FaultOutOfOrder    = "out_of_order"
+	FaultInvalidTotals = "invalid_totals"
+	// обычный заказ
+	kindValid = "valid"
+)
+
+// ожидаемая реакция сервиса на сообщения каждого вида
+var faultExpect = map[string]string{
+	kindValid:          "accepted",
+	FaultMalformed:     "dlq",
+	FaultMissingUID:    "dlq",
+	FaultOversized:     "rejected",
+	FaultDuplicate:     "accepted",
+	FaultOutOfOrder:    "dlq",
+	FaultInvalidTotals: "reconcile",
+}
+
+// описания видов для справки по флагу -faults
+var faultHelp = map[string]string{
+	FaultMalformed:     "обрезанный JSON, уходит в DLQ",
+	FaultMissingUID:    "заказ без order_uid, уходит в DLQ",
+	FaultOversized:     "заказ больше -oversize байт, отклоняется брокером или бд (DLQ)",
+	FaultDuplicate:     "повтор уже отправленного order_uid с другими данными, перезаписывает заказ",
+	FaultOutOfOrder:    "смена статуса shipped раньше paid: shipped уходит в DLQ, paid принимается",
+	FaultInvalidTotals: "amount не сходится с товарами, заказ принимается, попадает в отчёт сверки",
+}
+
+// сколько отправленных заказов помнить для повторов и смены статусов
+const recentOrders = 100
+
+// faultRatio - доля сообщений одного вида
+type faultRatio struct {
+	kind  string
+	ratio float64
+}
+
+// parseFaults разбирает значение флага -faults: "malformed=0.05,duplicate=0.1"
+func parseFaults(s string) ([]faultRatio, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var out []faultRatio
+	total := 0.0
+	for _, part := range strings.Split(s, ",") {
+		kind, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if _, known := faultHelp[kind]; !ok || !known {
+			return nil, fmt.Errorf("faults: неизвестный вид %q, ожидался один из %s", kind, strings.Join(faultKinds(), ", "))
+		}
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("faults: доля %s должна быть от 0 до 1", kind)
+		}
+		total += ratio
+		out = append(out, faultRatio{kind: kind, ratio: ratio})
+	}
+	if total > 1 {
+		return nil, fmt.Errorf("faults: сумма долей %.2f больше 1", total)
+	}
+	return out, nil
+}
+
+// faultUsage - справка по видам ошибочных сообщений, по виду в строке
+func faultUsage() string {
+	var b strings.Builder
+	for _, k := range faultKinds() {
+		fmt.Fprintf(&b, "\n  %s - %s", k, faultHelp[k])
+	}
+	return b.String()
+}
+
+func faultKinds() []string {
+	kinds := make([]string, 0, len(faultHelp))
+	for k := range faultHelp {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// faultInjector подмешивает к сгенерированным заказам ошибочные сообщения
+// и ведёт сводку отправленного
+type faultInjector struct {
+	r           *rand.Rand
+	gen         *Generator
+	encode      func(*model.Order) ([]byte, error)
+	ratios      []faultRatio
+	oversize    int
+	statusTopic string
+
+	recent []sentOrder
+
+	summary *faultSummary
+}
+
+// sentOrder - отправленный заказ и ключ его сообщения: повтор должен попасть
+// в тот же раздел, иначе он может обогнать исходный заказ
+type sentOrder struct {
+	key   string
+	order model.Order
+}
+
+func newFaultInjector(seed int64, gen *Generator, encode func(*model.Order) ([]byte, error), ratios []faultRatio, oversize int, statusTopic string) *faultInjector {
+	return &faultInjector{
+		r:           rand.New(rand.NewSource(seed)),
+		gen:         gen,
+		encode:      encode,
+		ratios:      ratios,
+		oversize:    oversize,
+		statusTopic: statusTopic,
+		summary:     newFaultSummary(seed),
+	}
+}
+
+func (f *faultInjector) pick() string {
+	u := f.r.Float64()
+	for _, fr := range f.ratios {
+		if u < fr.ratio {
+			return fr.kind
+		}
+		u -= fr.ratio
+	}
+	return kindValid
+}
+
+// next возвращает очередное сообщение. Ошибка - сбой кодирования, не вид сообщения.
+func (f *faultInjector) next() (message, error) {
+	kind := f.pick()
+	// повтору и смене статуса нужен уже отправленный заказ
+	if (kind == FaultDuplicate || kind == FaultOutOfOrder) && len(f.recent) == 0 {
+		kind = kindValid
+	}
+
+	switch kind {
+	case FaultMalformed:
+		key, order := f.gen.Next()
+		data, _ := json.Marshal(order)
+		return message{key: key, value: data[:len(data)/2], kind: kind, contentType: codec.JSON.ContentType()}, nil
+
+	case FaultMissingUID:
+		key, order := f.gen.Next()
+		order.OrderUID = ""
+		return f.order(kind, key, &order)
+
+	case FaultOversized:
+		key, order := f.gen.Next()
+		order.Items[0].Name = strings.Repeat("x", f.oversize)
+		return f.order(kind, key, &order)
+
+	case FaultDuplicate:
+		sent := f.recent[f.r.Intn(len(f.recent))]
+		order := sent.order
+		order.Items = append([]model.Item(nil), order.Items...)
+		order.Items[0].Size = "changed-" + strconv.Itoa(f.r.Intn(1000))
+		order.Delivery.Address = fmt.Sprintf("Изменённый адрес %d", f.r.Intn(1000))
+		return f.order(kind, sent.key, &order)
+
+	case FaultOutOfOrder:
+		order := f.recent[f.r.Intn(len(f.recent))].order
+		shipped, _ := json.Marshal(map[string]string{"order_uid": order.OrderUID, "status": string(model.StatusShipped), "reason": "fault injection"})
+		paid, _ := json.Marshal(map[string]string{"order_uid": order.OrderUID, "status": string(model.StatusPaid), "reason": "fault injection"})
+		// paid отправляется тем же потоком сразу после shipped, иначе порядок не гарантирован
+		return message{topic: f.statusTopic, key: order.OrderUID, value: shipped, kind: kind, contentType: codec.JSON.ContentType(),
+			then: &message{topic: f.statusTopic, key: order.OrderUID, value: paid, kind: kindValid, contentType: codec.JSON.ContentType()}}, nil
+
+	case FaultInvalidTotals:
+		key, order := f.gen.Next()
+		order.Payment.Amount += 1 + f.r.Intn(100)
+		return f.order(kind, key, &order)
+	}
+
+	key, order := f.gen.Next()
+	f.recent = append(f.recent, sentOrder{key: key, order: order})
+	if len(f.recent) > recentOrders {
+		f.recent = f.recent[1:]
+	}
+	return f.order(kindValid, key, &order)
+}
+
+func (f *faultInjector) order(kind, key string, order *model.Order) (message, error) {
+	data, err := f.encode(order)
+	if err != nil {
+		return message{}, err
+	}
+	return message{key: key, value: data, kind: kind}, nil
+}
+
+// faultSummary - что отправлено, по видам сообщений. По ключам из сводки
+// тесты находят сообщения в DLQ и проверяют ответы API.
+type faultSummary struct {
+	Seed  int64                   `json:"seed"`
+	Kinds map[string]*kindSummary `json:"kinds"`
+
+	mu sync.Mutex
+}
+
+type kindSummary struct {
+	// ожидаемая реакция: accepted, dlq, rejected или reconcile
+	Expect string `json:"expect"`
+	Sent   int    `json:"sent"`
+	// ошибки отправки, для oversized это ожидаемый отказ брокера
+	Failed int `json:"failed"`
+	// ключи сообщений, в том числе неотправленных; у обычных заказов не собираются
+	Keys []string `json:"keys,omitempty"`
+}
+
+func newFaultSummary(seed int64) *faultSummary {
+	return &faultSummary{Seed: seed, Kinds: make(map[string]*kindSummary)}
+}
+
+func (s *faultSummary) record(m message, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.Kinds[m.kind]
+	if !ok {
+		k = &kindSummary{Expect: faultExpect[m.kind]}
+		s.Kinds[m.kind] = k
+	}
+	if m.kind != kindValid {
+		k.Keys = append(k.Keys, m.key)
+	}
+	if err != nil {
+		k.Failed++
+		return
+	}
+	k.Sent++
+}
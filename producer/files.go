@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"demo-service/internal/codec"
+	"demo-service/internal/model"
+)
+
+// record - сообщение из файла: заказ целиком или конверт с ключом,
+// заголовками и временем исходного сообщения
+type record struct {
+	Key     *string           `json:"key"`
+	Value   json.RawMessage   `json:"value"`
+	Headers map[string]string `json:"headers"`
+	Time    time.Time         `json:"timestamp"`
+	// источник для сообщений об ошибках: файл и номер записи
+	source string
+}
+
+// fileSource по очереди читает записи из файла или всех *.json, *.ndjson
+// и *.jsonl файлов каталога. Файл - JSON-объект, массив объектов или NDJSON;
+// "-" - stdin.
+type fileSource struct {
+	files []string
+	file  io.Closer
+	dec   *json.Decoder
+	name  string
+	n     int
+	// внутри массива верхнего уровня
+	inArray bool
+}
+
+func newFileSource(path string) (*fileSource, error) {
+	if path == "-" {
+		return &fileSource{files: []string{"-"}}, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return &fileSource{files: []string{path}}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".json", ".ndjson", ".jsonl":
+			if !e.IsDir() {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("в каталоге %s нет файлов .json, .ndjson или .jsonl", path)
+	}
+	sort.Strings(files)
+	return &fileSource{files: files}, nil
+}
+
+// next возвращает следующую запись, io.EOF - записи кончились
+func (s *fileSource) next() (record, error) {
+	for {
+		if s.dec == nil {
+			if len(s.files) == 0 {
+				return record{}, io.EOF
+			}
+			if err := s.open(s.files[0]); err != nil {
+				return record{}, err
+			}
+			s.files = s.files[1:]
+		}
+		if s.dec.More() {
+			var raw json.RawMessage
+			if err := s.dec.Decode(&raw); err != nil {
+				return record{}, fmt.Errorf("%s, запись %d: %w", s.name, s.n+1, err)
+			}
+			s.n++
+			return parseRecord(raw, fmt.Sprintf("%s:%d", s.name, s.n))
+		}
+		if s.inArray {
+			if _, err := s.dec.Token(); err != nil {
+				return record{}, fmt.Errorf("%s: %w", s.name, err)
+			}
+		}
+		// после данных в файле ничего не должно остаться
+		if _, err := s.dec.Token(); !errors.Is(err, io.EOF) {
+			return record{}, fmt.Errorf("%s: лишние данные после записи %d", s.name, s.n)
+		}
+		s.close()
+	}
+}
+
+func (s *fileSource) open(name string) error {
+	var r io.Reader
+	if name == "-" {
+		r = os.Stdin
+	} else {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		s.file, r = f, f
+	}
+	br := bufio.NewReader(r)
+	s.dec, s.name, s.n, s.inArray = json.NewDecoder(br), name, 0, false
+
+	// массив верхнего уровня читается по элементам, а не целиком
+	first, err := peekNonSpace(br)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if first == '[' {
+		if _, err := s.dec.Token(); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		s.inArray = true
+	}
+	return nil
+}
+
+func (s *fileSource) close() {
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+	s.dec = nil
+}
+
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for i := 1; ; i++ {
+		b, err := br.Peek(i)
+		if err != nil {
+			return 0, err
+		}
+		if c := b[i-1]; c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			return c, nil
+		}
+	}
+}
+
+// parseRecord отличает конверт {"key", "value", ...} от заказа
+func parseRecord(raw json.RawMessage, source string) (record, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return record{}, fmt.Errorf("%s: ожидался JSON-объект: %w", source, err)
+	}
+	_, hasValue := probe["value"]
+	_, hasUID := probe["order_uid"]
+	if !hasValue || hasUID {
+		return record{Value: raw, source: source}, nil
+	}
+	var rec record
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return record{}, fmt.Errorf("%s: некорректный конверт: %w", source, err)
+	}
+	// значение строкой - исходные байты сообщения, в том числе некорректный JSON
+	var s string
+	if json.Unmarshal(rec.Value, &s) == nil {
+		rec.Value = json.RawMessage(s)
+	}
+	rec.source = source
+	return rec, nil
+}
+
+// replayOptions - что менять в заказах из файлов
+type replayOptions struct {
+	// новые order_uid, повторы одного заказа получают один и тот же новый uid
+	rewriteUID bool
+	// сдвинуть даты так, чтобы первый заказ был создан сейчас
+	rewriteDates bool
+	// воспроизводить интервалы между исходными сообщениями, ускоряя в speed раз
+	originalPace bool
+	speed        float64
+	// заказы перекодируются в формат кодировщика; nil - отправляются как есть
+	encode func(*model.Order) ([]byte, error)
+}
+
+// fileReplayer превращает записи файлов в сообщения
+type fileReplayer struct {
+	src  *fileSource
+	opts replayOptions
+	r    *rand.Rand
+	uids map[string]string
+	// время первой записи для сдвига дат и темпа
+	first time.Time
+	shift time.Duration
+}
+
+func newFileReplayer(src *fileSource, opts replayOptions) *fileReplayer {
+	return &fileReplayer{src: src, opts: opts, r: rand.New(rand.NewSource(time.Now().UnixNano())), uids: make(map[string]string)}
+}
+
+// next возвращает следующее сообщение, io.EOF - записи кончились
+func (f *fileReplayer) next() (message, error) {
+	rec, err := f.src.next()
+	if err != nil {
+		return message{}, err
+	}
+
+	m := message{value: rec.Value, kind: kindValid, headers: rec.Headers, contentType: codec.JSON.ContentType()}
+	for k, v := range rec.Headers {
+		if strings.EqualFold(k, codec.HeaderContentType) {
+			m.contentType = v
+		}
+	}
+
+	var order model.Order
+	// некорректные записи отправляются как есть, если их не нужно менять
+	decodeErr := json.Unmarshal(rec.Value, &order)
+	if decodeErr == nil && order.OrderUID != "" {
+		m.key = order.OrderUID
+	}
+	if rec.Key != nil {
+		m.key = *rec.Key
+	}
+
+	at := rec.Time
+	if at.IsZero() {
+		at = order.DateCreated
+	}
+	if f.first.IsZero() && !at.IsZero() {
+		f.first = at
+		f.shift = time.Since(at)
+	}
+	if f.opts.originalPace && !at.IsZero() {
+		m.at = time.Duration(float64(at.Sub(f.first)) / f.opts.speed)
+	}
+
+	if !f.opts.rewriteUID && !f.opts.rewriteDates && f.opts.encode == nil {
+		return m, nil
+	}
+	if decodeErr != nil {
+		return message{}, fmt.Errorf("%s: заказ нельзя изменить или перекодировать: %w", rec.source, decodeErr)
+	}
+
+	if f.opts.rewriteUID && order.OrderUID != "" {
+		old := order.OrderUID
+		uid, ok := f.uids[old]
+		if !ok {
+			b := make([]byte, 8)
+			f.r.Read(b)
+			uid = hex.EncodeToString(b) + "test"
+			f.uids[old] = uid
+		}
+		order.OrderUID = uid
+		if order.Payment.Transaction == old {
+			order.Payment.Transaction = uid
+		}
+		if m.key == old {
+			m.key = uid
+		}
+	}
+	if f.opts.rewriteDates && !f.first.IsZero() {
+		if !order.DateCreated.IsZero() {
+			order.DateCreated = order.DateCreated.Add(f.shift).UTC()
+		}
+		if order.Payment.PaymentDt != 0 {
+			order.Payment.PaymentDt = time.Unix(order.Payment.PaymentDt, 0).Add(f.shift).Unix()
+		}
+	}
+
+	if f.opts.encode != nil {
+		m.value, err = f.opts.encode(&order)
+		m.contentType = ""
+	} else {
+		m.value, err = marshalCompact(&order)
+	}
+	if err != nil {
+		return message{}, fmt.Errorf("%s: %w", rec.source, err)
+	}
+	return m, nil
+}
+
+func marshalCompact(o *model.Order) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(o); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
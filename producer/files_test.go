@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"demo-service/internal/model"
+)
+
+func TestFileReplayer(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// массив заказов, NDJSON с конвертами и файл другого типа, который не читается
+	write("1-orders.json", `[
+		{"order_uid": "a", "date_created": "2021-11-26T06:00:00Z", "payment": {"transaction": "a", "payment_dt": 1637906400}},
+		{"order_uid": "b", "date_created": "2021-11-26T06:00:10Z"}
+	]`)
+	write("2-captured.ndjson", `{"key": "customer-7", "value": {"order_uid": "a", "date_created": "2021-11-26T06:00:20Z"}, "headers": {"x-trace": "1"}}
+{"key": "broken", "value": "{\"order_uid\": ", "timestamp": "2021-11-26T06:00:30Z"}
+`)
+	write("notes.txt", "не заказ")
+
+	src, err := newFileSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newFileReplayer(src, replayOptions{originalPace: true, speed: 2})
+	var msgs []message
+	for {
+		m, err := r.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, m)
+	}
+	if len(msgs) != 4 {
+		t.Fatalf("ожидалось 4 сообщения, прочитано %d", len(msgs))
+	}
+	if msgs[0].key != "a" || msgs[2].key != "customer-7" || msgs[2].headers["x-trace"] != "1" {
+		t.Errorf("ключи и заголовки не сохранены: %+v", msgs)
+	}
+	if string(msgs[3].value) != `{"order_uid": ` || msgs[3].key != "broken" {
+		t.Errorf("некорректная запись должна отправляться как есть: %q", msgs[3].value)
+	}
+	if msgs[1].at != 5*time.Second || msgs[3].at != 15*time.Second {
+		t.Errorf("неверный темп: %s, %s", msgs[1].at, msgs[3].at)
+	}
+}
+
+func TestFileReplayer_Rewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orders.ndjson")
+	data := `{"order_uid": "a", "date_created": "2021-11-26T06:00:00Z", "payment": {"transaction": "a", "payment_dt": 1637906400}}
+{"order_uid": "a", "date_created": "2021-11-26T07:00:00Z"}
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	src, err := newFileSource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newFileReplayer(src, replayOptions{rewriteUID: true, rewriteDates: true})
+
+	var orders []model.Order
+	for range 2 {
+		m, err := r.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var o model.Order
+		if err := json.Unmarshal(m.value, &o); err != nil {
+			t.Fatal(err)
+		}
+		if m.key != o.OrderUID {
+			t.Errorf("ключ %q должен смениться вместе с order_uid %q", m.key, o.OrderUID)
+		}
+		orders = append(orders, o)
+	}
+	if orders[0].OrderUID == "a" || orders[0].OrderUID != orders[1].OrderUID || orders[0].Payment.Transaction != orders[0].OrderUID {
+		t.Errorf("повтор заказа должен получить тот же новый uid: %q, %q", orders[0].OrderUID, orders[1].OrderUID)
+	}
+	if time.Since(orders[0].DateCreated) > time.Minute || orders[1].DateCreated.Sub(orders[0].DateCreated) != time.Hour {
+		t.Errorf("даты должны сдвинуться к текущему времени с сохранением интервалов: %s, %s", orders[0].DateCreated, orders[1].DateCreated)
+	}
+	if time.Since(time.Unix(orders[0].Payment.PaymentDt, 0)) > time.Minute {
+		t.Errorf("payment_dt не сдвинут: %d", orders[0].Payment.PaymentDt)
+	}
+}
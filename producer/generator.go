@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"demo-service/internal/model"
+)
+
+// справочники для правдоподобных заказов
+var (
+	firstNames = []string{"Иван", "Анна", "Дмитрий", "Мария", "Алексей", "Елена", "Сергей", "Ольга", "Test", "Noa", "David", "Sarah"}
+	lastNames  = []string{"Иванов", "Смирнова", "Кузнецов", "Попова", "Соколов", "Лебедева", "Testov", "Cohen", "Levi", "Mizrahi"}
+	cities     = []struct{ city, region, phone string }{
+		{"Москва", "Московская область", "+7"},
+		{"Санкт-Петербург", "Ленинградская область", "+7"},
+		{"Казань", "Татарстан", "+7"},
+		{"Екатеринбург", "Свердловская область", "+7"},
+		{"Новосибирск", "Новосибирская область", "+7"},
+		{"Алматы", "Алматинская область", "+7"},
+		{"Минск", "Минская область", "+375"},
+		{"Kiryat Mozkin", "Kraiot", "+972"},
+		{"Haifa", "Haifa District", "+972"},
+	}
+	streets  = []string{"Ленина", "Мира", "Садовая", "Центральная", "Лесная", "Ploshad Mira", "Herzl"}
+	products = []struct{ name, brand string }{
+		{"Mascaras", "Vivienne Sabo"},
+		{"Помада", "Maybelline"},
+		{"Кроссовки", "Nike"},
+		{"Кроссовки", "Adidas"},
+		{"Футболка", "Uniqlo"},
+		{"Джинсы", "Levi's"},
+		{"Наушники", "Sony"},
+		{"Смартфон", "Xiaomi"},
+		{"Чайник", "Bosch"},
+		{"Рюкзак", "Herschel"},
+		{"Книга", "Эксмо"},
+		{"Конструктор", "LEGO"},
+	}
+	sizes      = []string{"0", "XS", "S", "M", "L", "XL", "42", "44"}
+	currencies = []string{"RUB", "RUB", "RUB", "USD", "EUR", "KZT", "BYN", "JPY", "KWD"}
+	banks      = []string{"alpha", "sber", "tinkoff", "vtb", "hapoalim"}
+	providers  = []string{"wbpay", "sbp", "card"}
+	services   = []string{"meest", "cdek", "boxberry", "pochta", "wb"}
+	locales    = []string{"ru", "ru", "en", "kk", "be"}
+)
+
+// itemsDist - распределение числа товаров в заказе
+type itemsDist struct {
+	min, max int
+	// среднее геометрического распределения, 0 - равномерное от min до max
+	mean float64
+}
+
+// максимум товаров при геометрическом распределении
+const maxItems = 100
+
+// parseItemsDist разбирает значение флага -items: "3" - ровно 3 товара,
+// "1-5" - равномерно от 1 до 5, "geo:2.5" - геометрическое со средним 2.5
+func parseItemsDist(s string) (itemsDist, error) {
+	if v, ok := strings.CutPrefix(s, "geo:"); ok {
+		mean, err := strconv.ParseFloat(v, 64)
+		if err != nil || mean < 1 {
+			return itemsDist{}, fmt.Errorf("items %q: среднее должно быть не меньше 1", s)
+		}
+		return itemsDist{min: 1, max: maxItems, mean: mean}, nil
+	}
+	lo, hi, isRange := strings.Cut(s, "-")
+	min, err := strconv.Atoi(lo)
+	if err != nil || min < 1 {
+		return itemsDist{}, fmt.Errorf("items %q: ожидалось N, A-B или geo:MEAN", s)
+	}
+	max := min
+	if isRange {
+		if max, err = strconv.Atoi(hi); err != nil || max < min {
+			return itemsDist{}, fmt.Errorf("items %q: ожидалось N, A-B или geo:MEAN", s)
+		}
+	}
+	return itemsDist{min: min, max: max}, nil
+}
+
+func (d itemsDist) sample(r *rand.Rand) int {
+	if d.mean > 0 {
+		// число испытаний до первого успеха с вероятностью 1/mean
+		n, p := 1, 1/d.mean
+		for n < d.max && r.Float64() >= p {
+			n++
+		}
+		return n
+	}
+	return d.min + r.Intn(d.max-d.min+1)
+}
+
+// Generator создаёт заказы со случайными, но согласованными данными:
+// суммы сходятся, так что заказы проходят сверку сервиса
+type Generator struct {
+	r     *rand.Rand
+	items itemsDist
+	// покупатели по Ципфу: несколько покупателей дают большую часть заказов
+	keys int
+	zipf *rand.Zipf
+}
+
+// NewGenerator: keys - число покупателей, ключ сообщения - customer_id;
+// 0 - у каждого заказа свой покупатель и ключ order_uid. skew > 1 включает
+// перекос по Ципфу, иначе покупатели выбираются равномерно.
+func NewGenerator(seed int64, items itemsDist, keys int, skew float64) *Generator {
+	g := &Generator{r: rand.New(rand.NewSource(seed)), items: items, keys: keys}
+	if keys > 1 && skew > 1 {
+		g.zipf = rand.NewZipf(g.r, skew, 1, uint64(keys-1))
+	}
+	return g
+}
+
+func (g *Generator) pick(list []string) string {
+	return list[g.r.Intn(len(list))]
+}
+
+func (g *Generator) hex(n int) string {
+	b := make([]byte, n)
+	g.r.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (g *Generator) customer() string {
+	switch {
+	case g.keys <= 0:
+		return "customer-" + g.hex(6)
+	case g.zipf != nil:
+		return fmt.Sprintf("customer-%d", g.zipf.Uint64())
+	default:
+		return fmt.Sprintf("customer-%d", g.r.Intn(g.keys))
+	}
+}
+
+// Next возвращает ключ сообщения и новый заказ
+func (g *Generator) Next() (string, model.Order) {
+	uid := g.hex(8) + "test"
+	track := "WBIL" + strings.ToUpper(g.hex(5))
+	place := cities[g.r.Intn(len(cities))]
+	now := time.Now().UTC()
+
+	order := model.Order{
+		OrderUID:    uid,
+		TrackNumber: track,
+		Entry:       "WBIL",
+		Delivery: model.Delivery{
+			Name:    g.pick(firstNames) + " " + g.pick(lastNames),
+			Phone:   fmt.Sprintf("%s%09d", place.phone, g.r.Intn(1e9)),
+			Zip:     fmt.Sprintf("%06d", g.r.Intn(1e6)),
+			City:    place.city,
+			Address: fmt.Sprintf("%s %d", g.pick(streets), 1+g.r.Intn(120)),
+			Region:  place.region,
+			Email:   fmt.Sprintf("user%d@example.com", g.r.Intn(1e6)),
+		},
+		Locale:          g.pick(locales),
+		CustomerID:      g.customer(),
+		DeliveryService: g.pick(services),
+		Shardkey:        strconv.Itoa(g.r.Intn(10)),
+		SmID:            g.r.Intn(100),
+		DateCreated:     now,
+		OofShard:        strconv.Itoa(1 + g.r.Intn(2)),
+	}
+
+	currency := g.pick(currencies)
+	// цены в целых единицах валюты: в иенах, тенге и рублях числа крупнее
+	scale := 1
+	switch currency {
+	case "JPY", "KZT":
+		scale = 100
+	case "RUB":
+		scale = 50
+	}
+
+	goods := 0
+	for range g.items.sample(g.r) {
+		p := products[g.r.Intn(len(products))]
+		price := (10 + g.r.Intn(990)) * scale
+		sale := []int{0, 0, 5, 10, 15, 20, 30, 50}[g.r.Intn(8)]
+		total := price * (100 - sale) / 100
+		goods += total
+		order.Items = append(order.Items, model.Item{
+			ChrtID:      1000000 + g.r.Intn(9000000),
+			TrackNumber: track,
+			Price:       price,
+			Rid:         g.hex(10) + "test",
+			Name:        p.name,
+			Sale:        sale,
+			Size:        g.pick(sizes),
+			TotalPrice:  total,
+			NmID:        1000000 + g.r.Intn(9000000),
+			Brand:       p.brand,
+			Status:      202,
+		})
+	}
+
+	delivery := []int{0, 100, 300, 500, 1500}[g.r.Intn(5)] * scale
+	fee := 0
+	if currency != "RUB" && g.r.Intn(10) == 0 {
+		fee = goods / 20
+	}
+	order.Payment = model.Payment{
+		Transaction:  uid,
+		Currency:     currency,
+		Provider:     g.pick(providers),
+		Amount:       goods + delivery + fee,
+		PaymentDt:    now.Unix(),
+		Bank:         g.pick(banks),
+		DeliveryCost: delivery,
+		GoodsTotal:   goods,
+		CustomFee:    fee,
+	}
+
+	key := uid
+	if g.keys > 0 {
+		key = order.CustomerID
+	}
+	return key, order
+}
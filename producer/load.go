@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// message - одно сообщение для отправки
+type message struct {
+	// топик, пусто - топик заказов
+	topic string
+	key   string
+	value []byte
+	// вид сообщения для сводки: valid или вид ошибки
+	kind string
+	// тип содержимого, пусто - формат кодировщика
+	contentType string
+	// дополнительные заголовки
+	headers map[string]string
+	// не отправлять раньше этого времени от начала нагрузки
+	at time.Duration
+	// отправляется тем же потоком сразу после этого сообщения
+	then *message
+}
+
+// sendFunc отправляет сообщение в Kafka
+type sendFunc func(ctx context.Context, m message) error
+
+// loadOptions - параметры нагрузки
+type loadOptions struct {
+	// сообщений в секунду, 0 - без ограничения
+	rate float64
+	// 0 - пока не отправлено count сообщений
+	duration time.Duration
+	// 0 - пока не истечёт duration
+	count       int
+	concurrency int
+}
+
+// runLoad отправляет сообщения next с заданной частотой в concurrency потоков.
+// next вызывается из одной горутины; false останавливает нагрузку.
+func runLoad(ctx context.Context, opts loadOptions, next func() (message, bool), send sendFunc) *stats {
+	if opts.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.duration)
+		defer cancel()
+	}
+	st := &stats{}
+	jobs := make(chan message, opts.concurrency)
+
+	var wg sync.WaitGroup
+	for range opts.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range jobs {
+				for next := &m; next != nil; next = next.then {
+					start := time.Now()
+					// начатая отправка доводится до конца и после остановки нагрузки
+					err := send(context.WithoutCancel(ctx), *next)
+					st.record(time.Since(start), err)
+				}
+			}
+		}()
+	}
+
+	st.start = time.Now()
+	var interval time.Duration
+	if opts.rate > 0 {
+		interval = time.Duration(float64(time.Second) / opts.rate)
+	}
+loop:
+	for i := 0; opts.count <= 0 || i < opts.count; i++ {
+		m, ok := next()
+		if !ok {
+			break
+		}
+		// расписание от начала, а не от предыдущего сообщения: задержки не накапливаются
+		if wait := time.Until(st.start.Add(max(time.Duration(i)*interval, m.at))); wait > 0 {
+			select {
+			case <-ctx.Done():
+				break loop
+			case <-time.After(wait):
+			}
+		}
+		select {
+		case <-ctx.Done():
+			break loop
+		case jobs <- m:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	st.elapsed = time.Since(st.start)
+	return st
+}
+
+// stats - итоги нагрузки
+type stats struct {
+	mu        sync.Mutex
+	start     time.Time
+	elapsed   time.Duration
+	sent      int
+	failed    int
+	latencies []time.Duration
+	lastErr   error
+}
+
+func (s *stats) record(d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.failed++
+		s.lastErr = err
+		return
+	}
+	s.sent++
+	s.latencies = append(s.latencies, d)
+}
+
+// percentile - задержка, которую не превышает доля p отправок
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p*float64(len(sorted))+0.5) - 1
+	return sorted[max(0, min(i, len(sorted)-1))]
+}
+
+func (s *stats) print(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lat := slices.Clone(s.latencies)
+	slices.Sort(lat)
+	secs := s.elapsed.Seconds()
+	fmt.Fprintf(w, "Отправлено: %d, ошибок: %d за %s\n", s.sent, s.failed, s.elapsed.Round(time.Millisecond))
+	if secs > 0 {
+		fmt.Fprintf(w, "Пропускная способность: %.1f сообщений/с\n", float64(s.sent)/secs)
+	}
+	if len(lat) > 0 {
+		fmt.Fprintf(w, "Задержка отправки: p50 %s, p90 %s, p99 %s, max %s\n",
+			percentile(lat, 0.5), percentile(lat, 0.9), percentile(lat, 0.99), lat[len(lat)-1])
+	}
+	if s.lastErr != nil {
+		fmt.Fprintf(w, "Последняя ошибка: %v\n", s.lastErr)
+	}
+}
@@ -1,107 +1,251 @@
+// producer - генератор нагрузки: отправляет в Kafka заказы со случайными
+// правдоподобными данными или из файлов с заданной частотой и печатает
+// пропускную способность и задержки отправки.
 package main
 
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"math/rand"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
 	"time"
 
-	"demo-service/internal/model"
+	"demo-service/internal/codec"
+	"demo-service/internal/config"
+	kafkaclient "demo-service/internal/infrastructure/kafka"
 
 	"github.com/segmentio/kafka-go"
 )
 
-func generateOrder() model.Order {
+func main() {
+	// TLS, SASL и прочие настройки клиента берутся из тех же переменных окружения, что у сервиса
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println("Ошибка конфигурации:", err)
+		os.Exit(2)
+	}
 
-	orderID := fmt.Sprintf("%d", rand.Int63()) + "test"
+	brokers := flag.String("brokers", strings.Join(cfg.Kafka.Brokers, ","), "адреса брокеров через запятую")
+	topic := flag.String("topic", cfg.Kafka.Topic, "топик заказов")
+	format := flag.String("format", "json", "формат сообщений: json, protobuf, avro")
+	registryURL := flag.String("registry", cfg.SchemaRegistryURL, "реестр схем: http(s)://... или file://<каталог>")
+	count := flag.Int("n", 5, "сколько заказов отправить, 0 - без ограничения")
+	rate := flag.Float64("rate", 1, "заказов в секунду, 0 - без ограничения")
+	duration := flag.Duration("duration", 0, "длительность нагрузки, 0 - до отправки -n заказов")
+	concurrency := flag.Int("concurrency", 1, "число параллельных отправок")
+	itemsFlag := flag.String("items", "1", "товаров в заказе: N, A-B (равномерно) или geo:MEAN")
+	keys := flag.Int("keys", 0, "число покупателей, ключ сообщения - customer_id; 0 - ключ order_uid")
+	skew := flag.Float64("skew", 0, "перекос покупателей по Ципфу, больше 1; иначе равномерно")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "зерно генератора для воспроизводимых данных")
+	linger := flag.Duration("linger", 10*time.Millisecond, "сколько ждать накопления пачки перед отправкой, 0 - отправлять сразу")
+	verbose := flag.Bool("v", false, "печатать каждое отправленное сообщение")
+	faultsFlag := flag.String("faults", "", "доли ошибочных сообщений, например malformed=0.05,duplicate=0.1. Виды:"+faultUsage())
+	oversize := flag.Int("oversize", 2<<20, "размер названия товара в сообщениях oversized, байт")
+	statusTopic := flag.String("status-topic", "", "топик смен статусов для out_of_order, по умолчанию KAFKA_STATUS_TOPIC")
+	summaryPath := flag.String("summary", "", "файл сводки в JSON, - для вывода в stdout; с -faults по умолчанию stdout")
+	from := flag.String("from", "", "отправить заказы из файла JSON/NDJSON, каталога таких файлов или stdin (-) вместо генерации")
+	rewriteUID := flag.Bool("rewrite-uid", false, "с -from: заменить order_uid новыми")
+	rewriteDates := flag.Bool("rewrite-dates", false, "с -from: сдвинуть даты так, чтобы первый заказ был создан сейчас")
+	pace := flag.String("pace", "rate", "с -from: rate - с частотой -rate, original - с интервалами исходных сообщений")
+	speed := flag.Float64("speed", 1, "с -pace original: ускорение воспроизведения")
+	flag.Parse()
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	cfg.Kafka.Brokers = strings.Split(*brokers, ",")
 
-	delivery := model.Delivery{
-		Name:    "Test User",
-		Phone:   "+9721234567",
-		Zip:     "1234567",
-		City:    "Kiryat Mozkin",
-		Address: "Ploshad Mira 1",
-		Region:  "Kraiot",
-		Email:   "test@gmail.com",
+	if *count <= 0 && *duration <= 0 && *from == "" {
+		fmt.Println("Ошибка: нужно ограничение -n или -duration")
+		os.Exit(2)
 	}
-
-	payment := model.Payment{
-		Transaction:  orderID,
-		Currency:     "USD",
-		Provider:     "wbpay",
-		Amount:       2000,
-		PaymentDt:    time.Now().Unix(),
-		Bank:         "alpha",
-		DeliveryCost: 700,
-		GoodsTotal:   1200,
-		CustomFee:    0,
+	// с одной -duration нагрузка идёт всё время, а не до -n по умолчанию
+	if *duration > 0 && !set["n"] {
+		*count = 0
+	}
+	if *concurrency < 1 || *rate < 0 {
+		fmt.Println("Ошибка: -concurrency должно быть положительным, -rate - неотрицательным")
+		os.Exit(2)
+	}
+	items, err := parseItemsDist(*itemsFlag)
+	if err != nil {
+		fmt.Println("Ошибка:", err)
+		os.Exit(2)
+	}
+	faults, err := parseFaults(*faultsFlag)
+	if err != nil {
+		fmt.Println("Ошибка:", err)
+		os.Exit(2)
+	}
+	for _, f := range faults {
+		if f.kind == FaultOutOfOrder && *statusTopic == "" {
+			*statusTopic = cfg.Kafka.StatusTopic
+		}
+	}
+	if *from != "" {
+		switch {
+		case len(faults) > 0:
+			fmt.Println("Ошибка: -from и -faults несовместимы")
+			os.Exit(2)
+		case *pace != "rate" && *pace != "original":
+			fmt.Println("Ошибка: -pace должен быть rate или original")
+			os.Exit(2)
+		case *speed <= 0:
+			fmt.Println("Ошибка: -speed должен быть положительным")
+			os.Exit(2)
+		}
+		// из файла отправляется всё, а темп задают исходные сообщения, если не указано иное
+		if !set["n"] {
+			*count = 0
+		}
+		if *pace == "original" && !set["rate"] {
+			*rate = 0
+		}
 	}
 
-	item := model.Item{
-		ChrtID:      1234567,
-		TrackNumber: "WBILM" + orderID,
-		Price:       300,
-		Rid:         "ab123test",
-		Name:        "Mascaras",
-		Sale:        10,
-		Size:        "M",
-		TotalPrice:  270,
-		NmID:        7654321,
-		Brand:       "Vivienne Sabo",
-		Status:      202,
+	ctx := context.Background()
+
+	f, err := codec.ParseFormat(*format)
+	if err != nil {
+		fmt.Println("Ошибка:", err)
+		os.Exit(2)
+	}
+	var registry codec.Registry
+	if *registryURL != "" {
+		if registry, err = codec.NewRegistry(*registryURL); err != nil {
+			fmt.Println("Ошибка настройки реестра схем:", err)
+			os.Exit(1)
+		}
+	}
+	encoder, err := codec.NewEncoder(ctx, f, registry, *topic+"-value")
+	if err != nil {
+		fmt.Println("Ошибка регистрации схемы:", err)
+		os.Exit(1)
 	}
 
-	order := model.Order{
-		OrderUID:        orderID,
-		TrackNumber:     "WBILM" + orderID,
-		Entry:           "WBIL",
-		Delivery:        delivery,
-		Payment:         payment,
-		Items:           []model.Item{item},
-		Locale:          "en",
-		CustomerID:      "test",
-		DeliveryService: "meest",
-		Shardkey:        "1",
-		SmID:            1,
-		DateCreated:     time.Now().UTC(),
-		OofShard:        "1",
+	writer, err := kafkaclient.NewWriter(cfg.Kafka, *topic)
+	if err != nil {
+		fmt.Println("Ошибка настройки Kafka:", err)
+		os.Exit(1)
 	}
 
-	return order
-}
+	// нулевой BatchTimeout kafka-go заменяет секундой, и поток отправляет не больше
+	// сообщения в секунду, поэтому -linger 0 - минимальное ожидание
+	batchTimeout := *linger
+	if batchTimeout <= 0 {
+		batchTimeout = time.Nanosecond
+	}
+	writer.Balancer = &kafka.Hash{}
+	writer.BatchTimeout = batchTimeout
 
-func main() {
-	writer := &kafka.Writer{
-		Addr:     kafka.TCP("localhost:9092"),
-		Topic:    "orders",
-		Balancer: &kafka.LeastBytes{},
+	writers := map[string]*kafka.Writer{"": writer}
+	if *statusTopic != "" {
+		// смены статусов нужны только сценарию out_of_order
+		sw, err := kafkaclient.NewWriter(cfg.Kafka, *statusTopic)
+		if err != nil {
+			fmt.Println("Ошибка настройки Kafka:", err)
+			os.Exit(1)
+		}
+		sw.Balancer = &kafka.Hash{}
+		sw.BatchTimeout = batchTimeout
+		writers[*statusTopic] = sw
 	}
 
-	for i := 0; i < 5; i++ {
-		order := generateOrder()
-		data, err := json.Marshal(order)
+	gen := NewGenerator(*seed, items, *keys, *skew)
+	injector := newFaultInjector(*seed+1, gen, encoder.Encode, faults, *oversize, *statusTopic)
+	next := func() (message, bool) {
+		m, err := injector.next()
 		if err != nil {
 			fmt.Println("Ошибка при сериализации:", err)
-			continue
+			return message{}, false
 		}
-
-		err = writer.WriteMessages(context.Background(),
-			kafka.Message{
-				Key:   []byte(order.OrderUID),
-				Value: data,
-			},
-		)
+		return m, true
+	}
+	var readErr error
+	if *from != "" {
+		src, err := newFileSource(*from)
 		if err != nil {
-			fmt.Println("Ошибка при отправке:", err)
-		} else {
-			fmt.Println("Сообщение отправлено для заказа:", order.OrderUID)
+			fmt.Println("Ошибка:", err)
+			os.Exit(2)
+		}
+		opts := replayOptions{rewriteUID: *rewriteUID, rewriteDates: *rewriteDates, originalPace: *pace == "original", speed: *speed}
+		if f != codec.JSON {
+			opts.encode = encoder.Encode
+		}
+		replayer := newFileReplayer(src, opts)
+		next = func() (message, bool) {
+			m, err := replayer.next()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					readErr = err
+				}
+				return message{}, false
+			}
+			return m, true
 		}
+	}
+	send := func(ctx context.Context, m message) error {
+		contentType := m.contentType
+		if contentType == "" {
+			contentType = encoder.ContentType()
+		}
+		headers := []kafka.Header{{Key: codec.HeaderContentType, Value: []byte(contentType)}}
+		for k, v := range m.headers {
+			if !strings.EqualFold(k, codec.HeaderContentType) {
+				headers = append(headers, kafka.Header{Key: k, Value: []byte(v)})
+			}
+		}
+		err := writers[m.topic].WriteMessages(ctx, kafka.Message{
+			Key:     []byte(m.key),
+			Value:   m.value,
+			Headers: headers,
+		})
+		injector.summary.record(m, err)
+		if *verbose && err == nil {
+			fmt.Printf("Сообщение отправлено: %s, ключ %s\n", m.kind, m.key)
+		}
+		return err
+	}
 
-		time.Sleep(time.Second)
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	st := runLoad(ctx, loadOptions{rate: *rate, duration: *duration, count: *count, concurrency: *concurrency}, next, send)
+	st.print(os.Stdout)
+	if readErr != nil {
+		fmt.Println("Ошибка чтения заказов:", readErr)
+	}
+	if len(faults) > 0 || *summaryPath != "" {
+		if err := writeSummary(*summaryPath, injector.summary); err != nil {
+			fmt.Println("Ошибка записи сводки:", err)
+		}
 	}
 
-	if err := writer.Close(); err != nil {
-		fmt.Println("Ошибка при закрытии продюсера:", err)
+	for _, w := range writers {
+		if err := w.Close(); err != nil {
+			fmt.Println("Ошибка при закрытии продюсера:", err)
+		}
+	}
+	// с -faults ошибки отправки ожидаемы и есть в сводке
+	if readErr != nil || st.failed > 0 && len(faults) == 0 {
+		os.Exit(1)
+	}
+}
+
+// writeSummary печатает сводку отправленного, path пусто или "-" - в stdout
+func writeSummary(path string, s *faultSummary) error {
+	out := os.Stdout
+	if path != "" && path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
 	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
 }
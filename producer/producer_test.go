@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"demo-service/internal/model"
+	"demo-service/internal/reconcile"
+)
+
+func TestGenerator_Consistent(t *testing.T) {
+	items, err := parseItemsDist("1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen := NewGenerator(1, items, 10, 1.5)
+	customers := map[string]int{}
+	for range 1000 {
+		key, order := gen.Next()
+		if issues := reconcile.Check(&order); issues != nil {
+			t.Fatalf("сгенерированный заказ не сходится: %+v", issues)
+		}
+		if n := len(order.Items); n < 1 || n > 5 {
+			t.Fatalf("товаров %d, ожидалось 1-5", n)
+		}
+		if key != order.CustomerID {
+			t.Fatalf("ключ %q должен совпадать с customer_id %q", key, order.CustomerID)
+		}
+		customers[key]++
+	}
+	if len(customers) > 10 || customers["customer-0"] < 300 {
+		t.Errorf("ожидался перекос к первому покупателю: %v", customers)
+	}
+}
+
+func TestParseItemsDist(t *testing.T) {
+	for _, bad := range []string{"", "0", "5-3", "geo:0.5", "x"} {
+		if _, err := parseItemsDist(bad); err == nil {
+			t.Errorf("%q: ожидалась ошибка", bad)
+		}
+	}
+	d, err := parseItemsDist("geo:2")
+	if err != nil || d.mean != 2 {
+		t.Errorf("geo:2: %+v, %v", d, err)
+	}
+}
+
+func TestRunLoad(t *testing.T) {
+	var n atomic.Int32
+	next := func() (message, bool) { return message{key: "k"}, true }
+	send := func(context.Context, message) error {
+		if n.Add(1)%10 == 0 {
+			return errors.New("брокер недоступен")
+		}
+		return nil
+	}
+	st := runLoad(context.Background(), loadOptions{rate: 1000, count: 50, concurrency: 4}, next, send)
+	if st.sent != 45 || st.failed != 5 || len(st.latencies) != 45 {
+		t.Errorf("отправлено %d, ошибок %d", st.sent, st.failed)
+	}
+	// 50 сообщений при 1000/с - не меньше 49 мс
+	if st.elapsed < 45*time.Millisecond {
+		t.Errorf("частота не ограничена: %s", st.elapsed)
+	}
+}
+
+func TestFaultInjector(t *testing.T) {
+	if _, err := parseFaults("malformed=0.6,duplicate=0.6"); err == nil {
+		t.Error("сумма долей больше 1 должна быть ошибкой")
+	}
+	if _, err := parseFaults("unknown=0.1"); err == nil {
+		t.Error("неизвестный вид должен быть ошибкой")
+	}
+	faults, err := parseFaults("malformed=0.1,missing_uid=0.1,duplicate=0.1,out_of_order=0.1,invalid_totals=0.1,oversized=0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encode := func(o *model.Order) ([]byte, error) { return json.Marshal(o) }
+	// ключи по клиенту: повтор должен уйти с ключом исходного заказа
+	f := newFaultInjector(1, NewGenerator(1, itemsDist{min: 1, max: 1}, 10, 0), encode, faults, 1000, "statuses")
+	keys := make(map[string]string)
+	for range 1000 {
+		m, err := f.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var order model.Order
+		decodeErr := json.Unmarshal(m.value, &order)
+		switch m.kind {
+		case FaultMalformed:
+			if decodeErr == nil {
+				t.Fatal("malformed должен быть некорректным JSON")
+			}
+		case FaultMissingUID:
+			if order.OrderUID != "" {
+				t.Fatal("в missing_uid не должно быть order_uid")
+			}
+		case FaultInvalidTotals:
+			if reconcile.Check(&order) == nil {
+				t.Fatal("invalid_totals должен не сходиться")
+			}
+		case FaultOversized:
+			if len(m.value) < 1000 {
+				t.Fatalf("oversized меньше заданного размера: %d", len(m.value))
+			}
+		case FaultOutOfOrder:
+			if m.topic != "statuses" || !strings.Contains(string(m.value), `"shipped"`) {
+				t.Fatalf("неверная смена статуса: %s", m.value)
+			}
+			if m.then == nil || m.then.key != m.key || !strings.Contains(string(m.then.value), `"paid"`) {
+				t.Fatalf("за shipped должен идти paid того же заказа: %+v", m.then)
+			}
+		case FaultDuplicate:
+			if m.key != keys[order.OrderUID] {
+				t.Fatalf("повтор %s с ключом %q, исходный заказ - %q", order.OrderUID, m.key, keys[order.OrderUID])
+			}
+		case kindValid:
+			keys[order.OrderUID] = m.key
+		}
+		f.summary.record(m, nil)
+	}
+
+	for _, kind := range []string{kindValid, FaultMalformed, FaultMissingUID, FaultDuplicate, FaultOutOfOrder, FaultInvalidTotals, FaultOversized} {
+		k := f.summary.Kinds[kind]
+		if k == nil || k.Sent == 0 || k.Expect == "" {
+			t.Errorf("%s: нет в сводке: %+v", kind, k)
+			continue
+		}
+		if kind != kindValid && len(k.Keys) != k.Sent {
+			t.Errorf("%s: ключей %d, отправлено %d", kind, len(k.Keys), k.Sent)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package test
+
+import (
+	"demo-service/internal/model"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestMoney(t *testing.T) {
+	usd, err := model.FromMajor(1817, "usd")
+	if err != nil || usd.Amount != 181700 || usd.Currency != "USD" {
+		t.Fatalf("FromMajor: %+v, %v", usd, err)
+	}
+	if s := (model.Money{Amount: -5, Currency: "USD"}).String(); s != "-0.05 USD" {
+		t.Errorf("String: %q", s)
+	}
+	if s := (model.Money{Amount: 1500, Currency: "JPY"}).String(); s != "1500 JPY" {
+		t.Errorf("String: %q", s)
+	}
+	if s := (model.Money{Amount: 12345, Currency: "KWD"}).String(); s != "12.345 KWD" {
+		t.Errorf("String: %q", s)
+	}
+
+	total, err := model.Sum(usd, model.Money{Amount: 50, Currency: "USD"}.Mul(3))
+	if err != nil || total.Amount != 181850 {
+		t.Errorf("Sum: %+v, %v", total, err)
+	}
+	if _, err := usd.Add(model.Money{Amount: 1, Currency: "EUR"}); !errors.Is(err, model.ErrCurrencyMismatch) {
+		t.Errorf("ожидалась ErrCurrencyMismatch, получено %v", err)
+	}
+	if _, err := model.FromMajor(1, "XXX"); !errors.Is(err, model.ErrUnknownCurrency) {
+		t.Errorf("ожидалась ErrUnknownCurrency, получено %v", err)
+	}
+}
+
+func TestPaymentWireFormat(t *testing.T) {
+	// формат JSON заказа не меняется, Money получается из полей платежа
+	data := []byte(`{"transaction":"t","currency":"RUB","amount":1817,"delivery_cost":1500,"goods_total":317,"custom_fee":0}`)
+	var p model.Payment
+	if err := json.Unmarshal(data, &p); err != nil {
+		t.Fatal(err)
+	}
+	amount, err := p.AmountMoney()
+	if err != nil || amount != (model.Money{Amount: 181700, Currency: "RUB"}) {
+		t.Errorf("AmountMoney: %+v, %v", amount, err)
+	}
+	goods, _ := p.GoodsTotalMoney()
+	delivery, _ := p.DeliveryCostMoney()
+	if sum, _ := goods.Add(delivery); sum != amount {
+		t.Errorf("goods_total + delivery_cost = %v, ожидалось %v", sum, amount)
+	}
+}
+
+func TestRateTable(t *testing.T) {
+	rates, err := model.NewRateTable("RUB", map[string]string{"USD": "92.5", "EUR": "100", "JPY": "0.6"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		in   model.Money
+		to   model.Currency
+		want int64
+	}{
+		{model.Money{Amount: 100, Currency: "USD"}, "RUB", 9250},
+		{model.Money{Amount: 10000, Currency: "RUB"}, "EUR", 100},
+		{model.Money{Amount: 1000, Currency: "JPY"}, "RUB", 60000},
+		// 1 евро = 100/92.5 доллара = 1.081..., округляется до центов
+		{model.Money{Amount: 100, Currency: "EUR"}, "USD", 108},
+		{model.Money{Amount: 1, Currency: "USD"}, "JPY", 2},
+	}
+	for _, tc := range cases {
+		got, err := rates.Convert(tc.in, tc.to)
+		if err != nil || got.Amount != tc.want || got.Currency != tc.to {
+			t.Errorf("%v -> %s: получено %v, %v, ожидалось %d", tc.in, tc.to, got, err, tc.want)
+		}
+	}
+	if _, err := rates.Convert(model.Money{Amount: 1, Currency: "GBP"}, "RUB"); !errors.Is(err, model.ErrNoRate) {
+		t.Errorf("ожидалась ErrNoRate, получено %v", err)
+	}
+	if _, err := model.NewRateTable("RUB", map[string]string{"USD": "-1"}); err == nil {
+		t.Error("ожидалась ошибка для отрицательного курса")
+	}
+}
+
+func TestParseCurrency(t *testing.T) {
+	for _, code := range []string{"BRL", "MXN", "HKD", "SGD", "NZD", "ZAR", "THB", "clf"} {
+		if _, err := model.ParseCurrency(code); err != nil {
+			t.Errorf("%s: %v", code, err)
+		}
+	}
+	if e := model.Currency("IQD").Exponent(); e != 3 {
+		t.Errorf("IQD: ожидалось 3 знака, получено %d", e)
+	}
+	for _, code := range []string{"XAU", "ABC", ""} {
+		if _, err := model.ParseCurrency(code); !errors.Is(err, model.ErrUnknownCurrency) {
+			t.Errorf("%q: ожидалась ErrUnknownCurrency, получено %v", code, err)
+		}
+	}
+}
+
+func TestItemMoney(t *testing.T) {
+	it := model.Item{Price: 453, TotalPrice: 317}
+	price, err := it.PriceMoney("rub")
+	if err != nil || price != (model.Money{Amount: 45300, Currency: "RUB"}) {
+		t.Errorf("PriceMoney: %+v, %v", price, err)
+	}
+	total, err := it.TotalPriceMoney("KWD")
+	if err != nil || total != (model.Money{Amount: 317000, Currency: "KWD"}) {
+		t.Errorf("TotalPriceMoney: %+v, %v", total, err)
+	}
+	if _, err := it.PriceMoney("XYZ"); !errors.Is(err, model.ErrUnknownCurrency) {
+		t.Errorf("ожидалась ErrUnknownCurrency, получено %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package test
 import (
 	"demo-service/internal/model"
 	"encoding/json"
+	"errors"
 	"reflect"
 	"testing"
 	"time"
@@ -70,3 +71,32 @@ func TestOrderJSON(t *testing.T) {
 		t.Errorf("Got %v, want %v", got, order)
 	}
 }
+
+func TestOrderValidate(t *testing.T) {
+	valid := func() *model.Order {
+		return &model.Order{
+			OrderUID:    "b563feb7b2b84b6test",
+			TrackNumber: "WBILMTESTTRACK",
+			Payment:     model.Payment{Currency: "USD"},
+			Items:       []model.Item{{ChrtID: 9934930}},
+		}
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	noItems := valid()
+	noItems.Items = nil
+	noTrack := valid()
+	noTrack.TrackNumber = ""
+	badCurrency := valid()
+	badCurrency.Payment.Currency = "доллары"
+	for _, o := range []*model.Order{noItems, noTrack, badCurrency, {}} {
+		if err := o.Validate(); err == nil {
+			t.Errorf("ожидалась ошибка для %+v", o)
+		}
+	}
+	if err := badCurrency.Validate(); !errors.Is(err, model.ErrUnknownCurrency) {
+		t.Errorf("ожидалась ErrUnknownCurrency, получено %v", err)
+	}
+}
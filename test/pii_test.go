@@ -0,0 +1,38 @@
+package test
+
+import (
+	"demo-service/internal/model"
+	"testing"
+)
+
+func TestDeliveryMasked(t *testing.T) {
+	d := model.Delivery{
+		Name:    "Test Testov",
+		Phone:   "+9720000012",
+		Zip:     "2639809",
+		City:    "Kiryat Mozkin",
+		Address: "Ploshad Mira 15",
+		Region:  "Kraiot",
+		Email:   "test@gmail.com",
+	}
+	want := model.Delivery{
+		Name:    "T*** T***",
+		Phone:   "+********12",
+		Zip:     "***",
+		City:    "Kiryat Mozkin",
+		Address: "***",
+		Region:  "Kraiot",
+		Email:   "t***@gmail.com",
+	}
+	if got := d.Masked(); got != want {
+		t.Errorf("получено %+v, ожидалось %+v", got, want)
+	}
+	if got := (model.Delivery{}).Masked(); got != (model.Delivery{}) {
+		t.Errorf("пустые поля должны оставаться пустыми: %+v", got)
+	}
+
+	o := &model.Order{OrderUID: "uid", Delivery: d}
+	if m := o.Masked(); m.Delivery != want || o.Delivery != d || m.OrderUID != "uid" {
+		t.Error("Masked должен вернуть маскированную копию, не меняя заказ")
+	}
+}
@@ -0,0 +1,35 @@
+package test
+
+import (
+	"demo-service/internal/model"
+	"errors"
+	"testing"
+)
+
+func TestCheckTransition(t *testing.T) {
+	cases := []struct {
+		from, to model.Status
+		err      error
+	}{
+		{model.StatusCreated, model.StatusPaid, nil},
+		{model.StatusPaid, model.StatusAssembled, nil},
+		{model.StatusAssembled, model.StatusShipped, nil},
+		{model.StatusShipped, model.StatusDelivered, nil},
+		{model.StatusDelivered, model.StatusReturned, nil},
+		{model.StatusAssembled, model.StatusCancelled, nil},
+		{model.StatusCreated, model.StatusShipped, model.ErrInvalidTransition},
+		{model.StatusShipped, model.StatusCancelled, model.ErrInvalidTransition},
+		{model.StatusCancelled, model.StatusPaid, model.ErrInvalidTransition},
+		{model.StatusDelivered, model.StatusCreated, model.ErrInvalidTransition},
+		{model.StatusCreated, "lost", model.ErrUnknownStatus},
+	}
+	for _, tc := range cases {
+		err := model.CheckTransition(tc.from, tc.to)
+		if tc.err == nil && err != nil {
+			t.Errorf("%s -> %s: неожиданная ошибка %v", tc.from, tc.to, err)
+		}
+		if tc.err != nil && !errors.Is(err, tc.err) {
+			t.Errorf("%s -> %s: ожидалась %v, получено %v", tc.from, tc.to, tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+// Интерфейс просмотра заказов: список с фильтрами и страницами, карточка
+// заказа и поток новых заказов. Маршрут хранится в адресе после #:
+// #/?customer_id=...&delivery_service=...&page_size=50 - список,
+// #/order/<order_uid> - карточка.
+"use strict";
+
+const $ = (id) => document.getElementById(id);
+
+// el создаёт элемент; текст вставляется через textContent, не как HTML
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    if (k === "class") node.className = v;
+    else if (k.startsWith("on")) node.addEventListener(k.slice(2), v);
+    else node.setAttribute(k, v);
+  }
+  for (const c of children) {
+    node.append(c instanceof Node ? c : String(c ?? ""));
+  }
+  return node;
+}
+
+function fmtDate(value) {
+  if (!value) return "";
+  const d = new Date(value);
+  return isNaN(d) ? String(value) : d.toLocaleString();
+}
+
+function fmtMoney(amount, currency) {
+  return `${Number(amount || 0).toLocaleString()} ${currency || ""}`.trim();
+}
+
+function statusBadge(status) {
+  return el("span", { class: "status " + (status || "") }, status || "—");
+}
+
+// ApiError - ответ API с ошибкой, detail берётся из problem+json
+class ApiError extends Error {
+  constructor(status, title, detail) {
+    super(detail || title);
+    this.status = status;
+    this.title = title;
+  }
+}
+
+async function api(path) {
+  let resp;
+  try {
+    resp = await fetch(path, { headers: { Accept: "application/json", "X-Client-ID": "web-ui" } });
+  } catch (e) {
+    throw new ApiError(0, "Сервис недоступен", "Не удалось связаться с сервисом, проверьте соединение");
+  }
+  if (resp.ok) return resp.json();
+  let problem = {};
+  try {
+    problem = await resp.json();
+  } catch (e) {
+    // тело не JSON, например ответ прокси
+  }
+  throw new ApiError(resp.status, problem.title || resp.statusText, problem.detail);
+}
+
+function showError(err) {
+  const box = $("error");
+  if (!err) {
+    box.hidden = true;
+    return;
+  }
+  let text = err.message;
+  if (err.status === 404) text = "Не найдено: " + (err.message || "");
+  else if (err.status === 503) text = "Хранилище недоступно, попробуйте позже";
+  else if (err.status) text = `Ошибка ${err.status}: ${err.message || err.title}`;
+  box.textContent = text;
+  box.hidden = false;
+}
+
+function setLoading(on) {
+  $("loading").hidden = !on;
+}
+
+// ---------- список ----------
+
+const list = {
+  filters: { customer_id: "", delivery_service: "", page_size: "50" },
+  // токены уже пройденных страниц для кнопки «Назад»
+  tokens: [""],
+  next: "",
+  uids: new Set(),
+  pending: 0,
+};
+
+function routeParams() {
+  const [, query = ""] = location.hash.slice(1).split("?");
+  return new URLSearchParams(query);
+}
+
+function filtersQuery() {
+  const q = new URLSearchParams();
+  for (const [k, v] of Object.entries(list.filters)) {
+    if (v) q.set(k, v);
+  }
+  return q;
+}
+
+async function showList() {
+  const params = routeParams();
+  const filters = {
+    customer_id: params.get("customer_id") || "",
+    delivery_service: params.get("delivery_service") || "",
+    page_size: params.get("page_size") || "50",
+  };
+  if (JSON.stringify(filters) !== JSON.stringify(list.filters)) {
+    list.filters = filters;
+    list.tokens = [""];
+  }
+  const form = $("filters");
+  for (const [k, v] of Object.entries(list.filters)) form.elements[k].value = v;
+
+  $("order-view").hidden = true;
+  $("list-view").hidden = false;
+  connectStream();
+  await loadPage();
+}
+
+async function loadPage() {
+  const token = list.tokens[list.tokens.length - 1];
+  const q = filtersQuery();
+  if (token) q.set("page_token", token);
+  setLoading(true);
+  showError(null);
+  try {
+    const page = await api("/orders?" + q);
+    list.next = page.next_page_token || "";
+    renderOrders(page.orders || []);
+  } catch (err) {
+    renderOrders([]);
+    list.next = "";
+    showError(err);
+  } finally {
+    setLoading(false);
+    renderPager();
+  }
+}
+
+function orderRow(o, isNew) {
+  return el("tr", { class: isNew ? "new" : "", "data-uid": o.order_uid, onclick: () => openOrder(o.order_uid) },
+    el("td", {}, o.order_uid),
+    el("td", {}, fmtDate(o.date_created)),
+    el("td", {}, o.customer_id),
+    el("td", {}, o.delivery_service),
+    el("td", {}, statusBadge(o.status)),
+    el("td", { class: "num" }, (o.items || []).length),
+    el("td", { class: "num" }, fmtMoney(o.payment && o.payment.amount, o.payment && o.payment.currency)),
+  );
+}
+
+function renderOrders(orders) {
+  const body = $("orders");
+  body.replaceChildren(...orders.map((o) => orderRow(o, false)));
+  list.uids = new Set(orders.map((o) => o.order_uid));
+  list.pending = 0;
+  $("new-orders").hidden = true;
+  $("empty").hidden = orders.length > 0;
+}
+
+function renderPager() {
+  const n = list.tokens.length;
+  $("prev").disabled = n <= 1;
+  $("next").disabled = !list.next;
+  $("page").textContent = "Страница " + n;
+}
+
+function openOrder(uid) {
+  location.hash = "#/order/" + encodeURIComponent(uid);
+}
+
+// новый или изменённый заказ из потока
+function onStreamOrder(o) {
+  if ($("list-view").hidden) return;
+  const body = $("orders");
+  const existing = body.querySelector(`tr[data-uid="${CSS.escape(o.order_uid)}"]`);
+  if (existing) {
+    existing.replaceWith(orderRow(o, true));
+    return;
+  }
+  // на первой странице новые заказы видны сразу, на остальных - счётчик
+  if (list.tokens.length === 1) {
+    body.prepend(orderRow(o, true));
+    list.uids.add(o.order_uid);
+    $("empty").hidden = true;
+    return;
+  }
+  list.pending++;
+  const notice = $("new-orders");
+  notice.textContent = `Новых заказов: ${list.pending}. Показать`;
+  notice.hidden = false;
+}
+
+// ---------- карточка заказа ----------
+
+function fieldRows(table, rows) {
+  table.replaceChildren(...rows.map(([name, value]) =>
+    el("tr", {}, el("th", {}, name), el("td", {}, value instanceof Node ? value : String(value ?? "")))));
+}
+
+async function showOrder(uid) {
+  $("list-view").hidden = true;
+  $("order-view").hidden = true;
+  setLoading(true);
+  showError(null);
+  let order;
+  try {
+    order = await api("/order/" + encodeURIComponent(uid));
+  } catch (err) {
+    setLoading(false);
+    if (err.status === 404) err.message = `заказ ${uid} не найден или удалён`;
+    showError(err);
+    return;
+  }
+  setLoading(false);
+  renderOrder(order);
+  $("order-view").hidden = false;
+
+  try {
+    const history = await api(`/order/${encodeURIComponent(uid)}/status/history`);
+    $("history").replaceChildren(...history.map((h) => el("tr", {},
+      el("td", {}, fmtDate(h.changed_at)),
+      el("td", {}, h.from ? statusBadge(h.from) : "—"),
+      el("td", {}, statusBadge(h.to)),
+      el("td", {}, h.source),
+      el("td", {}, h.reason || ""),
+    )));
+  } catch (err) {
+    $("history").replaceChildren(el("tr", {}, el("td", { colspan: 5, class: "muted" }, "История недоступна: " + err.message)));
+  }
+}
+
+function renderOrder(o) {
+  const d = o.delivery || {};
+  const p = o.payment || {};
+  $("order-title").textContent = "Заказ " + o.order_uid;
+  fieldRows($("order-fields"), [
+    ["Статус", statusBadge(o.status)],
+    ["track_number", o.track_number],
+    ["entry", o.entry],
+    ["Создан", fmtDate(o.date_created)],
+    ["Покупатель", o.customer_id],
+    ["Служба доставки", o.delivery_service],
+    ["Язык", o.locale],
+    ["shardkey / oof_shard", `${o.shardkey} / ${o.oof_shard}`],
+    ["sm_id", o.sm_id],
+  ]);
+  fieldRows($("delivery-fields"), [
+    ["Получатель", d.name],
+    ["Телефон", d.phone],
+    ["Email", d.email],
+    ["Индекс", d.zip],
+    ["Город", d.city],
+    ["Адрес", d.address],
+    ["Регион", d.region],
+  ]);
+  fieldRows($("payment-fields"), [
+    ["Транзакция", p.transaction],
+    ["request_id", p.request_id],
+    ["Провайдер", p.provider],
+    ["Банк", p.bank],
+    ["Оплачен", p.payment_dt ? fmtDate(p.payment_dt * 1000) : ""],
+    ["Товары", fmtMoney(p.goods_total, p.currency)],
+    ["Доставка", fmtMoney(p.delivery_cost, p.currency)],
+    ["Таможенный сбор", fmtMoney(p.custom_fee, p.currency)],
+    ["Итого", fmtMoney(p.amount, p.currency)],
+  ]);
+  $("items").replaceChildren(...(o.items || []).map((it) => el("tr", {},
+    el("td", {}, it.chrt_id),
+    el("td", {}, it.name),
+    el("td", {}, it.brand),
+    el("td", {}, it.size),
+    el("td", { class: "num" }, fmtMoney(it.price, p.currency)),
+    el("td", { class: "num" }, (it.sale || 0) + "%"),
+    el("td", { class: "num" }, fmtMoney(it.total_price, p.currency)),
+    el("td", {}, it.nm_id),
+    el("td", {}, it.rid),
+    el("td", {}, it.status),
+  )));
+  $("history").replaceChildren();
+}
+
+// ---------- поток новых заказов ----------
+
+let stream = null;
+let streamQuery = null;
+
+function connectStream() {
+  const q = new URLSearchParams();
+  if (list.filters.customer_id) q.set("customer_id", list.filters.customer_id);
+  if (list.filters.delivery_service) q.set("delivery_service", list.filters.delivery_service);
+  const query = q.toString();
+  if (stream && streamQuery === query) return;
+  if (stream) stream.close();
+  if (!window.EventSource) return;
+
+  streamQuery = query;
+  // EventSource сам переподключается и передаёт Last-Event-ID
+  stream = new EventSource("/orders/stream" + (query ? "?" + query : ""));
+  const live = $("live");
+  stream.onopen = () => {
+    live.textContent = "обновляется";
+    live.classList.remove("off");
+  };
+  stream.onerror = () => {
+    live.textContent = "нет соединения, переподключение…";
+    live.classList.add("off");
+  };
+  stream.addEventListener("order", (e) => {
+    try {
+      onStreamOrder(JSON.parse(e.data));
+    } catch (err) {
+      console.error("некорректное событие потока", err);
+    }
+  });
+}
+
+// ---------- маршрутизация ----------
+
+function route() {
+  const path = location.hash.slice(1).split("?")[0] || "/";
+  const m = path.match(/^\/order\/(.+)$/);
+  if (m) {
+    showOrder(decodeURIComponent(m[1]));
+  } else {
+    showList();
+  }
+}
+
+$("filters").addEventListener("submit", (e) => {
+  e.preventDefault();
+  const form = e.target;
+  const q = new URLSearchParams();
+  for (const name of ["customer_id", "delivery_service", "page_size"]) {
+    const v = form.elements[name].value.trim();
+    if (v) q.set(name, v);
+  }
+  const hash = "#/?" + q;
+  if (location.hash === hash) route();
+  else location.hash = hash;
+});
+
+$("filters").addEventListener("reset", () => {
+  setTimeout(() => { location.hash = "#/"; });
+});
+
+$("lookup").addEventListener("submit", (e) => {
+  e.preventDefault();
+  const uid = $("lookup-uid").value.trim();
+  if (uid) openOrder(uid);
+});
+
+$("prev").addEventListener("click", () => {
+  if (list.tokens.length > 1) {
+    list.tokens.pop();
+    loadPage();
+  }
+});
+
+$("next").addEventListener("click", () => {
+  if (list.next) {
+    list.tokens.push(list.next);
+    loadPage();
+  }
+});
+
+$("new-orders").addEventListener("click", () => {
+  list.tokens = [""];
+  loadPage();
+});
+
+window.addEventListener("hashchange", route);
+route();
@@ -0,0 +1,9 @@
+// Package web - интерфейс просмотра заказов, встроенный в бинарник сервиса
+package web
+
+import "embed"
+
+// Files - index.html и каталог assets
+//
+//go:embed index.html assets
+var Files embed.FS